@@ -3,6 +3,9 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"nofx/decision"
+	"nofx/market"
+	"nofx/mcp"
 	"os"
 	"time"
 )
@@ -12,7 +15,7 @@ type TraderConfig struct {
 	ID      string `json:"id"`
 	Name    string `json:"name"`
 	Enabled bool   `json:"enabled"` // 是否启用该trader
-	AIModel string `json:"ai_model"` // "qwen" or "deepseek"
+	AIModel string `json:"ai_model"` // "qwen", "deepseek", "custom", "ensemble" or "rule"
 
 	// 交易平台选择（二选一）
 	Exchange string `json:"exchange"` // "binance" or "hyperliquid"
@@ -31,6 +34,31 @@ type TraderConfig struct {
 	AsterSigner     string `json:"aster_signer,omitempty"`      // Aster API钱包地址
 	AsterPrivateKey string `json:"aster_private_key,omitempty"` // Aster API钱包私钥
 
+	// 行情数据配置（默认使用交易平台自己的行情数据）
+	MarketDataFallback bool                     `json:"market_data_fallback,omitempty"` // 平台行情获取失败时回退到币安
+	Timeframes         []market.TimeframeConfig `json:"timeframes,omitempty"`           // 多周期分析配置（为空时使用3m+4h）
+	MaxImpactPct       float64                  `json:"max_impact_pct,omitempty"`       // 开仓前盘口冲击上限（百分比，默认0.5）
+
+	// 相关性风控
+	MaxCorrelatedPositions int     `json:"max_correlated_positions,omitempty"` // 同方向高相关持仓数上限（含本次开仓，默认2，-1表示不限）
+	CorrelationThreshold   float64 `json:"correlation_threshold,omitempty"`    // 视为高相关的收益率相关系数（默认0.8）
+
+	// 市场状态规则（如高波动时禁止开仓）
+	RegimeRules []decision.RegimeRule `json:"regime_rules,omitempty"`
+
+	// 候选币种过滤链（按顺序执行，为空时使用默认的15M持仓价值过滤）
+	Filters []decision.FilterConfig `json:"filters,omitempty"`
+
+	// Prompt模板（text/template文件路径，为空时使用内置默认模板）
+	SystemPromptTemplate string `json:"system_prompt_template,omitempty"`
+	UserPromptTemplate   string `json:"user_prompt_template,omitempty"`
+
+	// 风险参数（最多持仓、风险回报比、信心度、保证金、仓位倍数、冷却期；未配置的使用默认值，持仓数/风险回报比/信心度/冷却期设为-1表示不限）
+	RiskProfile decision.RiskProfile `json:"risk_profile"`
+
+	// 决策解析或校验失败后让AI修正的最大轮数（0表示不修正，上限3）
+	MaxCorrectionRounds int `json:"max_correction_rounds,omitempty"`
+
 	// AI配置
 	QwenKey     string `json:"qwen_key,omitempty"`
 	DeepSeekKey string `json:"deepseek_key,omitempty"`
@@ -40,6 +68,22 @@ type TraderConfig struct {
 	CustomAPIKey    string `json:"custom_api_key,omitempty"`
 	CustomModelName string `json:"custom_model_name,omitempty"`
 
+	// 结构化输出（"json_schema" 或 "tool"，为空时从文本中解析JSON；API不支持时自动回退为文本）
+	AIOutputMode string `json:"ai_output_mode,omitempty"`
+
+	// 多模型集成（ai_model为"ensemble"时必填：同一份prompt并发发给多个模型，按策略合并决策）
+	Ensemble *decision.EnsembleConfig `json:"ensemble,omitempty"`
+
+	// 决策流水线（"single" 单次决策，默认；"debate" 多方、空方分别论证后由裁判决策）
+	Pipeline string                 `json:"pipeline,omitempty"`
+	Debate   *decision.DebateConfig `json:"debate,omitempty"` // 多空辩论各阶段的模型（为空的阶段使用ai_model配置的主模型）
+
+	// 规则策略（ai_model为"rule"时必填：不调用AI，按EMA交叉、RSI均值回归、通道突破等规则决策）
+	Strategy *decision.RuleConfig `json:"strategy,omitempty"`
+
+	// 决策记忆（最近N条决策及理由、最近平仓盈亏和持仓时长、币种胜率、冷却中的币种，按token预算渲染进prompt）
+	Memory *decision.MemoryConfig `json:"memory,omitempty"`
+
 	InitialBalance      float64 `json:"initial_balance"`
 	ScanIntervalMinutes int     `json:"scan_interval_minutes"`
 }
@@ -61,9 +105,13 @@ type Config struct {
 	MaxDailyLoss       float64        `json:"max_daily_loss"`
 	MaxDrawdown        float64        `json:"max_drawdown"`
 	StopTradingMinutes int            `json:"stop_trading_minutes"`
-	Leverage           LeverageConfig `json:"leverage"` // 杠杆配置
+	Leverage           LeverageConfig `json:"leverage"`                  // 杠杆配置
+	MarketDataDir      string         `json:"market_data_dir,omitempty"` // 本地历史行情目录（存在时优先读取，默认market_data）
 }
 
+// DefaultMarketDataDir 本地历史行情默认目录
+const DefaultMarketDataDir = "market_data"
+
 // LoadConfig 从文件加载配置
 func LoadConfig(filename string) (*Config, error) {
 	data, err := os.ReadFile(filename)
@@ -95,6 +143,10 @@ func LoadConfig(filename string) (*Config, error) {
 		}
 	}
 
+	if config.MarketDataDir == "" {
+		config.MarketDataDir = DefaultMarketDataDir
+	}
+
 	// 验证配置
 	if err := config.Validate(); err != nil {
 		return nil, fmt.Errorf("配置验证失败: %w", err)
@@ -122,8 +174,41 @@ func (c *Config) Validate() error {
 		if trader.Name == "" {
 			return fmt.Errorf("trader[%d]: Name不能为空", i)
 		}
-		if trader.AIModel != "qwen" && trader.AIModel != "deepseek" && trader.AIModel != "custom" {
-			return fmt.Errorf("trader[%d]: ai_model必须是 'qwen', 'deepseek' 或 'custom'", i)
+		if trader.AIModel != "qwen" && trader.AIModel != "deepseek" && trader.AIModel != "custom" && trader.AIModel != "ensemble" && trader.AIModel != "rule" {
+			return fmt.Errorf("trader[%d]: ai_model必须是 'qwen', 'deepseek', 'custom', 'ensemble' 或 'rule'", i)
+		}
+		if (trader.AIModel == "rule") != (trader.Strategy != nil) {
+			return fmt.Errorf("trader[%d]: ai_model为'rule'时必须（且只有此时才能）配置strategy", i)
+		}
+		if trader.Strategy != nil {
+			if err := trader.Strategy.Validate(trader.Timeframes); err != nil {
+				return fmt.Errorf("trader[%d]: strategy: %w", i, err)
+			}
+			if trader.Pipeline == decision.PipelineDebate {
+				return fmt.Errorf("trader[%d]: 规则策略不能使用多空辩论流水线", i)
+			}
+		}
+		if (trader.AIModel == "ensemble") != (trader.Ensemble != nil) {
+			return fmt.Errorf("trader[%d]: ai_model为'ensemble'时必须（且只有此时才能）配置ensemble", i)
+		}
+		if trader.Ensemble != nil {
+			if err := trader.Ensemble.Validate(); err != nil {
+				return fmt.Errorf("trader[%d]: %w", i, err)
+			}
+		}
+		if trader.Pipeline != "" && trader.Pipeline != decision.PipelineSingle && trader.Pipeline != decision.PipelineDebate {
+			return fmt.Errorf("trader[%d]: pipeline必须是 'single' 或 'debate': %q", i, trader.Pipeline)
+		}
+		if trader.Pipeline == decision.PipelineDebate && trader.Ensemble != nil {
+			return fmt.Errorf("trader[%d]: 多空辩论流水线不能与多模型集成同时使用", i)
+		}
+		if trader.Debate != nil {
+			if trader.Pipeline != decision.PipelineDebate {
+				return fmt.Errorf("trader[%d]: 配置debate时pipeline必须是'debate'", i)
+			}
+			if err := trader.Debate.Validate(); err != nil {
+				return fmt.Errorf("trader[%d]: %w", i, err)
+			}
 		}
 
 		// 验证交易平台配置
@@ -166,6 +251,41 @@ func (c *Config) Validate() error {
 				return fmt.Errorf("trader[%d]: 使用自定义API时必须配置custom_model_name", i)
 			}
 		}
+		if trader.AIOutputMode != mcp.OutputText && trader.AIOutputMode != mcp.OutputJSONSchema && trader.AIOutputMode != mcp.OutputToolCall {
+			return fmt.Errorf("trader[%d]: ai_output_mode必须是json_schema或tool: %q", i, trader.AIOutputMode)
+		}
+		if err := market.ValidateTimeframes(trader.Timeframes); err != nil {
+			return fmt.Errorf("trader[%d]: %w", i, err)
+		}
+		if trader.MaxImpactPct < 0 {
+			return fmt.Errorf("trader[%d]: max_impact_pct不能为负数", i)
+		}
+		if err := decision.ValidateFilters(trader.Filters); err != nil {
+			return fmt.Errorf("trader[%d]: %w", i, err)
+		}
+		if err := decision.ValidateRegimeRules(trader.RegimeRules); err != nil {
+			return fmt.Errorf("trader[%d]: %w", i, err)
+		}
+		if trader.Memory != nil {
+			if err := trader.Memory.Validate(); err != nil {
+				return fmt.Errorf("trader[%d]: %w", i, err)
+			}
+		}
+		if trader.MaxCorrectionRounds < 0 || trader.MaxCorrectionRounds > decision.MaxCorrectionRounds {
+			return fmt.Errorf("trader[%d]: max_correction_rounds必须在0到%d之间", i, decision.MaxCorrectionRounds)
+		}
+		if err := trader.RiskProfile.Validate(); err != nil {
+			return fmt.Errorf("trader[%d]: %w", i, err)
+		}
+		if err := decision.ValidatePromptTemplates(trader.SystemPromptTemplate, trader.UserPromptTemplate); err != nil {
+			return fmt.Errorf("trader[%d]: %w", i, err)
+		}
+		if trader.MaxCorrelatedPositions < -1 {
+			return fmt.Errorf("trader[%d]: max_correlated_positions必须大于等于-1（-1表示不限）", i)
+		}
+		if trader.CorrelationThreshold < 0 || trader.CorrelationThreshold > 1 {
+			return fmt.Errorf("trader[%d]: correlation_threshold必须在0到1之间", i)
+		}
 		if trader.InitialBalance <= 0 {
 			return fmt.Errorf("trader[%d]: initial_balance必须大于0", i)
 		}
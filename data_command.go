@@ -0,0 +1,108 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"nofx/config"
+	"nofx/market"
+	"nofx/market/store"
+	"os"
+	"strings"
+	"time"
+)
+
+// runDataCommand 处理 `nofx data ...` 子命令
+func runDataCommand(args []string) error {
+	if len(args) == 0 || args[0] != "download" {
+		return fmt.Errorf("用法: nofx data download --symbols BTCUSDT,ETHUSDT --from 2024-01-01 --interval 3m,4h [--to 2024-06-01] [--exchange binance] [--dir %s]", config.DefaultMarketDataDir)
+	}
+
+	fs := flag.NewFlagSet("data download", flag.ExitOnError)
+	symbols := fs.String("symbols", "", "币种列表，逗号分隔（如 BTCUSDT,ETHUSDT）")
+	from := fs.String("from", "", "起始时间（2006-01-02 或 RFC3339）")
+	to := fs.String("to", "", "结束时间（默认当前时间）")
+	intervals := fs.String("interval", "3m,4h", "K线周期，逗号分隔")
+	exchange := fs.String("exchange", "binance", "数据来源平台: binance / hyperliquid / aster")
+	testnet := fs.Bool("testnet", false, "使用Hyperliquid测试网")
+	dir := fs.String("dir", config.DefaultMarketDataDir, "本地存储目录")
+	funding := fs.Bool("funding", true, "同时下载资金费率历史")
+	openInterest := fs.Bool("oi", true, "同时下载历史持仓量（币安仅保留最近30天）")
+	fs.Parse(args[1:])
+
+	if *symbols == "" || *from == "" {
+		fs.Usage()
+		return fmt.Errorf("必须指定 --symbols 和 --from")
+	}
+
+	opts := store.DownloadOptions{
+		Symbols:      splitList(*symbols),
+		Intervals:    splitList(*intervals),
+		To:           time.Now(),
+		Funding:      *funding,
+		OpenInterest: *openInterest,
+	}
+
+	var err error
+	if opts.From, err = parseTime(*from); err != nil {
+		return fmt.Errorf("--from: %w", err)
+	}
+	if *to != "" {
+		if opts.To, err = parseTime(*to); err != nil {
+			return fmt.Errorf("--to: %w", err)
+		}
+	}
+	for _, interval := range opts.Intervals {
+		if _, err := market.IntervalDuration(interval); err != nil {
+			return fmt.Errorf("--interval: %w", err)
+		}
+	}
+
+	provider, err := market.NewHistoryProvider(*exchange, *testnet)
+	if err != nil {
+		return err
+	}
+	st, err := store.Open(*dir)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("📥 下载历史行情: %s %v %v [%s ~ %s] -> %s", provider.Name(), opts.Symbols, opts.Intervals,
+		opts.From.Format(time.RFC3339), opts.To.Format(time.RFC3339), *dir)
+	if err := st.Download(provider, opts); err != nil {
+		return err
+	}
+	log.Printf("✓ 下载完成")
+	return nil
+}
+
+// useLocalMarketStore 本地存储目录存在时，让行情获取优先读取本地数据
+func useLocalMarketStore(dir string) {
+	if _, err := os.Stat(dir); err != nil {
+		return
+	}
+	st, err := store.Open(dir)
+	if err != nil {
+		log.Printf("⚠️  打开本地行情存储失败: %v", err)
+		return
+	}
+	market.SetKlineStore(st)
+	log.Printf("✓ 已启用本地行情存储: %s", dir)
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
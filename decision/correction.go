@@ -0,0 +1,118 @@
+package decision
+
+import (
+	"fmt"
+	"log"
+	"nofx/mcp"
+	"time"
+)
+
+// MaxCorrectionRounds 修正轮数上限（每轮都是一次完整的AI调用）
+const MaxCorrectionRounds = 3
+
+// CorrectionRound 一轮修正的记录
+type CorrectionRound struct {
+	Round    int                `json:"round"`
+	Feedback string             `json:"feedback"`           // 发送给AI的修正提示
+	Response string             `json:"response"`           // AI的修正输出
+	Error    string             `json:"error,omitempty"`    // 调用或解析失败的原因
+	Rejected []RejectedDecision `json:"rejected,omitempty"` // 修正后仍未通过验证的决策
+}
+
+// correctionErrors 需要反馈给AI的问题（无问题时返回nil）
+func correctionErrors(decision *FullDecision, parseErr error) []string {
+	if parseErr != nil {
+		return []string{parseErr.Error()}
+	}
+	var errors []string
+	for _, r := range decision.Rejected {
+		errors = append(errors, fmt.Sprintf("%s %s: %s", r.Decision.Symbol, r.Decision.Action, r.Reason))
+	}
+	return errors
+}
+
+// correctDecision 把解析/校验错误连同原始输出和约束发回给AI，要求输出修正后的JSON决策数组
+// 最多进行 ctx.MaxCorrectionRounds 轮；修正失败时保留最后一次能解析的结果
+func correctDecision(ctx *Context, client *mcp.Client, templates *PromptTemplates, messages []mcp.Message,
+	response string, decision *FullDecision, parseErr error) (*FullDecision, error) {
+	var rounds []CorrectionRound
+
+	errors := correctionErrors(decision, parseErr)
+	for round := 1; round <= ctx.MaxCorrectionRounds; round++ {
+		if len(errors) == 0 {
+			break
+		}
+		if !ctx.Deadline.IsZero() && time.Now().After(ctx.Deadline) {
+			log.Printf("⏰ 已到本周期截止时间，停止修正")
+			break
+		}
+
+		feedback, err := templates.renderCorrection(ctx, client.StructuredOutput(), errors, response)
+		if err != nil {
+			return decision, err
+		}
+		log.Printf("🔁 决策修正 第%d/%d轮: %d个问题", round, ctx.MaxCorrectionRounds, len(errors))
+
+		messages = append(messages,
+			mcp.Message{Role: "assistant", Content: response},
+			mcp.Message{Role: "user", Content: feedback})
+		record := CorrectionRound{Round: round, Feedback: feedback}
+
+		corrected, err := client.ChatWithSchema(messages, decisionResponseSchema)
+		if err != nil {
+			record.Error = fmt.Sprintf("调用AI API失败: %v", err)
+			rounds = append(rounds, record)
+			log.Printf("  ❌ %s", record.Error)
+			break
+		}
+		record.Response = corrected
+		response = corrected
+
+		// 上一轮已通过验证但修正时遗漏的平仓仍然执行（平仓不应因修正而丢失）
+		var carried []Decision
+		if parseErr == nil {
+			carried = decision.Decisions
+		}
+		result, err := parseFullDecisionResponse(corrected, ctx, carried)
+		if err != nil {
+			record.Error = err.Error()
+			rounds = append(rounds, record)
+			log.Printf("  ❌ 修正输出解析失败: %v", err)
+			if parseErr != nil {
+				parseErr = err // 仍没有可用的结果
+			}
+			errors = []string{err.Error()}
+			continue
+		}
+
+		// 修正输出只包含JSON，沿用首轮的思维链
+		if decision != nil && decision.CoTTrace != "" {
+			result.CoTTrace = decision.CoTTrace
+		}
+		record.Rejected = result.Rejected
+		rounds = append(rounds, record)
+		log.Printf("  ✓ 修正后 %d 个决策通过验证，%d 个被拒绝", len(result.Decisions), len(result.Rejected))
+
+		decision, parseErr = result, nil
+		errors = correctionErrors(decision, nil)
+	}
+
+	if decision != nil {
+		decision.Corrections = rounds
+	}
+	return decision, parseErr
+}
+
+// carryOverReducing 把上一轮通过验证、但本轮遗漏的平仓决策加回来
+func carryOverReducing(previous, current []Decision) []Decision {
+	seen := make(map[string]bool, len(current))
+	for _, d := range current {
+		seen[d.Symbol+"_"+d.Action] = true
+	}
+	for _, d := range previous {
+		if (d.Action == "close_long" || d.Action == "close_short") && !seen[d.Symbol+"_"+d.Action] {
+			current = append(current, d)
+		}
+	}
+	return current
+}
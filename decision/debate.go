@@ -0,0 +1,160 @@
+package decision
+
+import (
+	"fmt"
+	"log"
+	"nofx/mcp"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// 决策流水线
+const (
+	PipelineSingle = "single" // 单次决策（默认）
+	PipelineDebate = "debate" // 多方、空方分别论证，再由裁判输出决策
+)
+
+// 多空辩论的阶段
+const (
+	DebateBull  = "bull"
+	DebateBear  = "bear"
+	DebateJudge = "judge"
+)
+
+// DebateConfig 多空辩论流水线配置（各阶段可以使用不同的模型，为空时使用trader的主模型）
+type DebateConfig struct {
+	Bull  *ModelConfig `json:"bull,omitempty"`  // 多方分析师
+	Bear  *ModelConfig `json:"bear,omitempty"`  // 空方分析师
+	Judge *ModelConfig `json:"judge,omitempty"` // 裁判（输出最终决策）
+}
+
+// Validate 校验辩论配置
+func (c *DebateConfig) Validate() error {
+	stages := []string{DebateBull, DebateBear, DebateJudge}
+	for i, m := range []*ModelConfig{c.Bull, c.Bear, c.Judge} {
+		if m == nil {
+			continue
+		}
+		if err := m.Validate(); err != nil {
+			return fmt.Errorf("debate.%s: %w", stages[i], err)
+		}
+	}
+	return nil
+}
+
+// Debate 多空辩论流水线各阶段的AI客户端
+type Debate struct {
+	Bull  ModelClient
+	Bear  ModelClient
+	Judge ModelClient
+}
+
+// NewDebate 按配置创建各阶段的AI客户端（未配置的阶段使用主模型）
+func NewDebate(cfg DebateConfig, main ModelClient) (*Debate, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	stage := func(m *ModelConfig) ModelClient {
+		if m == nil {
+			return main
+		}
+		return m.NewClient()
+	}
+	return &Debate{
+		Bull:  stage(cfg.Bull),
+		Bear:  stage(cfg.Bear),
+		Judge: stage(cfg.Judge),
+	}, nil
+}
+
+// DebateStage 多空辩论中一个阶段的记录
+type DebateStage struct {
+	Stage    string `json:"stage"` // bull / bear / judge
+	Model    string `json:"model"`
+	Response string `json:"response"`        // 该阶段的原始输出
+	Error    string `json:"error,omitempty"` // 调用或解析失败的原因
+}
+
+// GetDebateDecision 多空辩论流水线：多方和空方基于同一份 Context 并发论证，裁判听取双方论证后输出决策
+// 裁判的 System Prompt 和输出格式与单次决策相同，辩论记录附加在 User Prompt 之后
+func GetDebateDecision(ctx *Context, debate *Debate) (*FullDecision, error) {
+	// 1. 行情数据只获取一次，三个阶段看到相同的输入
+	if err := fetchMarketDataForContext(ctx); err != nil {
+		return nil, fmt.Errorf("获取市场数据失败: %w", err)
+	}
+	templates := ctx.Prompts
+	if templates == nil {
+		templates = defaultPromptTemplates
+	}
+
+	// 2. 多方和空方并发论证
+	var bull, bear DebateStage
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		bull = argue(ctx, templates, DebateBull, templates.Bull, debate.Bull)
+	}()
+	go func() {
+		defer wg.Done()
+		bear = argue(ctx, templates, DebateBear, templates.Bear, debate.Bear)
+	}()
+	wg.Wait()
+	stages := []DebateStage{bull, bear}
+
+	// 3. 裁判输出决策（一方失败时裁判只听取另一方）
+	bullArgument, bearArgument := bull.Response, bear.Response
+	if bull.Error != "" {
+		bullArgument = "（多方论证失败，无观点）"
+	}
+	if bear.Error != "" {
+		bearArgument = "（空方论证失败，无观点）"
+	}
+	appendix, err := templates.renderJudge(ctx, bullArgument, bearArgument)
+	if err != nil {
+		return &FullDecision{Timestamp: time.Now(), Decisions: []Decision{}, Debate: stages}, err
+	}
+	log.Printf("  ⚖️  [%s] 裁判决策中...", debate.Judge.Name)
+	decision, err := decide(ctx, debate.Judge.Client, appendix)
+
+	judge := DebateStage{Stage: DebateJudge, Model: debate.Judge.Name}
+	if decision == nil {
+		decision = &FullDecision{Timestamp: time.Now(), Decisions: []Decision{}}
+	}
+	judge.Response = decision.RawResponse
+	if err != nil {
+		judge.Error = err.Error()
+	}
+	decision.Debate = append(stages, judge)
+	return decision, err
+}
+
+// argue 多方或空方的一轮论证（纯文本，不解析决策）
+func argue(ctx *Context, templates *PromptTemplates, stage string, tmpl *template.Template, member ModelClient) DebateStage {
+	record := DebateStage{Stage: stage, Model: member.Name}
+
+	systemPrompt, err := execute(tmpl, newPromptData(ctx))
+	if err != nil {
+		record.Error = err.Error()
+		return record
+	}
+	userPrompt, err := templates.renderUser(ctx)
+	if err != nil {
+		record.Error = err.Error()
+		return record
+	}
+
+	response, err := member.Client.Chat([]mcp.Message{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: userPrompt},
+	})
+	if err != nil {
+		record.Error = fmt.Sprintf("调用AI API失败: %v", err)
+		log.Printf("  ❌ [%s] %s论证失败: %v", member.Name, stage, err)
+		return record
+	}
+	record.Response = response
+	log.Printf("  🗣  [%s] %s论证完成（%d字）", member.Name, stage, len([]rune(response)))
+	return record
+}
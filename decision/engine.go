@@ -1,16 +1,22 @@
 package decision
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"nofx/market"
 	"nofx/mcp"
 	"nofx/pool"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
+// marketDataWorkers 并发获取行情数据的最大协程数
+const marketDataWorkers = 8
+
 // PositionInfo 持仓信息
 type PositionInfo struct {
 	Symbol           string  `json:"symbol"`
@@ -62,16 +68,41 @@ type Context struct {
 	Positions       []PositionInfo          `json:"positions"`
 	CandidateCoins  []CandidateCoin         `json:"candidate_coins"`
 	MarketDataMap   map[string]*market.Data `json:"-"` // 不序列化，但内部使用
+	MarketOptions   *market.Options         `json:"-"` // 行情数据源和时间框架配置（为空时使用默认值）
 	OITopDataMap    map[string]*OITopData   `json:"-"` // OI Top数据映射
 	Performance     interface{}             `json:"-"` // 历史表现分析（logger.PerformanceAnalysis）
 	BTCETHLeverage  int                     `json:"-"` // BTC/ETH杠杆倍数（从配置读取）
 	AltcoinLeverage int                     `json:"-"` // 山寨币杠杆倍数（从配置读取）
+	ScanIntervalMin int                     `json:"-"` // 扫描间隔（分钟）
+	Deadline        time.Time               `json:"-"` // 本周期截止时间（到期后取消未完成的行情请求，为零表示不限）
+
+	MarketDataErrors  map[string]string `json:"-"` // 获取行情数据失败的币种及原因
+	DataQualityIssues map[string]string `json:"-"` // 行情数据质量不合格的币种及原因（禁止开仓）
+
+	Filters            []CandidateFilter `json:"-"` // 候选币种过滤链（为空时使用 DefaultFilters）
+	FilteredCandidates map[string]string `json:"-"` // 被过滤的候选币种 -> "过滤器: 原因"
+
+	BTCData                *market.Data              `json:"-"` // BTC行情（基准，不在候选和持仓中也会获取）
+	Correlations           *market.CorrelationMatrix `json:"-"` // 候选和持仓币种的收益率相关性与BTC beta
+	CorrelationThreshold   float64                   `json:"-"` // 视为同一笔交易的相关系数阈值
+	MaxCorrelatedPositions int                       `json:"-"` // 同方向高相关持仓数上限（0表示不限）
+
+	RegimeRules []RegimeRule `json:"-"` // 市场状态规则（validateDecision 执行）
+
+	Prompts *PromptTemplates `json:"-"` // System / User Prompt 模板（为空时使用内置默认模板）
+
+	Risk         RiskProfile          `json:"-"` // 风险参数（零值字段使用 DefaultRiskProfile）
+	RecentCloses map[string]time.Time `json:"-"` // 币种最近平仓时间（冷却期判断）
+
+	MaxCorrectionRounds int `json:"-"` // 解析或校验失败后让AI修正的最大轮数（0表示不修正）
+
+	Memory *Memory `json:"-"` // 决策记忆（最近决策、平仓结果、币种胜率；为空时不渲染）
 }
 
 // Decision AI的交易决策
 type Decision struct {
 	Symbol          string  `json:"symbol"`
-	Action          string  `json:"action"` // "open_long", "open_short", "close_long", "close_short", "hold", "wait"
+	Action          string  `json:"action" enum:"open_long,open_short,close_long,close_short,hold,wait"` // 结构化输出时enum生成schema枚举
 	Leverage        int     `json:"leverage,omitempty"`
 	PositionSizeUSD float64 `json:"position_size_usd,omitempty"`
 	StopLoss        float64 `json:"stop_loss,omitempty"`
@@ -83,10 +114,22 @@ type Decision struct {
 
 // FullDecision AI的完整决策（包含思维链）
 type FullDecision struct {
-	UserPrompt string     `json:"user_prompt"` // 发送给AI的输入prompt
-	CoTTrace   string     `json:"cot_trace"`   // 思维链分析（AI输出）
-	Decisions  []Decision `json:"decisions"`   // 具体决策列表
-	Timestamp  time.Time  `json:"timestamp"`
+	UserPrompt  string             `json:"user_prompt"`        // 发送给AI的输入prompt
+	CoTTrace    string             `json:"cot_trace"`          // 思维链分析（AI输出）
+	RawResponse string             `json:"raw_response"`       // AI的原始输出（修正前）
+	Decisions   []Decision         `json:"decisions"`          // 通过验证的决策列表
+	Rejected    []RejectedDecision `json:"rejected,omitempty"` // 未通过验证的决策及原因
+	Timestamp   time.Time          `json:"timestamp"`
+
+	Corrections []CorrectionRound `json:"corrections,omitempty"` // 校验失败后让AI修正的各轮记录
+	Ensemble    *EnsembleResult   `json:"ensemble,omitempty"`    // 多模型集成时各模型的输出和合并结果
+	Debate      []DebateStage     `json:"debate,omitempty"`      // 多空辩论流水线各阶段的记录
+}
+
+// RejectedDecision 未通过验证的决策
+type RejectedDecision struct {
+	Decision Decision `json:"decision"`
+	Reason   string   `json:"reason"`
 }
 
 // GetFullDecision 获取AI的完整交易决策（批量分析所有币种和持仓）
@@ -95,25 +138,51 @@ func GetFullDecision(ctx *Context, mcpClient *mcp.Client) (*FullDecision, error)
 	if err := fetchMarketDataForContext(ctx); err != nil {
 		return nil, fmt.Errorf("获取市场数据失败: %w", err)
 	}
+	return decide(ctx, mcpClient, "")
+}
 
-	// 2. 构建 System Prompt（固定规则）和 User Prompt（动态数据）
-	systemPrompt := buildSystemPrompt(ctx.Account.TotalEquity, ctx.BTCETHLeverage, ctx.AltcoinLeverage)
-	userPrompt := buildUserPrompt(ctx)
+// decide 渲染prompt、调用AI并解析决策（行情数据需已获取；只读ctx，可并发调用）
+// appendix 附加在 User Prompt 之后（如多空辩论记录），为空表示单次决策
+func decide(ctx *Context, mcpClient *mcp.Client, appendix string) (*FullDecision, error) {
+	// 2. 渲染 System Prompt（固定规则）和 User Prompt（动态数据）
+	structured := mcpClient.StructuredOutput()
+	templates := ctx.Prompts
+	if templates == nil {
+		templates = defaultPromptTemplates
+	}
+	systemPrompt, err := templates.renderSystem(ctx, structured)
+	if err != nil {
+		return nil, err
+	}
+	userPrompt, err := templates.renderUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	userPrompt += appendix
 
 	// 3. 调用AI API（使用 system + user prompt）
-	aiResponse, err := mcpClient.CallWithMessages(systemPrompt, userPrompt)
+	messages := []mcp.Message{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: userPrompt},
+	}
+	aiResponse, err := mcpClient.ChatWithSchema(messages, decisionResponseSchema)
 	if err != nil {
 		return nil, fmt.Errorf("调用AI API失败: %w", err)
 	}
 
-	// 4. 解析AI响应
-	decision, err := parseFullDecisionResponse(aiResponse, ctx.Account.TotalEquity, ctx.BTCETHLeverage, ctx.AltcoinLeverage)
+	// 4. 解析AI响应（有解析或校验错误时按配置让AI修正）
+	decision, err := parseFullDecisionResponse(aiResponse, ctx, nil)
+	if ctx.MaxCorrectionRounds > 0 {
+		decision, err = correctDecision(ctx, mcpClient, templates, messages, aiResponse, decision, err)
+	}
+	if decision != nil {
+		decision.Timestamp = time.Now()
+		decision.UserPrompt = userPrompt // 保存输入prompt
+		decision.RawResponse = aiResponse
+	}
 	if err != nil {
-		return nil, fmt.Errorf("解析AI响应失败: %w", err)
+		return decision, fmt.Errorf("解析AI响应失败: %w", err)
 	}
-
-	decision.Timestamp = time.Now()
-	decision.UserPrompt = userPrompt // 保存输入prompt
 	return decision, nil
 }
 
@@ -121,6 +190,9 @@ func GetFullDecision(ctx *Context, mcpClient *mcp.Client) (*FullDecision, error)
 func fetchMarketDataForContext(ctx *Context) error {
 	ctx.MarketDataMap = make(map[string]*market.Data)
 	ctx.OITopDataMap = make(map[string]*OITopData)
+	ctx.MarketDataErrors = make(map[string]string)
+	ctx.DataQualityIssues = make(map[string]string)
+	ctx.FilteredCandidates = make(map[string]string)
 
 	// 收集所有需要获取数据的币种
 	symbolSet := make(map[string]bool)
@@ -139,31 +211,50 @@ func fetchMarketDataForContext(ctx *Context) error {
 		symbolSet[coin.Symbol] = true
 	}
 
-	// 并发获取市场数据
+	// 3. BTC作为计算beta的基准
+	requested := symbolSet[market.BTCSymbol]
+	symbolSet[market.BTCSymbol] = true
+
 	// 持仓币种集合（用于判断是否跳过OI检查）
 	positionSymbols := make(map[string]bool)
 	for _, pos := range ctx.Positions {
 		positionSymbols[pos.Symbol] = true
 	}
 
-	for symbol := range symbolSet {
-		data, err := market.Get(symbol)
-		if err != nil {
-			// 单个币种失败不影响整体，只记录错误
+	// 并发获取市场数据（单个币种失败不影响整体，只记录错误）
+	results := fetchMarketDataConcurrently(ctx, symbolSet)
+
+	// 数据质量检查：有问题的币种不参与交易（持仓币种保留数据以便决策平仓，但禁止加仓）
+	for symbol, data := range results {
+		if len(data.QualityIssues) == 0 {
+			continue
+		}
+		reason := strings.Join(data.QualityIssues, "; ")
+		ctx.DataQualityIssues[symbol] = reason
+		if positionSymbols[symbol] {
+			log.Printf("⚠️  %s 行情数据异常（持仓币种，仅允许平仓）: %s", symbol, reason)
 			continue
 		}
+		log.Printf("⚠️  %s 行情数据异常，排除此币种: %s", symbol, reason)
+		delete(results, symbol)
+	}
 
-		// ⚠️ 流动性过滤：持仓价值低于15M USD的币种不做（多空都不做）
-		// 持仓价值 = 持仓量 × 当前价格
-		// 但现有持仓必须保留（需要决策是否平仓）
-		isExistingPosition := positionSymbols[symbol]
-		if !isExistingPosition && data.OpenInterest != nil && data.CurrentPrice > 0 {
-			// 计算持仓价值（USD）= 持仓量 × 当前价格
-			oiValue := data.OpenInterest.Latest * data.CurrentPrice
-			oiValueInMillions := oiValue / 1_000_000 // 转换为百万美元单位
-			if oiValueInMillions < 15 {
-				log.Printf("⚠️  %s 持仓价值过低(%.2fM USD < 15M)，跳过此币种 [持仓量:%.0f × 价格:%.4f]",
-					symbol, oiValueInMillions, data.OpenInterest.Latest, data.CurrentPrice)
+	ctx.BTCData = results[market.BTCSymbol]
+	if !requested {
+		delete(results, market.BTCSymbol)
+	}
+
+	// 候选币种过滤链（现有持仓必须保留，需要决策是否平仓）
+	filters := ctx.Filters
+	if filters == nil {
+		filters, _ = NewFilterChain(nil)
+	}
+	now := time.Now()
+	for symbol, data := range results {
+		if !positionSymbols[symbol] {
+			if name, reason := applyFilters(filters, symbol, data, now); name != "" {
+				log.Printf("🚫 %s 被过滤器 %s 排除: %s", symbol, name, reason)
+				ctx.FilteredCandidates[symbol] = name + ": " + reason
 				continue
 			}
 		}
@@ -171,6 +262,20 @@ func fetchMarketDataForContext(ctx *Context) error {
 		ctx.MarketDataMap[symbol] = data
 	}
 
+	// 收益率相关性和BTC beta（基于覆盖时间最长的周期）
+	correlationData := make(map[string]*market.Data, len(ctx.MarketDataMap)+1)
+	for symbol, data := range ctx.MarketDataMap {
+		correlationData[symbol] = data
+	}
+	if ctx.BTCData != nil {
+		correlationData[market.BTCSymbol] = ctx.BTCData
+	}
+	var timeframes []market.TimeframeConfig
+	if ctx.MarketOptions != nil {
+		timeframes = ctx.MarketOptions.Timeframes
+	}
+	ctx.Correlations = market.ComputeCorrelations(correlationData, market.CorrelationInterval(timeframes))
+
 	// 加载OI Top数据（不影响主流程）
 	oiPositions, err := pool.GetOITopPositions()
 	if err == nil {
@@ -191,263 +296,115 @@ func fetchMarketDataForContext(ctx *Context) error {
 	return nil
 }
 
-// calculateMaxCandidates 根据账户状态计算需要分析的候选币种数量
-func calculateMaxCandidates(ctx *Context) int {
-	// 直接返回候选池的全部币种数量
-	// 因为候选池已经在 auto_trader.go 中筛选过了
-	// 固定分析前20个评分最高的币种（来自AI500）
-	return len(ctx.CandidateCoins)
-}
-
-// buildSystemPrompt 构建 System Prompt（固定规则，可缓存）
-func buildSystemPrompt(accountEquity float64, btcEthLeverage, altcoinLeverage int) string {
-	var sb strings.Builder
-
-	// === 核心使命 ===
-	sb.WriteString("你是专业的加密货币交易AI，在币安合约市场进行自主交易。\n\n")
-	sb.WriteString("# 🎯 核心目标\n\n")
-	sb.WriteString("**最大化夏普比率（Sharpe Ratio）**\n\n")
-	sb.WriteString("夏普比率 = 平均收益 / 收益波动率\n\n")
-	sb.WriteString("**这意味着**：\n")
-	sb.WriteString("- ✅ 高质量交易（高胜率、大盈亏比）→ 提升夏普\n")
-	sb.WriteString("- ✅ 稳定收益、控制回撤 → 提升夏普\n")
-	sb.WriteString("- ✅ 耐心持仓、让利润奔跑 → 提升夏普\n")
-	sb.WriteString("- ❌ 频繁交易、小盈小亏 → 增加波动，严重降低夏普\n")
-	sb.WriteString("- ❌ 过度交易、手续费损耗 → 直接亏损\n")
-	sb.WriteString("- ❌ 过早平仓、频繁进出 → 错失大行情\n\n")
-	sb.WriteString("**关键认知**: 系统每3分钟扫描一次，但不意味着每次都要交易！\n")
-	sb.WriteString("大多数时候应该是 `wait` 或 `hold`，只在极佳机会时才开仓。\n\n")
-
-	// === 硬约束（风险控制）===
-	sb.WriteString("# ⚖️ 硬约束（风险控制）\n\n")
-	sb.WriteString("1. **风险回报比**: 必须 ≥ 1:3（冒1%风险，赚3%+收益）\n")
-	sb.WriteString("2. **最多持仓**: 3个币种（质量>数量）\n")
-	sb.WriteString(fmt.Sprintf("3. **单币仓位**: 山寨%.0f-%.0f U(%dx杠杆) | BTC/ETH %.0f-%.0f U(%dx杠杆)\n",
-		accountEquity*0.8, accountEquity*1.5, altcoinLeverage, accountEquity*5, accountEquity*10, btcEthLeverage))
-	sb.WriteString("4. **保证金**: 总使用率 ≤ 90%\n\n")
-
-	// === 做空激励 ===
-	sb.WriteString("# 📉 做多做空平衡\n\n")
-	sb.WriteString("**重要**: 下跌趋势做空的利润 = 上涨趋势做多的利润\n\n")
-	sb.WriteString("- 上涨趋势 → 做多\n")
-	sb.WriteString("- 下跌趋势 → 做空\n")
-	sb.WriteString("- 震荡市场 → 观望\n\n")
-	sb.WriteString("**不要有做多偏见！做空是你的核心工具之一**\n\n")
-
-	// === 交易频率认知 ===
-	sb.WriteString("# ⏱️ 交易频率认知\n\n")
-	sb.WriteString("**量化标准**:\n")
-	sb.WriteString("- 优秀交易员：每天2-4笔 = 每小时0.1-0.2笔\n")
-	sb.WriteString("- 过度交易：每小时>2笔 = 严重问题\n")
-	sb.WriteString("- 最佳节奏：开仓后持有至少30-60分钟\n\n")
-	sb.WriteString("**自查**:\n")
-	sb.WriteString("如果你发现自己每个周期都在交易 → 说明标准太低\n")
-	sb.WriteString("如果你发现持仓<30分钟就平仓 → 说明太急躁\n\n")
-
-	// === 开仓信号强度 ===
-	sb.WriteString("# 🎯 开仓标准（严格）\n\n")
-	sb.WriteString("只在**强信号**时开仓，不确定就观望。\n\n")
-	sb.WriteString("**你拥有的完整数据**：\n")
-	sb.WriteString("- 📊 **原始序列**：3分钟价格序列(MidPrices数组) + 4小时K线序列\n")
-	sb.WriteString("- 📈 **技术序列**：EMA20序列、MACD序列、RSI7序列、RSI14序列\n")
-	sb.WriteString("- 💰 **资金序列**：成交量序列、持仓量(OI)序列、资金费率\n")
-	sb.WriteString("- 🎯 **筛选标记**：AI500评分 / OI_Top排名（如果有标注）\n\n")
-	sb.WriteString("**分析方法**（完全由你自主决定）：\n")
-	sb.WriteString("- 自由运用序列数据，你可以做但不限于趋势分析、形态识别、支撑阻力、技术阻力位、斐波那契、波动带计算\n")
-	sb.WriteString("- 多维度交叉验证（价格+量+OI+指标+序列形态）\n")
-	sb.WriteString("- 用你认为最有效的方法发现高确定性机会\n")
-	sb.WriteString("- 综合信心度 ≥ 75 才开仓\n\n")
-	sb.WriteString("**避免低质量信号**：\n")
-	sb.WriteString("- 单一维度（只看一个指标）\n")
-	sb.WriteString("- 相互矛盾（涨但量萎缩）\n")
-	sb.WriteString("- 横盘震荡\n")
-	sb.WriteString("- 刚平仓不久（<15分钟）\n\n")
-
-	// === 夏普比率自我进化 ===
-	sb.WriteString("# 🧬 夏普比率自我进化\n\n")
-	sb.WriteString("每次你会收到**夏普比率**作为绩效反馈（周期级别）：\n\n")
-	sb.WriteString("**夏普比率 < -0.5** (持续亏损):\n")
-	sb.WriteString("  → 🛑 停止交易，连续观望至少6个周期（18分钟）\n")
-	sb.WriteString("  → 🔍 深度反思：\n")
-	sb.WriteString("     • 交易频率过高？（每小时>2次就是过度）\n")
-	sb.WriteString("     • 持仓时间过短？（<30分钟就是过早平仓）\n")
-	sb.WriteString("     • 信号强度不足？（信心度<75）\n")
-	sb.WriteString("     • 是否在做空？（单边做多是错误的）\n\n")
-	sb.WriteString("**夏普比率 -0.5 ~ 0** (轻微亏损):\n")
-	sb.WriteString("  → ⚠️ 严格控制：只做信心度>80的交易\n")
-	sb.WriteString("  → 减少交易频率：每小时最多1笔新开仓\n")
-	sb.WriteString("  → 耐心持仓：至少持有30分钟以上\n\n")
-	sb.WriteString("**夏普比率 0 ~ 0.7** (正收益):\n")
-	sb.WriteString("  → ✅ 维持当前策略\n\n")
-	sb.WriteString("**夏普比率 > 0.7** (优异表现):\n")
-	sb.WriteString("  → 🚀 可适度扩大仓位\n\n")
-	sb.WriteString("**关键**: 夏普比率是唯一指标，它会自然惩罚频繁交易和过度进出。\n\n")
-
-	// === 决策流程 ===
-	sb.WriteString("# 📋 决策流程\n\n")
-	sb.WriteString("1. **分析夏普比率**: 当前策略是否有效？需要调整吗？\n")
-	sb.WriteString("2. **评估持仓**: 趋势是否改变？是否该止盈/止损？\n")
-	sb.WriteString("3. **寻找新机会**: 有强信号吗？多空机会？\n")
-	sb.WriteString("4. **输出决策**: 思维链分析 + JSON\n\n")
-
-	// === 输出格式 ===
-	sb.WriteString("# 📤 输出格式\n\n")
-	sb.WriteString("**第一步: 思维链（纯文本）**\n")
-	sb.WriteString("简洁分析你的思考过程\n\n")
-	sb.WriteString("**第二步: JSON决策数组**\n\n")
-	sb.WriteString("```json\n[\n")
-	sb.WriteString(fmt.Sprintf("  {\"symbol\": \"BTCUSDT\", \"action\": \"open_short\", \"leverage\": %d, \"position_size_usd\": %.0f, \"stop_loss\": 97000, \"take_profit\": 91000, \"confidence\": 85, \"risk_usd\": 300, \"reasoning\": \"下跌趋势+MACD死叉\"},\n", btcEthLeverage, accountEquity*5))
-	sb.WriteString("  {\"symbol\": \"ETHUSDT\", \"action\": \"close_long\", \"reasoning\": \"止盈离场\"}\n")
-	sb.WriteString("]\n```\n\n")
-	sb.WriteString("**字段说明**:\n")
-	sb.WriteString("- `action`: open_long | open_short | close_long | close_short | hold | wait\n")
-	sb.WriteString("- `confidence`: 0-100（开仓建议≥75）\n")
-	sb.WriteString("- 开仓时必填: leverage, position_size_usd, stop_loss, take_profit, confidence, risk_usd, reasoning\n\n")
-
-	// === 关键提醒 ===
-	sb.WriteString("---\n\n")
-	sb.WriteString("**记住**: \n")
-	sb.WriteString("- 目标是夏普比率，不是交易频率\n")
-	sb.WriteString("- 做空 = 做多，都是赚钱工具\n")
-	sb.WriteString("- 宁可错过，不做低质量交易\n")
-	sb.WriteString("- 风险回报比1:3是底线\n")
-
-	return sb.String()
-}
+// fetchMarketDataConcurrently 用有限的协程池并发获取各币种的市场数据
+// 请求在周期截止时间到达时取消，失败的币种记录到 ctx.MarketDataErrors
+func fetchMarketDataConcurrently(ctx *Context, symbolSet map[string]bool) map[string]*market.Data {
+	reqCtx, cancel := context.WithCancel(context.Background())
+	if !ctx.Deadline.IsZero() {
+		reqCtx, cancel = context.WithDeadline(context.Background(), ctx.Deadline)
+	}
+	defer cancel()
 
-// buildUserPrompt 构建 User Prompt（动态数据）
-func buildUserPrompt(ctx *Context) string {
-	var sb strings.Builder
-
-	// 系统状态
-	sb.WriteString(fmt.Sprintf("**时间**: %s | **周期**: #%d | **运行**: %d分钟\n\n",
-		ctx.CurrentTime, ctx.CallCount, ctx.RuntimeMinutes))
-
-	// BTC 市场
-	if btcData, hasBTC := ctx.MarketDataMap["BTCUSDT"]; hasBTC {
-		sb.WriteString(fmt.Sprintf("**BTC**: %.2f (1h: %+.2f%%, 4h: %+.2f%%) | MACD: %.4f | RSI: %.2f\n\n",
-			btcData.CurrentPrice, btcData.PriceChange1h, btcData.PriceChange4h,
-			btcData.CurrentMACD, btcData.CurrentRSI7))
-	}
-
-	// 账户
-	sb.WriteString(fmt.Sprintf("**账户**: 净值%.2f | 余额%.2f (%.1f%%) | 盈亏%+.2f%% | 保证金%.1f%% | 持仓%d个\n\n",
-		ctx.Account.TotalEquity,
-		ctx.Account.AvailableBalance,
-		(ctx.Account.AvailableBalance/ctx.Account.TotalEquity)*100,
-		ctx.Account.TotalPnLPct,
-		ctx.Account.MarginUsedPct,
-		ctx.Account.PositionCount))
-
-	// 持仓（完整市场数据）
-	if len(ctx.Positions) > 0 {
-		sb.WriteString("## 当前持仓\n")
-		for i, pos := range ctx.Positions {
-			// 计算持仓时长
-			holdingDuration := ""
-			if pos.UpdateTime > 0 {
-				durationMs := time.Now().UnixMilli() - pos.UpdateTime
-				durationMin := durationMs / (1000 * 60) // 转换为分钟
-				if durationMin < 60 {
-					holdingDuration = fmt.Sprintf(" | 持仓时长%d分钟", durationMin)
+	symbols := make([]string, 0, len(symbolSet))
+	for symbol := range symbolSet {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = make(map[string]*market.Data, len(symbols))
+		jobs    = make(chan string)
+	)
+
+	workers := marketDataWorkers
+	if len(symbols) < workers {
+		workers = len(symbols)
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for symbol := range jobs {
+				data, err := market.GetWithContext(reqCtx, symbol, ctx.MarketOptions)
+
+				mu.Lock()
+				if err != nil {
+					ctx.MarketDataErrors[symbol] = err.Error()
 				} else {
-					durationHour := durationMin / 60
-					durationMinRemainder := durationMin % 60
-					holdingDuration = fmt.Sprintf(" | 持仓时长%d小时%d分钟", durationHour, durationMinRemainder)
+					results[symbol] = data
 				}
+				mu.Unlock()
 			}
-
-			sb.WriteString(fmt.Sprintf("%d. %s %s | 入场价%.4f 当前价%.4f | 盈亏%+.2f%% | 杠杆%dx | 保证金%.0f | 强平价%.4f%s\n\n",
-				i+1, pos.Symbol, strings.ToUpper(pos.Side),
-				pos.EntryPrice, pos.MarkPrice, pos.UnrealizedPnLPct,
-				pos.Leverage, pos.MarginUsed, pos.LiquidationPrice, holdingDuration))
-
-			// 使用FormatMarketData输出完整市场数据
-			if marketData, ok := ctx.MarketDataMap[pos.Symbol]; ok {
-				sb.WriteString(market.Format(marketData))
-				sb.WriteString("\n")
-			}
-		}
-	} else {
-		sb.WriteString("**当前持仓**: 无\n\n")
+		}()
 	}
 
-	// 候选币种（完整市场数据）
-	sb.WriteString(fmt.Sprintf("## 候选币种 (%d个)\n\n", len(ctx.MarketDataMap)))
-	displayedCount := 0
-	for _, coin := range ctx.CandidateCoins {
-		marketData, hasData := ctx.MarketDataMap[coin.Symbol]
-		if !hasData {
-			continue
-		}
-		displayedCount++
-
-		sourceTags := ""
-		if len(coin.Sources) > 1 {
-			sourceTags = " (AI500+OI_Top双重信号)"
-		} else if len(coin.Sources) == 1 && coin.Sources[0] == "oi_top" {
-			sourceTags = " (OI_Top持仓增长)"
-		}
-
-		// 使用FormatMarketData输出完整市场数据
-		sb.WriteString(fmt.Sprintf("### %d. %s%s\n\n", displayedCount, coin.Symbol, sourceTags))
-		sb.WriteString(market.Format(marketData))
-		sb.WriteString("\n")
+	for _, symbol := range symbols {
+		jobs <- symbol
 	}
-	sb.WriteString("\n")
+	close(jobs)
+	wg.Wait()
 
-	// 夏普比率（直接传值，不要复杂格式化）
-	if ctx.Performance != nil {
-		// 直接从interface{}中提取SharpeRatio
-		type PerformanceData struct {
-			SharpeRatio float64 `json:"sharpe_ratio"`
-		}
-		var perfData PerformanceData
-		if jsonData, err := json.Marshal(ctx.Performance); err == nil {
-			if err := json.Unmarshal(jsonData, &perfData); err == nil {
-				sb.WriteString(fmt.Sprintf("## 📊 夏普比率: %.2f\n\n", perfData.SharpeRatio))
-			}
+	for _, symbol := range symbols {
+		if reason, ok := ctx.MarketDataErrors[symbol]; ok {
+			log.Printf("⚠️  %s 获取市场数据失败: %s", symbol, reason)
 		}
 	}
 
-	sb.WriteString("---\n\n")
-	sb.WriteString("现在请分析并输出决策（思维链 + JSON）\n")
+	return results
+}
 
-	return sb.String()
+// calculateMaxCandidates 根据账户状态计算需要分析的候选币种数量
+func calculateMaxCandidates(ctx *Context) int {
+	// 直接返回候选池的全部币种数量
+	// 因为候选池已经在 auto_trader.go 中筛选过了
+	// 固定分析前20个评分最高的币种（来自AI500）
+	return len(ctx.CandidateCoins)
 }
 
 // parseFullDecisionResponse 解析AI的完整决策响应
-func parseFullDecisionResponse(aiResponse string, accountEquity float64, btcEthLeverage, altcoinLeverage int) (*FullDecision, error) {
-	// 1. 提取思维链
-	cotTrace := extractCoTTrace(aiResponse)
+// carried 为上一轮已通过验证的决策，其中本次遗漏的平仓会补回并参与验证
+func parseFullDecisionResponse(aiResponse string, ctx *Context, carried []Decision) (*FullDecision, error) {
+	var (
+		cotTrace  string
+		decisions []Decision
+		malformed []RejectedDecision // 单条格式错误的决策直接拒绝，不影响其他决策
+	)
+	if structured, ok := parseStructuredResponse(aiResponse); ok {
+		// 1-2. 结构化输出：思维链和决策数组是独立字段
+		cotTrace = structured.ChainOfThought
+		decisions, malformed = decodeDecisions(structured.Decisions)
+	} else {
+		// 1. 提取思维链
+		cotTrace = extractCoTTrace(aiResponse)
 
-	// 2. 提取JSON决策列表
-	decisions, err := extractDecisions(aiResponse)
-	if err != nil {
-		return &FullDecision{
-			CoTTrace:  cotTrace,
-			Decisions: []Decision{},
-		}, fmt.Errorf("提取决策失败: %w\n\n=== AI思维链分析 ===\n%s", err, cotTrace)
+		// 2. 提取JSON决策列表（API不支持结构化输出时的兜底解析）
+		var err error
+		decisions, malformed, err = extractDecisions(aiResponse)
+		if err != nil {
+			return &FullDecision{
+				CoTTrace:  cotTrace,
+				Decisions: []Decision{},
+			}, fmt.Errorf("提取决策失败: %w\n\n=== AI思维链分析 ===\n%s", err, cotTrace)
+		}
 	}
 
-	// 3. 验证决策
-	if err := validateDecisions(decisions, accountEquity, btcEthLeverage, altcoinLeverage); err != nil {
-		return &FullDecision{
-			CoTTrace:  cotTrace,
-			Decisions: decisions,
-		}, fmt.Errorf("决策验证失败: %w\n\n=== AI思维链分析 ===\n%s", err, cotTrace)
-	}
+	// 3. 逐条验证决策，只保留通过验证的决策
+	decisions = carryOverReducing(carried, decisions)
+	valid, rejected := validateDecisions(decisions, ctx)
 
 	return &FullDecision{
 		CoTTrace:  cotTrace,
-		Decisions: decisions,
+		Decisions: valid,
+		Rejected:  append(malformed, rejected...),
 	}, nil
 }
 
 // extractCoTTrace 提取思维链分析
 func extractCoTTrace(response string) string {
 	// 查找JSON数组的开始位置
-	jsonStart := strings.Index(response, "[")
+	jsonStart, _ := findDecisionArray(response)
 
 	if jsonStart > 0 {
 		// 思维链是JSON数组之前的内容
@@ -459,17 +416,15 @@ func extractCoTTrace(response string) string {
 }
 
 // extractDecisions 提取JSON决策列表
-func extractDecisions(response string) ([]Decision, error) {
-	// 直接查找JSON数组 - 找第一个完整的JSON数组
-	arrayStart := strings.Index(response, "[")
+// 数组整体无法解析时返回错误；单条决策解析失败时放入拒绝列表
+func extractDecisions(response string) ([]Decision, []RejectedDecision, error) {
+	// 查找决策JSON数组（跳过思维链中的方括号）
+	arrayStart, arrayEnd := findDecisionArray(response)
 	if arrayStart == -1 {
-		return nil, fmt.Errorf("无法找到JSON数组起始")
+		return nil, nil, fmt.Errorf("无法找到JSON数组起始")
 	}
-
-	// 从 [ 开始，匹配括号找到对应的 ]
-	arrayEnd := findMatchingBracket(response, arrayStart)
 	if arrayEnd == -1 {
-		return nil, fmt.Errorf("无法找到JSON数组结束")
+		return nil, nil, fmt.Errorf("无法找到JSON数组结束")
 	}
 
 	jsonContent := strings.TrimSpace(response[arrayStart : arrayEnd+1])
@@ -480,13 +435,32 @@ func extractDecisions(response string) ([]Decision, error) {
 	// 使用简单的字符串扫描而不是正则表达式
 	jsonContent = fixMissingQuotes(jsonContent)
 
-	// 解析JSON
-	var decisions []Decision
-	if err := json.Unmarshal([]byte(jsonContent), &decisions); err != nil {
-		return nil, fmt.Errorf("JSON解析失败: %w\nJSON内容: %s", err, jsonContent)
+	// 解析JSON（先拆成单条，逐条解析）
+	var items []json.RawMessage
+	if err := json.Unmarshal([]byte(jsonContent), &items); err != nil {
+		return nil, nil, fmt.Errorf("JSON解析失败: %w\nJSON内容: %s", err, jsonContent)
 	}
 
-	return decisions, nil
+	decisions, malformed := decodeDecisions(items)
+	return decisions, malformed, nil
+}
+
+// decodeDecisions 逐条解析决策（解析失败的放入拒绝列表）
+func decodeDecisions(items []json.RawMessage) ([]Decision, []RejectedDecision) {
+	var decisions []Decision
+	var malformed []RejectedDecision
+	for i, item := range items {
+		var d Decision
+		if err := json.Unmarshal(item, &d); err != nil {
+			malformed = append(malformed, RejectedDecision{
+				Decision: d,
+				Reason:   fmt.Sprintf("决策 #%d JSON解析失败: %v（%s）", i+1, err, item),
+			})
+			continue
+		}
+		decisions = append(decisions, d)
+	}
+	return decisions, malformed
 }
 
 // fixMissingQuotes 替换中文引号为英文引号（避免输入法自动转换）
@@ -498,25 +472,122 @@ func fixMissingQuotes(jsonStr string) string {
 	return jsonStr
 }
 
-// validateDecisions 验证所有决策（需要账户信息和杠杆配置）
-func validateDecisions(decisions []Decision, accountEquity float64, btcEthLeverage, altcoinLeverage int) error {
-	for i, decision := range decisions {
-		if err := validateDecision(&decision, accountEquity, btcEthLeverage, altcoinLeverage); err != nil {
-			return fmt.Errorf("决策 #%d 验证失败: %w", i+1, err)
+// validateDecisions 逐条独立验证决策（需要账户信息、杠杆配置和市场数据）
+// 返回通过验证的决策和被拒绝的决策；平仓等不增加风险的决策只校验自身，不受同批其他决策影响
+func validateDecisions(decisions []Decision, ctx *Context) ([]Decision, []RejectedDecision) {
+	reasons := make([]string, len(decisions))
+	var reducing []Decision
+	for i := range decisions {
+		if err := validateDecision(&decisions[i], ctx); err != nil {
+			reasons[i] = err.Error()
+		} else if !isOpenAction(decisions[i].Action) {
+			reducing = append(reducing, decisions[i])
 		}
 	}
-	return nil
+
+	// 持仓数、保证金和同方向高相关持仓上限按批次累计（通过验证的平仓释放的名额可用于开仓）
+	budget := newRiskBudget(ctx, reducing)
+
+	var valid []Decision
+	var rejected []RejectedDecision
+	for i, d := range decisions {
+		if reasons[i] == "" && isOpenAction(d.Action) {
+			if err := budget.reserve(&d); err != nil {
+				reasons[i] = err.Error()
+			}
+		}
+		if reasons[i] != "" {
+			log.Printf("  ⛔ %s %s 被拒绝: %s", d.Symbol, d.Action, reasons[i])
+			rejected = append(rejected, RejectedDecision{Decision: d, Reason: reasons[i]})
+			continue
+		}
+		valid = append(valid, d)
+	}
+	return valid, rejected
 }
 
-// findMatchingBracket 查找匹配的右括号
+// isOpenAction 是否为开仓（增加风险）操作
+func isOpenAction(action string) bool {
+	return action == "open_long" || action == "open_short"
+}
+
+// findDecisionArray 查找决策JSON数组的起止位置
+// 思维链中可能出现方括号（如"[支撑位]"），因此取最后一个能解析为对象数组的 [...]；
+// 都不能解析时返回第一个 [ 的位置（由调用方报告解析错误）
+func findDecisionArray(response string) (int, int) {
+	firstStart, firstEnd := -1, -1
+	bestStart, bestEnd := -1, -1
+	emptyStart, emptyEnd := -1, -1
+
+	for i := 0; i < len(response); {
+		offset := strings.IndexByte(response[i:], '[')
+		if offset == -1 {
+			break
+		}
+		start := i + offset
+		end := findMatchingBracket(response, start)
+		if firstStart == -1 {
+			firstStart, firstEnd = start, end
+		}
+
+		if end != -1 {
+			var items []json.RawMessage
+			content := fixMissingQuotes(strings.TrimSpace(response[start : end+1]))
+			if json.Unmarshal([]byte(content), &items) == nil && allObjects(items) {
+				if len(items) > 0 {
+					// 跳过数组内部（字段值里的方括号不是候选）
+					bestStart, bestEnd = start, end
+					i = end + 1
+					continue
+				}
+				if emptyStart == -1 {
+					emptyStart, emptyEnd = start, end
+				}
+			}
+		}
+		i = start + 1
+	}
+
+	switch {
+	case bestStart != -1:
+		return bestStart, bestEnd
+	case emptyStart != -1:
+		return emptyStart, emptyEnd
+	}
+	return firstStart, firstEnd
+}
+
+// allObjects 数组元素是否都是JSON对象
+func allObjects(items []json.RawMessage) bool {
+	for _, item := range items {
+		if len(item) == 0 || item[0] != '{' {
+			return false
+		}
+	}
+	return true
+}
+
+// findMatchingBracket 查找匹配的右括号（跳过JSON字符串内的方括号，如 reasoning 中的"跌破[前低"）
 func findMatchingBracket(s string, start int) int {
 	if start >= len(s) || s[start] != '[' {
 		return -1
 	}
 
 	depth := 0
+	inString := false
 	for i := start; i < len(s); i++ {
+		if inString {
+			switch s[i] {
+			case '\\':
+				i++ // 跳过转义字符
+			case '"':
+				inString = false
+			}
+			continue
+		}
 		switch s[i] {
+		case '"':
+			inString = true
 		case '[':
 			depth++
 		case ']':
@@ -531,7 +602,11 @@ func findMatchingBracket(s string, start int) int {
 }
 
 // validateDecision 验证单个决策的有效性
-func validateDecision(d *Decision, accountEquity float64, btcEthLeverage, altcoinLeverage int) error {
+func validateDecision(d *Decision, ctx *Context) error {
+	accountEquity := ctx.Account.TotalEquity
+	btcEthLeverage, altcoinLeverage := ctx.BTCETHLeverage, ctx.AltcoinLeverage
+	risk := ctx.Risk.WithDefaults()
+
 	// 验证action
 	validActions := map[string]bool{
 		"open_long":   true,
@@ -545,16 +620,30 @@ func validateDecision(d *Decision, accountEquity float64, btcEthLeverage, altcoi
 	if !validActions[d.Action] {
 		return fmt.Errorf("无效的action: %s", d.Action)
 	}
+	if d.Symbol == "" && d.Action != "hold" && d.Action != "wait" {
+		return fmt.Errorf("%s 缺少symbol", d.Action)
+	}
 
 	// 开仓操作必须提供完整参数
-	if d.Action == "open_long" || d.Action == "open_short" {
+	if isOpenAction(d.Action) {
+		if reason, bad := ctx.DataQualityIssues[d.Symbol]; bad {
+			return fmt.Errorf("%s 行情数据异常，禁止开仓: %s", d.Symbol, reason)
+		}
+		if remaining := ctx.cooldownRemaining(d.Symbol, time.Now()); remaining > 0 {
+			return fmt.Errorf("%s 平仓后冷却中（%d分钟），还需%.0f分钟才能再开仓", d.Symbol, risk.CooldownMinutes, remaining.Minutes())
+		}
+		if risk.MinConfidence > 0 && d.Confidence < risk.MinConfidence {
+			return fmt.Errorf("信心度%d低于开仓下限%d", d.Confidence, risk.MinConfidence)
+		}
+
 		// 根据币种使用配置的杠杆上限
-		maxLeverage := altcoinLeverage          // 山寨币使用配置的杠杆
-		maxPositionValue := accountEquity * 1.5 // 山寨币最多1.5倍账户净值
+		maxLeverage := altcoinLeverage // 山寨币使用配置的杠杆
 		if d.Symbol == "BTCUSDT" || d.Symbol == "ETHUSDT" {
-			maxLeverage = btcEthLeverage          // BTC和ETH使用配置的杠杆
-			maxPositionValue = accountEquity * 10 // BTC/ETH最多10倍账户净值
+			maxLeverage = btcEthLeverage // BTC和ETH使用配置的杠杆
 		}
+		multiple := risk.PositionMultipleFor(d.Symbol)
+		minPositionValue := accountEquity * multiple.Min
+		maxPositionValue := accountEquity * multiple.Max
 
 		if d.Leverage <= 0 || d.Leverage > maxLeverage {
 			return fmt.Errorf("杠杆必须在1-%d之间（%s，当前配置上限%d倍）: %d", maxLeverage, d.Symbol, maxLeverage, d.Leverage)
@@ -562,61 +651,29 @@ func validateDecision(d *Decision, accountEquity float64, btcEthLeverage, altcoi
 		if d.PositionSizeUSD <= 0 {
 			return fmt.Errorf("仓位大小必须大于0: %.2f", d.PositionSizeUSD)
 		}
-		// 验证仓位价值上限（加1%容差以避免浮点数精度问题）
+		// 验证仓位价值范围（加1%容差以避免浮点数精度问题）
 		tolerance := maxPositionValue * 0.01 // 1%容差
 		if d.PositionSizeUSD > maxPositionValue+tolerance {
-			if d.Symbol == "BTCUSDT" || d.Symbol == "ETHUSDT" {
-				return fmt.Errorf("BTC/ETH单币种仓位价值不能超过%.0f USDT（10倍账户净值），实际: %.0f", maxPositionValue, d.PositionSizeUSD)
-			} else {
-				return fmt.Errorf("山寨币单币种仓位价值不能超过%.0f USDT（1.5倍账户净值），实际: %.0f", maxPositionValue, d.PositionSizeUSD)
-			}
+			return fmt.Errorf("%s 单币种仓位价值不能超过%.0f USDT（%g倍账户净值），实际: %.0f", d.Symbol, maxPositionValue, multiple.Max, d.PositionSizeUSD)
+		}
+		if d.PositionSizeUSD < minPositionValue-tolerance {
+			return fmt.Errorf("%s 单币种仓位价值不能低于%.0f USDT（%g倍账户净值），实际: %.0f", d.Symbol, minPositionValue, multiple.Min, d.PositionSizeUSD)
 		}
 		if d.StopLoss <= 0 || d.TakeProfit <= 0 {
 			return fmt.Errorf("止损和止盈必须大于0")
 		}
 
-		// 验证止损止盈的合理性
-		if d.Action == "open_long" {
-			if d.StopLoss >= d.TakeProfit {
-				return fmt.Errorf("做多时止损价必须小于止盈价")
-			}
-		} else {
-			if d.StopLoss <= d.TakeProfit {
-				return fmt.Errorf("做空时止损价必须大于止盈价")
-			}
-		}
-
-		// 验证风险回报比（必须≥1:3）
-		// 计算入场价（假设当前市价）
-		var entryPrice float64
-		if d.Action == "open_long" {
-			// 做多：入场价在止损和止盈之间
-			entryPrice = d.StopLoss + (d.TakeProfit-d.StopLoss)*0.2 // 假设在20%位置入场
-		} else {
-			// 做空：入场价在止损和止盈之间
-			entryPrice = d.StopLoss - (d.StopLoss-d.TakeProfit)*0.2 // 假设在20%位置入场
-		}
-
-		var riskPercent, rewardPercent, riskRewardRatio float64
-		if d.Action == "open_long" {
-			riskPercent = (entryPrice - d.StopLoss) / entryPrice * 100
-			rewardPercent = (d.TakeProfit - entryPrice) / entryPrice * 100
-			if riskPercent > 0 {
-				riskRewardRatio = rewardPercent / riskPercent
-			}
-		} else {
-			riskPercent = (d.StopLoss - entryPrice) / entryPrice * 100
-			rewardPercent = (entryPrice - d.TakeProfit) / entryPrice * 100
-			if riskPercent > 0 {
-				riskRewardRatio = rewardPercent / riskPercent
-			}
+		// 按实时价格校验止损止盈、风险回报比和强平距离
+		entryRisk, err := checkEntryRisk(d, ctx.MarketDataMap[d.Symbol], risk)
+		if err != nil {
+			return err
 		}
 
-		// 硬约束：风险回报比必须≥3.0
-		if riskRewardRatio < 3.0 {
-			return fmt.Errorf("风险回报比过低(%.2f:1)，必须≥3.0:1 [风险:%.2f%% 收益:%.2f%%] [止损:%.2f 止盈:%.2f]",
-				riskRewardRatio, riskPercent, rewardPercent, d.StopLoss, d.TakeProfit)
+		// 市场状态规则
+		if err := checkRegimeRules(d, ctx); err != nil {
+			return err
 		}
+		log.Printf("  📐 %s %s: %s", d.Symbol, d.Action, entryRisk)
 	}
 
 	return nil
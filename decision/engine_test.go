@@ -0,0 +1,75 @@
+package decision
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFindDecisionArray(t *testing.T) {
+	const decisions = `[{"symbol": "BTCUSDT", "action": "wait", "reasoning": "观望"}]`
+
+	tests := []struct {
+		name     string
+		response string
+		want     string // 期望找到的数组（为空表示找不到）
+	}{
+		{"只有决策数组", decisions, decisions},
+		{"思维链中的方括号", "BTC 回踩[支撑位]后企稳，ETH 在[3200, 3300]区间震荡\n\n" + decisions, decisions},
+		{"代码块包裹", "分析完毕。\n```json\n" + decisions + "\n```", decisions},
+		{"思维链在数组之后", decisions + "\n\n以上决策基于[4h]趋势", decisions},
+		{"多个对象数组取最后一个", `示例格式: [{"symbol": "X", "action": "hold"}]` + "\n最终决策:\n" + decisions, decisions},
+		{"reasoning中有不成对的方括号",
+			`[{"symbol": "BTCUSDT", "action": "open_long", "reasoning": "突破区间]上沿，跌破[前低止损"}]`,
+			`[{"symbol": "BTCUSDT", "action": "open_long", "reasoning": "突破区间]上沿，跌破[前低止损"}]`},
+		{"reasoning中有转义引号",
+			`[{"symbol": "BTCUSDT", "action": "wait", "reasoning": "等待\"[确认]\"信号"}]`,
+			`[{"symbol": "BTCUSDT", "action": "wait", "reasoning": "等待\"[确认]\"信号"}]`},
+		{"空数组", "没有合适的机会 []", "[]"},
+		{"非空数组优先于空数组", "候选: []\n" + decisions, decisions},
+		{"都不能解析时返回第一个", "先看[支撑位]再说 [{symbol: BTCUSDT}]", "[支撑位]"},
+		{"没有方括号", "暂无决策", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := findDecisionArray(tt.response)
+			if tt.want == "" {
+				if start != -1 {
+					t.Fatalf("期望找不到, 实际 [%d, %d]", start, end)
+				}
+				return
+			}
+			if start == -1 || end == -1 {
+				t.Fatalf("未找到数组, 期望 %q", tt.want)
+			}
+			if got := tt.response[start : end+1]; got != tt.want {
+				t.Errorf("找到 %q, 期望 %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindMatchingBracket(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int
+	}{
+		{"[]", 1},
+		{"[[1], [2]] x", 9},
+		{`["]"]`, 4},
+		{`["\"]"]`, 6},
+		{"[1, 2", -1},
+		{"x[]", -1},
+	}
+	for _, tt := range tests {
+		if got := findMatchingBracket(tt.s, 0); got != tt.want {
+			t.Errorf("findMatchingBracket(%q) = %d, 期望 %d", tt.s, got, tt.want)
+		}
+	}
+
+	// 从中间位置开始
+	s := `思维链 [{"a": "]"}]`
+	if got := findMatchingBracket(s, strings.IndexByte(s, '[')); got != len(s)-1 {
+		t.Errorf("findMatchingBracket(%q) = %d, 期望 %d", s, got, len(s)-1)
+	}
+}
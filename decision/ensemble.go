@@ -0,0 +1,315 @@
+package decision
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 多模型集成的合并策略
+const (
+	EnsembleUnanimous = "unanimous" // 所有成功返回的模型给出同一决策才执行（平仓过半即可）
+	EnsembleMajority  = "majority"  // 超过半数成功返回的模型给出同一决策才执行
+	EnsembleWeighted  = "weighted"  // 按信心度加权投票，仓位和止损止盈按信心度加权平均
+)
+
+// EnsembleConfig 多模型集成配置
+type EnsembleConfig struct {
+	Policy string        `json:"policy"` // 合并策略: unanimous / majority / weighted
+	Models []ModelConfig `json:"models"`
+}
+
+// Validate 校验集成配置
+func (c *EnsembleConfig) Validate() error {
+	if c.Policy != EnsembleUnanimous && c.Policy != EnsembleMajority && c.Policy != EnsembleWeighted {
+		return fmt.Errorf("ensemble.policy必须是 'unanimous', 'majority' 或 'weighted': %q", c.Policy)
+	}
+	if len(c.Models) < 2 {
+		return fmt.Errorf("ensemble.models至少需要2个模型")
+	}
+	names := make(map[string]bool)
+	for i, m := range c.Models {
+		if err := m.Validate(); err != nil {
+			return fmt.Errorf("ensemble.models[%d]: %w", i, err)
+		}
+		name := m.DisplayName()
+		if names[name] {
+			return fmt.Errorf("ensemble.models[%d]: 名称 '%s' 重复（同一模型配置多次时请设置name）", i, name)
+		}
+		names[name] = true
+	}
+	return nil
+}
+
+// Ensemble 多模型集成（同一份prompt并发发给所有模型，按策略合并决策）
+type Ensemble struct {
+	Policy  string
+	Members []ModelClient
+}
+
+// NewEnsemble 按配置创建各模型的AI客户端
+func NewEnsemble(cfg EnsembleConfig) (*Ensemble, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	ensemble := &Ensemble{Policy: cfg.Policy}
+	for _, m := range cfg.Models {
+		ensemble.Members = append(ensemble.Members, m.NewClient())
+	}
+	return ensemble, nil
+}
+
+// Names 各模型的显示名称
+func (e *Ensemble) Names() []string {
+	names := make([]string, len(e.Members))
+	for i, m := range e.Members {
+		names[i] = m.Name
+	}
+	return names
+}
+
+// ModelOutput 集成中单个模型的输出
+type ModelOutput struct {
+	Name        string             `json:"name"`
+	Response    string             `json:"response"`              // 原始输出
+	CoTTrace    string             `json:"cot_trace"`             // 思维链
+	Decisions   []Decision         `json:"decisions"`             // 通过验证、参与投票的决策
+	Rejected    []RejectedDecision `json:"rejected,omitempty"`    // 未通过验证的决策
+	Corrections []CorrectionRound  `json:"corrections,omitempty"` // 该模型的修正记录
+	Error       string             `json:"error,omitempty"`       // 调用或解析失败的原因（视为弃权）
+}
+
+// EnsembleVote 一个（币种, 操作）的投票结果
+type EnsembleVote struct {
+	Symbol  string   `json:"symbol"`
+	Action  string   `json:"action"`
+	Models  []string `json:"models"`         // 给出该决策的模型
+	Support float64  `json:"support"`        // 得票（weighted策略为信心度之和）
+	Total   float64  `json:"total"`          // 满票（weighted策略为模型数×100）
+	Adopted bool     `json:"adopted"`        // 是否采纳
+	Note    string   `json:"note,omitempty"` // 未采纳的原因
+}
+
+// EnsembleResult 多模型集成的各模型输出和投票结果
+type EnsembleResult struct {
+	Policy string         `json:"policy"`
+	Models []ModelOutput  `json:"models"`
+	Votes  []EnsembleVote `json:"votes"`
+}
+
+// GetEnsembleDecision 把同一份prompt并发发给集成中的所有模型，按策略合并各模型通过验证的决策
+// 调用失败的模型弃权且不计入总票数；合并后的决策重新验证
+func GetEnsembleDecision(ctx *Context, ensemble *Ensemble) (*FullDecision, error) {
+	// 1. 行情数据只获取一次，所有模型看到相同的输入
+	if err := fetchMarketDataForContext(ctx); err != nil {
+		return nil, fmt.Errorf("获取市场数据失败: %w", err)
+	}
+
+	// 2. 并发调用各模型
+	results := make([]*FullDecision, len(ensemble.Members))
+	errs := make([]error, len(ensemble.Members))
+	var wg sync.WaitGroup
+	for i, member := range ensemble.Members {
+		wg.Add(1)
+		go func(i int, member ModelClient) {
+			defer wg.Done()
+			results[i], errs[i] = decide(ctx, member.Client, "")
+		}(i, member)
+	}
+	wg.Wait()
+
+	result := &EnsembleResult{Policy: ensemble.Policy}
+	full := &FullDecision{Timestamp: time.Now(), Ensemble: result}
+	var cot strings.Builder
+	succeeded := 0
+	for i, member := range ensemble.Members {
+		output := ModelOutput{Name: member.Name, Decisions: []Decision{}}
+		if d := results[i]; d != nil {
+			output.Response = d.RawResponse
+			output.CoTTrace = d.CoTTrace
+			output.Decisions = d.Decisions
+			output.Rejected = d.Rejected
+			output.Corrections = d.Corrections
+			if full.UserPrompt == "" {
+				full.UserPrompt = d.UserPrompt
+			}
+		}
+		if errs[i] != nil {
+			output.Error = errs[i].Error()
+			output.Decisions = []Decision{} // 解析失败的模型弃权
+			log.Printf("  🧠 [%s] 失败（弃权）: %v", member.Name, errs[i])
+		} else {
+			succeeded++
+			log.Printf("  🧠 [%s] %d个决策通过验证，%d个被拒绝", member.Name, len(output.Decisions), len(output.Rejected))
+		}
+		result.Models = append(result.Models, output)
+		if output.CoTTrace != "" {
+			fmt.Fprintf(&cot, "=== %s ===\n%s\n\n", member.Name, output.CoTTrace)
+		}
+	}
+	full.CoTTrace = strings.TrimSpace(cot.String())
+	if succeeded == 0 {
+		full.Decisions = []Decision{}
+		return full, fmt.Errorf("集成中的%d个模型均未返回可用决策", len(ensemble.Members))
+	}
+
+	// 3. 按策略合并，合并后的参数（平均仓位和止损止盈）重新验证
+	merged, votes := mergeDecisions(ensemble.Policy, result.Models)
+	result.Votes = votes
+	for _, v := range votes {
+		mark := "✓"
+		if !v.Adopted {
+			mark = "✗"
+		}
+		log.Printf("  🗳  %s %s %s: %.0f/%.0f [%s] %s", mark, v.Symbol, v.Action, v.Support, v.Total, strings.Join(v.Models, ", "), v.Note)
+	}
+	full.Decisions, full.Rejected = validateDecisions(merged, ctx)
+	return full, nil
+}
+
+// proposal 一个模型对某（币种, 操作）给出的决策
+type proposal struct {
+	model    string
+	decision Decision
+}
+
+// voteWeight weighted策略下的票权：信心度（未给出信心度的平仓按100计）
+func voteWeight(d Decision) float64 {
+	if d.Confidence <= 0 {
+		return 100
+	}
+	return float64(d.Confidence)
+}
+
+// mergeDecisions 按（币种, 操作）统计各模型的决策，采纳达到票数要求的决策并合并参数
+// 总票数只计算成功返回的模型；平仓（降低风险）只需过半成功返回的模型提出即采纳，不受 unanimous/weighted 的门槛限制
+// hold/wait 不参与投票；同一币种多空开仓同时达标时保留得票高的一方，平票则都不采纳
+func mergeDecisions(policy string, outputs []ModelOutput) ([]Decision, []EnsembleVote) {
+	answered := 0
+	for _, output := range outputs {
+		if output.Error == "" {
+			answered++
+		}
+	}
+	n := float64(answered)
+
+	var keys []string
+	groups := make(map[string][]proposal)
+	for _, output := range outputs {
+		seen := make(map[string]bool)
+		for _, d := range output.Decisions {
+			if d.Action == "hold" || d.Action == "wait" {
+				continue
+			}
+			key := d.Symbol + "_" + d.Action
+			if seen[key] {
+				continue // 同一模型重复的决策只计一票
+			}
+			seen[key] = true
+			if _, ok := groups[key]; !ok {
+				keys = append(keys, key)
+			}
+			groups[key] = append(groups[key], proposal{model: output.Name, decision: d})
+		}
+	}
+
+	votes := make([]EnsembleVote, len(keys))
+	for i, key := range keys {
+		proposals := groups[key]
+		v := EnsembleVote{Symbol: proposals[0].decision.Symbol, Action: proposals[0].decision.Action}
+		count := float64(len(proposals))
+		for _, p := range proposals {
+			v.Models = append(v.Models, p.model)
+			if policy == EnsembleWeighted {
+				v.Support += voteWeight(p.decision)
+			} else {
+				v.Support++
+			}
+		}
+		switch policy {
+		case EnsembleUnanimous:
+			v.Total = n
+			v.Adopted = v.Support == n
+		case EnsembleMajority:
+			v.Total = n
+			v.Adopted = v.Support > n/2
+		case EnsembleWeighted:
+			v.Total = n * 100
+			v.Adopted = v.Support > v.Total/2
+		}
+		if !v.Adopted && !isOpenAction(v.Action) && count > n/2 {
+			v.Adopted = true
+			v.Note = "平仓过半即采纳"
+		}
+		if !v.Adopted {
+			v.Note = "票数不足"
+		}
+		votes[i] = v
+	}
+
+	// 同一币种多空开仓冲突（先判定再修改，平票时双方都不采纳）
+	conflict := make([]bool, len(votes))
+	for i := range votes {
+		if !votes[i].Adopted || !isOpenAction(votes[i].Action) {
+			continue
+		}
+		for j := range votes {
+			if votes[j].Adopted && votes[j].Symbol == votes[i].Symbol && isOpenAction(votes[j].Action) &&
+				votes[j].Action != votes[i].Action && votes[j].Support >= votes[i].Support {
+				conflict[i] = true
+			}
+		}
+	}
+	for i := range votes {
+		if conflict[i] {
+			votes[i].Adopted = false
+			votes[i].Note = "多空冲突"
+		}
+	}
+
+	var merged []Decision
+	for i, key := range keys {
+		if votes[i].Adopted {
+			merged = append(merged, mergeProposals(policy, groups[key], votes[i]))
+		}
+	}
+	return merged, votes
+}
+
+// mergeProposals 合并同一（币种, 操作）的多个决策
+// 仓位、止损、止盈和风险金额取平均（weighted策略按信心度加权），杠杆取最小值，信心度取平均
+func mergeProposals(policy string, proposals []proposal, vote EnsembleVote) Decision {
+	merged := Decision{Symbol: vote.Symbol, Action: vote.Action}
+
+	var totalWeight, confidence float64
+	var reasons []string
+	for _, p := range proposals {
+		d := p.decision
+		weight := 1.0
+		if policy == EnsembleWeighted {
+			weight = voteWeight(d)
+		}
+		totalWeight += weight
+		merged.PositionSizeUSD += d.PositionSizeUSD * weight
+		merged.StopLoss += d.StopLoss * weight
+		merged.TakeProfit += d.TakeProfit * weight
+		merged.RiskUSD += d.RiskUSD * weight
+		confidence += float64(d.Confidence)
+		if merged.Leverage == 0 || (d.Leverage > 0 && d.Leverage < merged.Leverage) {
+			merged.Leverage = d.Leverage
+		}
+		if d.Reasoning != "" {
+			reasons = append(reasons, fmt.Sprintf("%s: %s", p.model, d.Reasoning))
+		}
+	}
+	merged.PositionSizeUSD /= totalWeight
+	merged.StopLoss /= totalWeight
+	merged.TakeProfit /= totalWeight
+	merged.RiskUSD /= totalWeight
+	merged.Confidence = int(math.Round(confidence / float64(len(proposals))))
+	merged.Reasoning = strings.TrimSpace(fmt.Sprintf("[%s %.0f/%.0f] %s", policy, vote.Support, vote.Total, strings.Join(reasons, " | ")))
+	return merged
+}
@@ -0,0 +1,188 @@
+package decision
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// ensembleOutput 构造单个模型的输出
+func ensembleOutput(name string, decisions ...Decision) ModelOutput {
+	return ModelOutput{Name: name, Decisions: decisions}
+}
+
+func openLong(confidence int) Decision {
+	return Decision{Symbol: "BTCUSDT", Action: "open_long", Leverage: 5, PositionSizeUSD: 1000, StopLoss: 90, TakeProfit: 120, Confidence: confidence}
+}
+
+func openShort(confidence int) Decision {
+	return Decision{Symbol: "BTCUSDT", Action: "open_short", Leverage: 5, PositionSizeUSD: 1000, StopLoss: 110, TakeProfit: 80, Confidence: confidence}
+}
+
+func closeLong() Decision {
+	return Decision{Symbol: "BTCUSDT", Action: "close_long", Confidence: 0}
+}
+
+func TestMergeDecisionsThresholds(t *testing.T) {
+	wait := Decision{Symbol: "ALL", Action: "wait"}
+
+	tests := []struct {
+		name        string
+		policy      string
+		outputs     []ModelOutput
+		wantAdopted bool
+		wantSupport float64
+		wantTotal   float64
+	}{
+		{"unanimous 3/3", EnsembleUnanimous, []ModelOutput{
+			ensembleOutput("a", openLong(80)), ensembleOutput("b", openLong(80)), ensembleOutput("c", openLong(80)),
+		}, true, 3, 3},
+		{"unanimous 2/3", EnsembleUnanimous, []ModelOutput{
+			ensembleOutput("a", openLong(80)), ensembleOutput("b", openLong(80)), ensembleOutput("c", wait),
+		}, false, 2, 3},
+		{"unanimous 失败的模型不计入总数", EnsembleUnanimous, []ModelOutput{
+			ensembleOutput("a", openLong(80)), ensembleOutput("b", openLong(80)), {Name: "c", Error: "timeout"},
+		}, true, 2, 2},
+		{"majority 三个模型失败两个", EnsembleMajority, []ModelOutput{
+			ensembleOutput("a", openLong(80)), {Name: "b", Error: "timeout"}, {Name: "c", Error: "解析失败"},
+		}, true, 1, 1},
+		{"unanimous 平仓过半即采纳", EnsembleUnanimous, []ModelOutput{
+			ensembleOutput("a", closeLong()), ensembleOutput("b", closeLong()), ensembleOutput("c", wait),
+		}, true, 2, 3},
+		{"unanimous 模型失败时平仓仍可采纳", EnsembleUnanimous, []ModelOutput{
+			ensembleOutput("a", closeLong()), ensembleOutput("b", closeLong()), ensembleOutput("c", wait), {Name: "d", Error: "timeout"},
+		}, true, 2, 3},
+		{"成功返回的模型中平仓未过半", EnsembleUnanimous, []ModelOutput{
+			ensembleOutput("a", closeLong()), ensembleOutput("b", wait), {Name: "c", Error: "timeout"},
+		}, false, 1, 2},
+		{"weighted 平仓过半即采纳", EnsembleWeighted, []ModelOutput{
+			ensembleOutput("a", closeLong()), ensembleOutput("b", Decision{Symbol: "BTCUSDT", Action: "close_long", Confidence: 20}), ensembleOutput("c", wait),
+		}, true, 120, 300},
+		{"majority 2/3", EnsembleMajority, []ModelOutput{
+			ensembleOutput("a", openLong(80)), ensembleOutput("b", openLong(80)), ensembleOutput("c", wait),
+		}, true, 2, 3},
+		{"majority 2/4 不过半", EnsembleMajority, []ModelOutput{
+			ensembleOutput("a", openLong(80)), ensembleOutput("b", openLong(80)), ensembleOutput("c", wait), ensembleOutput("d", wait),
+		}, false, 2, 4},
+		{"majority 同一模型重复只计一票", EnsembleMajority, []ModelOutput{
+			ensembleOutput("a", openLong(80), openLong(90)), ensembleOutput("b", wait), ensembleOutput("c", wait),
+		}, false, 1, 3},
+		{"weighted 170/300", EnsembleWeighted, []ModelOutput{
+			ensembleOutput("a", openLong(90)), ensembleOutput("b", openLong(80)), ensembleOutput("c", wait),
+		}, true, 170, 300},
+		{"weighted 150/300 刚好一半不采纳", EnsembleWeighted, []ModelOutput{
+			ensembleOutput("a", openLong(75)), ensembleOutput("b", openLong(75)), ensembleOutput("c", wait),
+		}, false, 150, 300},
+		{"weighted 未给信心度按100计", EnsembleWeighted, []ModelOutput{
+			ensembleOutput("a", openLong(0)), ensembleOutput("b", openLong(60)), ensembleOutput("c", wait),
+		}, true, 160, 300},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, votes := mergeDecisions(tt.policy, tt.outputs)
+			if len(votes) != 1 {
+				t.Fatalf("votes = %d, 期望 1（hold/wait 不参与投票）", len(votes))
+			}
+			v := votes[0]
+			if v.Support != tt.wantSupport || v.Total != tt.wantTotal {
+				t.Errorf("support = %.0f/%.0f, 期望 %.0f/%.0f", v.Support, v.Total, tt.wantSupport, tt.wantTotal)
+			}
+			if v.Adopted != tt.wantAdopted {
+				t.Errorf("adopted = %v, 期望 %v", v.Adopted, tt.wantAdopted)
+			}
+			if got := len(merged) == 1; got != tt.wantAdopted {
+				t.Errorf("合并结果 %d 条, adopted = %v", len(merged), tt.wantAdopted)
+			}
+		})
+	}
+}
+
+func TestMergeDecisionsConflict(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     string
+		outputs    []ModelOutput
+		wantAction string // 为空表示多空都不采纳
+	}{
+		{"平票时双方都不采纳", EnsembleMajority, []ModelOutput{
+			ensembleOutput("a", openLong(80), openShort(80)),
+			ensembleOutput("b", openLong(80), openShort(80)),
+			ensembleOutput("c"),
+		}, ""},
+		{"保留得票高的一方", EnsembleMajority, []ModelOutput{
+			ensembleOutput("a", openLong(80), openShort(80)),
+			ensembleOutput("b", openLong(80), openShort(80)),
+			ensembleOutput("c", openLong(80)),
+		}, "open_long"},
+		{"weighted 按信心度之和比较", EnsembleWeighted, []ModelOutput{
+			ensembleOutput("a", openLong(55), openShort(90)),
+			ensembleOutput("b", openLong(55), openShort(90)),
+			ensembleOutput("c", openLong(55)),
+		}, "open_short"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, votes := mergeDecisions(tt.policy, tt.outputs)
+			switch {
+			case tt.wantAction == "" && len(merged) != 0:
+				t.Fatalf("期望都不采纳, 实际采纳 %+v", merged)
+			case tt.wantAction != "" && (len(merged) != 1 || merged[0].Action != tt.wantAction):
+				t.Fatalf("期望只采纳 %s, 实际 %+v", tt.wantAction, merged)
+			}
+			for _, v := range votes {
+				if !v.Adopted && v.Action != tt.wantAction && v.Note != "多空冲突" {
+					t.Errorf("%s 未采纳的原因 = %q, 期望 多空冲突", v.Action, v.Note)
+				}
+			}
+		})
+	}
+}
+
+func TestMergeProposals(t *testing.T) {
+	proposals := []proposal{
+		{model: "a", decision: Decision{Symbol: "BTCUSDT", Action: "open_long", Leverage: 5, PositionSizeUSD: 6000, StopLoss: 90, TakeProfit: 130, RiskUSD: 60, Confidence: 90, Reasoning: "突破"}},
+		{model: "b", decision: Decision{Symbol: "BTCUSDT", Action: "open_long", Leverage: 3, PositionSizeUSD: 8000, StopLoss: 94, TakeProfit: 120, RiskUSD: 80, Confidence: 60}},
+	}
+
+	tests := []struct {
+		name                         string
+		policy                       string
+		wantSize, wantSL, wantTP     float64
+		wantRisk                     float64
+		wantLeverage, wantConfidence int
+	}{
+		{"majority 等权平均", EnsembleMajority, 7000, 92, 125, 70, 3, 75},
+		{"weighted 按信心度加权", EnsembleWeighted, 6800, 91.6, 126, 68, 3, 75},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vote := EnsembleVote{Symbol: "BTCUSDT", Action: "open_long", Support: 2, Total: 2}
+			d := mergeProposals(tt.policy, proposals, vote)
+			for _, c := range []struct {
+				field     string
+				got, want float64
+			}{
+				{"position_size_usd", d.PositionSizeUSD, tt.wantSize},
+				{"stop_loss", d.StopLoss, tt.wantSL},
+				{"take_profit", d.TakeProfit, tt.wantTP},
+				{"risk_usd", d.RiskUSD, tt.wantRisk},
+			} {
+				if math.Abs(c.got-c.want) > 1e-9 {
+					t.Errorf("%s = %v, 期望 %v", c.field, c.got, c.want)
+				}
+			}
+			if d.Leverage != tt.wantLeverage {
+				t.Errorf("leverage = %d, 期望取最小值 %d", d.Leverage, tt.wantLeverage)
+			}
+			if d.Confidence != tt.wantConfidence {
+				t.Errorf("confidence = %d, 期望 %d", d.Confidence, tt.wantConfidence)
+			}
+			if !strings.HasPrefix(d.Reasoning, "["+tt.policy+" 2/2] a: 突破") || strings.Contains(d.Reasoning, "b:") {
+				t.Errorf("reasoning = %q", d.Reasoning)
+			}
+		})
+	}
+}
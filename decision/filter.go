@@ -0,0 +1,189 @@
+package decision
+
+import (
+	"fmt"
+	"math"
+	"nofx/market"
+	"path"
+	"strings"
+	"time"
+)
+
+// FilterConfig 候选币种过滤器配置（按配置顺序依次执行，任一过滤器不通过即排除）
+type FilterConfig struct {
+	Type     string   `json:"type"`               // 过滤器类型（见 filterFactories）
+	Value    float64  `json:"value,omitempty"`    // 阈值
+	Patterns []string `json:"patterns,omitempty"` // 黑/白名单模式（通配符，如 "1000*"、"*DOGE*"）
+}
+
+// DefaultFilters 未配置过滤器时使用：持仓价值低于15M USD的币种不做
+var DefaultFilters = []FilterConfig{
+	{Type: "min_oi_value", Value: 15_000_000},
+}
+
+// CandidateFilter 候选币种过滤器
+// 数据源未提供过滤所需的数据时视为通过（不因数据缺失误杀币种）
+type CandidateFilter interface {
+	Name() string
+
+	// Check 返回空字符串表示通过，否则返回排除原因
+	Check(symbol string, data *market.Data, now time.Time) string
+}
+
+type filterFactory func(cfg FilterConfig) (CandidateFilter, error)
+
+var filterFactories = map[string]filterFactory{
+	"min_oi_value": thresholdFilter("持仓价值(USD)", false, func(data *market.Data, now time.Time) (float64, bool) {
+		if data.OpenInterest == nil || data.OpenInterest.Latest <= 0 {
+			return 0, false
+		}
+		return data.OpenInterest.Value, true
+	}),
+	"min_quote_volume_24h": thresholdFilter("24h成交额(USD)", false, func(data *market.Data, now time.Time) (float64, bool) {
+		return data.QuoteVolume24h, data.QuoteVolume24h > 0
+	}),
+	"max_spread_bps": thresholdFilter("买卖价差(bps)", true, func(data *market.Data, now time.Time) (float64, bool) {
+		if data.Depth == nil {
+			return 0, false
+		}
+		return data.Depth.SpreadBps, true
+	}),
+	"min_listing_age_days": thresholdFilter("上线天数", false, func(data *market.Data, now time.Time) (float64, bool) {
+		// 零值表示K线覆盖的时间内一直有数据，上线时间早于该范围
+		if data.ListedSince.IsZero() {
+			return 0, false
+		}
+		return now.Sub(data.ListedSince).Hours() / 24, true
+	}),
+	"max_funding_rate_pct": thresholdFilter("资金费率绝对值(%)", true, func(data *market.Data, now time.Time) (float64, bool) {
+		return math.Abs(data.FundingRate) * 100, true
+	}),
+	"blacklist": func(cfg FilterConfig) (CandidateFilter, error) {
+		return newPatternFilter(cfg, false)
+	},
+	"whitelist": func(cfg FilterConfig) (CandidateFilter, error) {
+		return newPatternFilter(cfg, true)
+	},
+}
+
+// NewFilterChain 按配置创建过滤链（为空时使用 DefaultFilters）
+func NewFilterChain(configs []FilterConfig) ([]CandidateFilter, error) {
+	if len(configs) == 0 {
+		configs = DefaultFilters
+	}
+
+	chain := make([]CandidateFilter, 0, len(configs))
+	for i, cfg := range configs {
+		factory, ok := filterFactories[cfg.Type]
+		if !ok {
+			return nil, fmt.Errorf("filters[%d]: 不支持的过滤器类型 %q", i, cfg.Type)
+		}
+		filter, err := factory(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("filters[%d] %s: %w", i, cfg.Type, err)
+		}
+		chain = append(chain, filter)
+	}
+	return chain, nil
+}
+
+// ValidateFilters 校验过滤器配置
+func ValidateFilters(configs []FilterConfig) error {
+	_, err := NewFilterChain(configs)
+	return err
+}
+
+// applyFilters 依次执行过滤链，返回第一个不通过的过滤器名称和原因
+func applyFilters(chain []CandidateFilter, symbol string, data *market.Data, now time.Time) (string, string) {
+	for _, filter := range chain {
+		if reason := filter.Check(symbol, data, now); reason != "" {
+			return filter.Name(), reason
+		}
+	}
+	return "", ""
+}
+
+// valueFilter 数值阈值过滤器
+type valueFilter struct {
+	name   string
+	label  string
+	limit  float64
+	upper  bool // true表示超过上限排除，false表示低于下限排除
+	metric func(data *market.Data, now time.Time) (float64, bool)
+}
+
+// thresholdFilter 创建数值阈值过滤器工厂（metric返回false表示数据缺失）
+func thresholdFilter(label string, upper bool, metric func(data *market.Data, now time.Time) (float64, bool)) filterFactory {
+	return func(cfg FilterConfig) (CandidateFilter, error) {
+		if cfg.Value <= 0 {
+			return nil, fmt.Errorf("value必须大于0")
+		}
+		return &valueFilter{name: cfg.Type, label: label, limit: cfg.Value, upper: upper, metric: metric}, nil
+	}
+}
+
+func (f *valueFilter) Name() string { return f.name }
+
+func (f *valueFilter) Check(symbol string, data *market.Data, now time.Time) string {
+	value, ok := f.metric(data, now)
+	if !ok {
+		return ""
+	}
+	if f.upper && value > f.limit {
+		return fmt.Sprintf("%s %s > %s", f.label, formatFilterValue(value), formatFilterValue(f.limit))
+	}
+	if !f.upper && value < f.limit {
+		return fmt.Sprintf("%s %s < %s", f.label, formatFilterValue(value), formatFilterValue(f.limit))
+	}
+	return ""
+}
+
+// formatFilterValue 大数值以M为单位显示
+func formatFilterValue(v float64) string {
+	if math.Abs(v) >= 1_000_000 {
+		return fmt.Sprintf("%.2fM", v/1_000_000)
+	}
+	return fmt.Sprintf("%.4g", v)
+}
+
+// symbolPatternFilter 黑/白名单过滤器
+type symbolPatternFilter struct {
+	patterns []string
+	allow    bool // true为白名单（只保留匹配的币种），false为黑名单
+}
+
+func newPatternFilter(cfg FilterConfig, allow bool) (CandidateFilter, error) {
+	if len(cfg.Patterns) == 0 {
+		return nil, fmt.Errorf("patterns不能为空")
+	}
+	patterns := make([]string, len(cfg.Patterns))
+	for i, p := range cfg.Patterns {
+		patterns[i] = strings.ToUpper(p)
+		if _, err := path.Match(patterns[i], ""); err != nil {
+			return nil, fmt.Errorf("无效的模式 %q: %w", p, err)
+		}
+	}
+	return &symbolPatternFilter{patterns: patterns, allow: allow}, nil
+}
+
+func (f *symbolPatternFilter) Name() string {
+	if f.allow {
+		return "whitelist"
+	}
+	return "blacklist"
+}
+
+func (f *symbolPatternFilter) Check(symbol string, data *market.Data, now time.Time) string {
+	for _, p := range f.patterns {
+		if ok, _ := path.Match(p, symbol); ok {
+			if f.allow {
+				return ""
+			}
+			return fmt.Sprintf("匹配黑名单 %s", p)
+		}
+	}
+	if f.allow {
+		return "不在白名单中"
+	}
+	return ""
+}
@@ -0,0 +1,185 @@
+package decision
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MemoryConfig 决策记忆配置：最近的决策、平仓结果、币种胜率和冷却中的币种渲染进 User Prompt
+// 各部分数量为0时不展示该部分；decisions/trades/symbols 都未配置时使用默认值
+type MemoryConfig struct {
+	Decisions int `json:"decisions,omitempty"`  // 最近N条开平仓决策（默认5）
+	Trades    int `json:"trades,omitempty"`     // 最近N笔平仓交易（默认5，历史表现最多保留10笔）
+	Symbols   int `json:"symbols,omitempty"`    // 胜率统计展示的币种数（按交易次数，默认8）
+	MaxTokens int `json:"max_tokens,omitempty"` // 记忆部分的token预算（默认600，超出时轮流删减最旧的条目）
+}
+
+// DefaultMemoryConfig 默认记忆配置
+var DefaultMemoryConfig = MemoryConfig{
+	Decisions: 5,
+	Trades:    5,
+	Symbols:   8,
+	MaxTokens: 600,
+}
+
+// memoryReasoningRunes 每条决策理由保留的最大字符数
+const memoryReasoningRunes = 60
+
+// WithDefaults 填充默认值
+// decisions/trades/symbols 都为0时三部分都使用默认数量，否则为0的部分不展示；max_tokens为0时使用默认预算
+func (c MemoryConfig) WithDefaults() MemoryConfig {
+	if c.Decisions == 0 && c.Trades == 0 && c.Symbols == 0 {
+		c.Decisions = DefaultMemoryConfig.Decisions
+		c.Trades = DefaultMemoryConfig.Trades
+		c.Symbols = DefaultMemoryConfig.Symbols
+	}
+	if c.MaxTokens == 0 {
+		c.MaxTokens = DefaultMemoryConfig.MaxTokens
+	}
+	return c
+}
+
+// Validate 校验记忆配置
+func (c MemoryConfig) Validate() error {
+	if c.Decisions < 0 || c.Trades < 0 || c.Symbols < 0 {
+		return fmt.Errorf("memory.decisions/trades/symbols不能为负数")
+	}
+	if c.MaxTokens < 0 {
+		return fmt.Errorf("memory.max_tokens不能为负数")
+	}
+	return nil
+}
+
+// Memory 决策记忆（由trader从决策日志和历史表现整理，各列表按时间从旧到新）
+type Memory struct {
+	Decisions []MemoryDecision
+	Trades    []MemoryTrade
+	Symbols   []MemorySymbol // 按交易次数排序
+	MaxTokens int            // token预算（0表示不限制）
+}
+
+// MemoryDecision 一条历史决策
+type MemoryDecision struct {
+	Time      time.Time
+	Symbol    string
+	Action    string
+	Reasoning string
+	Executed  bool   // 是否执行成功
+	Error     string // 执行失败的原因
+}
+
+// MemoryTrade 一笔已平仓交易
+type MemoryTrade struct {
+	Symbol    string
+	Side      string
+	PnL       float64 // 盈亏（USDT）
+	PnLPct    float64 // 盈亏百分比（相对保证金）
+	Hold      time.Duration
+	CloseTime time.Time
+	StopLoss  bool // 是否止损离场
+}
+
+// MemorySymbol 单个币种的历史表现
+type MemorySymbol struct {
+	Symbol   string
+	Trades   int
+	Wins     int
+	WinRate  float64 // 胜率（百分比）
+	TotalPnL float64
+}
+
+// formatMemory 渲染记忆部分（memory为空时返回空字符串）
+// 超出token预算时轮流从条目最多的列表中删除最旧（币种统计为交易最少）的条目，冷却中的币种始终保留
+func formatMemory(memory *Memory, cooldowns []string) string {
+	if memory == nil {
+		return ""
+	}
+	m := *memory
+	text := m.render(cooldowns)
+	for m.MaxTokens > 0 && estimateTokens(text) > m.MaxTokens {
+		switch longest := max(len(m.Decisions), len(m.Trades), len(m.Symbols)); {
+		case longest == 0:
+			return text
+		case len(m.Symbols) == longest:
+			m.Symbols = m.Symbols[:len(m.Symbols)-1]
+		case len(m.Trades) == longest:
+			m.Trades = m.Trades[1:]
+		default:
+			m.Decisions = m.Decisions[1:]
+		}
+		text = m.render(cooldowns)
+	}
+	return text
+}
+
+func (m *Memory) render(cooldowns []string) string {
+	var sb strings.Builder
+	if len(m.Decisions) > 0 {
+		sb.WriteString("**最近决策**:\n")
+		for _, d := range m.Decisions {
+			status := "✓"
+			if !d.Executed {
+				status = "✗"
+				if d.Error != "" {
+					status += "(" + truncateRunes(d.Error, 30) + ")"
+				}
+			}
+			fmt.Fprintf(&sb, "- %s %s %s %s: %s\n", d.Time.Format("01-02 15:04"), d.Symbol, d.Action, status, truncateRunes(d.Reasoning, memoryReasoningRunes))
+		}
+	}
+	if len(m.Trades) > 0 {
+		sb.WriteString("**最近平仓**:\n")
+		for _, t := range m.Trades {
+			fmt.Fprintf(&sb, "- %s %s %s %+.2f USDT (%+.1f%%) 持仓%s", t.CloseTime.Format("01-02 15:04"), t.Symbol, strings.ToUpper(t.Side), t.PnL, t.PnLPct, formatHold(t.Hold))
+			if t.StopLoss {
+				sb.WriteString(" 止损")
+			}
+			sb.WriteString("\n")
+		}
+	}
+	if len(m.Symbols) > 0 {
+		stats := make([]string, len(m.Symbols))
+		for i, s := range m.Symbols {
+			stats[i] = fmt.Sprintf("%s %d/%d胜(%.0f%%, %+.1fU)", s.Symbol, s.Wins, s.Trades, s.WinRate, s.TotalPnL)
+		}
+		fmt.Fprintf(&sb, "**币种胜率**: %s\n", strings.Join(stats, " | "))
+	}
+	if len(cooldowns) > 0 {
+		fmt.Fprintf(&sb, "**冷却中**（禁止开仓）: %s\n", strings.Join(cooldowns, ", "))
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// estimateTokens 粗略估算token数：中文等宽字符按1个token，其他字符按4个字符1个token
+func estimateTokens(s string) int {
+	wide, other := 0, 0
+	for _, r := range s {
+		if r > unicode.MaxASCII {
+			wide++
+		} else {
+			other++
+		}
+	}
+	return wide + (other+3)/4
+}
+
+// truncateRunes 截断到n个字符（超出时以…结尾）
+func truncateRunes(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n]) + "…"
+}
+
+// formatHold 持仓时长（如 "45分钟"、"3小时20分钟"）
+func formatHold(d time.Duration) string {
+	minutes := int(d.Minutes())
+	if minutes < 60 {
+		return fmt.Sprintf("%d分钟", minutes)
+	}
+	return fmt.Sprintf("%d小时%d分钟", minutes/60, minutes%60)
+}
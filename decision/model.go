@@ -0,0 +1,68 @@
+package decision
+
+import (
+	"fmt"
+	"nofx/mcp"
+)
+
+// ModelConfig 单个AI模型的配置（多模型集成、多空辩论各阶段使用）
+type ModelConfig struct {
+	Name       string `json:"name,omitempty"`        // 显示名称（日志中区分模型，默认为模型名）
+	AIModel    string `json:"ai_model"`              // "deepseek", "qwen" 或 "custom"
+	APIKey     string `json:"api_key"`               // API密钥
+	APIURL     string `json:"api_url,omitempty"`     // 自定义API地址（custom时必填）
+	ModelName  string `json:"model_name,omitempty"`  // 自定义模型名称（custom时必填）
+	OutputMode string `json:"output_mode,omitempty"` // 结构化输出方式（同 ai_output_mode）
+}
+
+// DisplayName 模型的显示名称
+func (m ModelConfig) DisplayName() string {
+	if m.Name != "" {
+		return m.Name
+	}
+	if m.AIModel == "custom" {
+		return m.ModelName
+	}
+	return m.AIModel
+}
+
+// Validate 校验模型配置
+func (m ModelConfig) Validate() error {
+	switch m.AIModel {
+	case "deepseek", "qwen":
+	case "custom":
+		if m.APIURL == "" || m.ModelName == "" {
+			return fmt.Errorf("使用自定义API时必须配置api_url和model_name")
+		}
+	default:
+		return fmt.Errorf("ai_model必须是 'qwen', 'deepseek' 或 'custom'")
+	}
+	if m.APIKey == "" {
+		return fmt.Errorf("api_key不能为空")
+	}
+	if m.OutputMode != mcp.OutputText && m.OutputMode != mcp.OutputJSONSchema && m.OutputMode != mcp.OutputToolCall {
+		return fmt.Errorf("output_mode必须是json_schema或tool: %q", m.OutputMode)
+	}
+	return nil
+}
+
+// ModelClient 带显示名称的AI客户端
+type ModelClient struct {
+	Name   string
+	Client *mcp.Client
+}
+
+// NewClient 按配置创建AI客户端
+func (m ModelConfig) NewClient() ModelClient {
+	client := mcp.New()
+	switch m.AIModel {
+	case "custom":
+		client.SetCustomAPI(m.APIURL, m.APIKey, m.ModelName)
+	case "qwen":
+		client.SetQwenAPIKey(m.APIKey, "")
+	default:
+		client.SetDeepSeekAPIKey(m.APIKey)
+	}
+	client.OutputMode = m.OutputMode
+	return ModelClient{Name: m.DisplayName(), Client: client}
+}
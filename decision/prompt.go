@@ -0,0 +1,364 @@
+package decision
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"nofx/market"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+//go:embed templates/*.tmpl
+var defaultTemplateFS embed.FS
+
+const (
+	defaultSystemTemplate     = "templates/system.tmpl"
+	defaultUserTemplate       = "templates/user.tmpl"
+	defaultCorrectionTemplate = "templates/correction.tmpl"
+	defaultBullTemplate       = "templates/bull.tmpl"
+	defaultBearTemplate       = "templates/bear.tmpl"
+	defaultJudgeTemplate      = "templates/judge.tmpl"
+)
+
+// PromptTemplates System / User Prompt 模板
+type PromptTemplates struct {
+	System     *template.Template
+	User       *template.Template
+	Correction *template.Template // 决策校验失败后的修正提示（使用内置模板）
+
+	// 多空辩论流水线（使用内置模板）
+	Bull  *template.Template // 多方分析师的 System Prompt
+	Bear  *template.Template // 空方分析师的 System Prompt
+	Judge *template.Template // 附加在裁判 User Prompt 之后的辩论记录
+}
+
+// PromptCandidate 有行情数据的候选币种（按展示顺序编号）
+type PromptCandidate struct {
+	Index   int
+	Symbol  string
+	Sources []string
+	Data    *market.Data
+}
+
+// PromptData 模板数据：内嵌完整的 Context，并补充模板常用的派生字段
+type PromptData struct {
+	*Context
+	ScanIntervalMin int                      // 扫描间隔（分钟，未配置时为3）
+	Timeframes      []market.TimeframeConfig // 行情时间框架（为空表示默认配置）
+	Performance     map[string]interface{}   // 历史表现（logger.PerformanceAnalysis 的JSON字段，如 .Performance.sharpe_ratio）
+	Candidates      []PromptCandidate        // 有行情数据的候选币种
+	Risk            RiskProfile              // 风险参数（已填充默认值，与 validateDecision 使用的一致）
+	Cooldowns       []string                 // 冷却中的币种及剩余时间（如 "SOLUSDT(剩余8分钟)"）
+
+	StructuredOutput bool // AI客户端使用结构化输出（影响输出格式说明）
+}
+
+var promptFuncs = template.FuncMap{
+	"add":                 func(a, b int) int { return a + b },
+	"mul":                 func(a, b interface{}) float64 { return toFloat(a) * toFloat(b) },
+	"percent":             percentOf,
+	"number":              toFloat,
+	"upper":               strings.ToUpper,
+	"join":                strings.Join,
+	"formatMarket":        market.Format,
+	"formatCorrelations":  market.FormatCorrelations,
+	"describeTimeframes":  market.DescribeTimeframes,
+	"describeRegimeRules": describeRegimeRules,
+	"holdingDuration":     holdingDuration,
+	"formatMemory":        formatMemory,
+	"sourceTags":          sourceTags,
+}
+
+// LoadPromptTemplates 加载 System / User Prompt 模板（路径为空时使用内置默认模板）
+func LoadPromptTemplates(systemPath, userPath string) (*PromptTemplates, error) {
+	system, err := loadTemplate(systemPath, defaultSystemTemplate)
+	if err != nil {
+		return nil, err
+	}
+	user, err := loadTemplate(userPath, defaultUserTemplate)
+	if err != nil {
+		return nil, err
+	}
+	correction, err := loadTemplate("", defaultCorrectionTemplate)
+	if err != nil {
+		return nil, err
+	}
+	bull, err := loadTemplate("", defaultBullTemplate)
+	if err != nil {
+		return nil, err
+	}
+	bear, err := loadTemplate("", defaultBearTemplate)
+	if err != nil {
+		return nil, err
+	}
+	judge, err := loadTemplate("", defaultJudgeTemplate)
+	if err != nil {
+		return nil, err
+	}
+	return &PromptTemplates{
+		System:     system,
+		User:       user,
+		Correction: correction,
+		Bull:       bull,
+		Bear:       bear,
+		Judge:      judge,
+	}, nil
+}
+
+// ValidatePromptTemplates 加载模板并用示例数据试渲染（字段名、函数调用错误在配置加载时暴露）
+func ValidatePromptTemplates(systemPath, userPath string) error {
+	templates, err := LoadPromptTemplates(systemPath, userPath)
+	if err != nil {
+		return err
+	}
+	ctx := samplePromptContext()
+	if _, err := templates.renderSystem(ctx, false); err != nil {
+		return err
+	}
+	if _, err := templates.renderUser(ctx); err != nil {
+		return err
+	}
+	if _, err := templates.renderCorrection(ctx, false, []string{"BTCUSDT open_long: 风险回报比过低"}, "[]"); err != nil {
+		return err
+	}
+	for _, tmpl := range []*template.Template{templates.Bull, templates.Bear} {
+		if _, err := execute(tmpl, newPromptData(ctx)); err != nil {
+			return err
+		}
+	}
+	if _, err := templates.renderJudge(ctx, "BTCUSDT 做多", "BTCUSDT 做空"); err != nil {
+		return err
+	}
+	return nil
+}
+
+func loadTemplate(path, defaultName string) (*template.Template, error) {
+	var (
+		name    string
+		content []byte
+		err     error
+	)
+	if path == "" {
+		name = filepath.Base(defaultName)
+		content, err = defaultTemplateFS.ReadFile(defaultName)
+	} else {
+		name = filepath.Base(path)
+		content, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取prompt模板失败: %w", err)
+	}
+
+	tmpl, err := template.New(name).Funcs(promptFuncs).Option("missingkey=zero").Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("解析prompt模板失败: %w", err)
+	}
+	return tmpl, nil
+}
+
+// renderSystem 渲染 System Prompt（固定规则）
+func (t *PromptTemplates) renderSystem(ctx *Context, structured bool) (string, error) {
+	data := newPromptData(ctx)
+	data.StructuredOutput = structured
+	return execute(t.System, data)
+}
+
+// renderUser 渲染 User Prompt（动态数据）
+func (t *PromptTemplates) renderUser(ctx *Context) (string, error) {
+	return execute(t.User, newPromptData(ctx))
+}
+
+// renderCorrection 渲染修正提示（上一轮的错误、原始输出和约束）
+func (t *PromptTemplates) renderCorrection(ctx *Context, structured bool, errors []string, response string) (string, error) {
+	data := newPromptData(ctx)
+	data.StructuredOutput = structured
+	return execute(t.Correction, &correctionData{
+		PromptData: data,
+		Errors:     errors,
+		Response:   response,
+	})
+}
+
+// renderJudge 渲染裁判阶段附加的多空辩论记录
+func (t *PromptTemplates) renderJudge(ctx *Context, bull, bear string) (string, error) {
+	return execute(t.Judge, &judgeData{
+		PromptData: newPromptData(ctx),
+		Bull:       bull,
+		Bear:       bear,
+	})
+}
+
+// judgeData 裁判阶段模板数据
+type judgeData struct {
+	*PromptData
+	Bull string // 多方论证
+	Bear string // 空方论证
+}
+
+// correctionData 修正提示模板数据
+type correctionData struct {
+	*PromptData
+	Errors   []string // 上一轮的解析或校验错误
+	Response string   // 上一轮的原始输出
+}
+
+func execute(tmpl *template.Template, data interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("渲染prompt模板 %s 失败: %w", tmpl.Name(), err)
+	}
+	return buf.String(), nil
+}
+
+func newPromptData(ctx *Context) *PromptData {
+	data := &PromptData{
+		Context:         ctx,
+		ScanIntervalMin: ctx.ScanIntervalMin,
+		Risk:            ctx.Risk.WithDefaults(),
+	}
+	if data.ScanIntervalMin <= 0 {
+		data.ScanIntervalMin = 3
+	}
+	if ctx.MarketOptions != nil {
+		data.Timeframes = ctx.MarketOptions.Timeframes
+	}
+
+	// Performance 是 interface{}（避免依赖logger），转成JSON字段的map供模板访问
+	if ctx.Performance != nil {
+		if jsonData, err := json.Marshal(ctx.Performance); err == nil {
+			var perf map[string]interface{}
+			if err := json.Unmarshal(jsonData, &perf); err == nil {
+				data.Performance = perf
+			}
+		}
+	}
+
+	now := time.Now()
+	symbols := make([]string, 0, len(ctx.RecentCloses))
+	for symbol := range ctx.RecentCloses {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	for _, symbol := range symbols {
+		if remaining := ctx.cooldownRemaining(symbol, now); remaining > 0 {
+			data.Cooldowns = append(data.Cooldowns, fmt.Sprintf("%s(剩余%.0f分钟)", symbol, math.Ceil(remaining.Minutes())))
+		}
+	}
+
+	for _, coin := range ctx.CandidateCoins {
+		marketData, ok := ctx.MarketDataMap[coin.Symbol]
+		if !ok {
+			continue
+		}
+		data.Candidates = append(data.Candidates, PromptCandidate{
+			Index:   len(data.Candidates) + 1,
+			Symbol:  coin.Symbol,
+			Sources: coin.Sources,
+			Data:    marketData,
+		})
+	}
+	return data
+}
+
+// defaultPromptTemplates 内置默认模板（编译时嵌入，解析失败属于程序错误）
+var defaultPromptTemplates = func() *PromptTemplates {
+	templates, err := LoadPromptTemplates("", "")
+	if err != nil {
+		panic(err)
+	}
+	return templates
+}()
+
+// samplePromptContext 模板校验用的示例上下文（覆盖持仓、候选、BTC、相关性、历史表现和记忆分支）
+func samplePromptContext() *Context {
+	now := time.Now()
+	data := &market.Data{Symbol: "BTCUSDT", CurrentPrice: 100000, Regime: &market.RegimeData{Regime: market.RegimeRanging, Interval: "4h"}}
+	return &Context{
+		CurrentTime:    now.Format("2006-01-02 15:04:05"),
+		RuntimeMinutes: 60,
+		CallCount:      20,
+		Account:        AccountInfo{TotalEquity: 1000, AvailableBalance: 800, MarginUsed: 200, MarginUsedPct: 20, PositionCount: 1},
+		Positions: []PositionInfo{
+			{Symbol: "BTCUSDT", Side: "long", EntryPrice: 99000, MarkPrice: 100000, Quantity: 0.01, Leverage: 5, MarginUsed: 200, UpdateTime: now.Add(-90 * time.Minute).UnixMilli()},
+		},
+		CandidateCoins:  []CandidateCoin{{Symbol: "BTCUSDT", Sources: []string{"ai500", "oi_top"}}},
+		MarketDataMap:   map[string]*market.Data{"BTCUSDT": data},
+		Performance:     map[string]interface{}{"sharpe_ratio": 0.5},
+		BTCETHLeverage:  5,
+		AltcoinLeverage: 5,
+		ScanIntervalMin: 3,
+		BTCData:         data,
+		Correlations: &market.CorrelationMatrix{
+			Interval:    "4h",
+			Symbols:     []string{"BTCUSDT", "ETHUSDT"},
+			Correlation: map[string]map[string]float64{"ETHUSDT": {"BTCUSDT": 0.9}},
+			Beta:        map[string]float64{"ETHUSDT": 1.2},
+		},
+		CorrelationThreshold:   0.8,
+		MaxCorrelatedPositions: 2,
+		RegimeRules:            []RegimeRule{{Regime: string(market.RegimeHighVolatility), Scope: RegimeScopeMarket, Block: "open"}},
+		Risk:                   RiskProfile{SymbolPositions: map[string]PositionMultiple{"SOLUSDT": {Min: 1, Max: 3}}},
+		RecentCloses:           map[string]time.Time{"ETHUSDT": now.Add(-5 * time.Minute)},
+		Memory: &Memory{
+			Decisions: []MemoryDecision{{Time: now.Add(-90 * time.Minute), Symbol: "BTCUSDT", Action: "open_long", Reasoning: "突破", Executed: true}},
+			Trades:    []MemoryTrade{{Symbol: "ETHUSDT", Side: "long", PnL: -12, PnLPct: -6, Hold: 40 * time.Minute, CloseTime: now.Add(-5 * time.Minute), StopLoss: true}},
+			Symbols:   []MemorySymbol{{Symbol: "ETHUSDT", Trades: 1, WinRate: 0, TotalPnL: -12}},
+			MaxTokens: DefaultMemoryConfig.MaxTokens,
+		},
+	}
+}
+
+// percentOf a占b的百分比（b为0时返回0）
+func percentOf(a, b float64) float64 {
+	if b == 0 {
+		return 0
+	}
+	return a / b * 100
+}
+
+// toFloat 模板中的数值统一转为float64（nil或非数值为0）
+func toFloat(v interface{}) float64 {
+	switch n := v.(type) {
+	case float64:
+		return n
+	case float32:
+		return float64(n)
+	case int:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case json.Number:
+		f, _ := n.Float64()
+		return f
+	}
+	return 0
+}
+
+// holdingDuration 持仓时长（updateTime为毫秒时间戳，未知时返回空字符串）
+func holdingDuration(updateTime int64) string {
+	if updateTime <= 0 {
+		return ""
+	}
+	durationMin := (time.Now().UnixMilli() - updateTime) / (1000 * 60)
+	if durationMin < 60 {
+		return fmt.Sprintf("%d分钟", durationMin)
+	}
+	return fmt.Sprintf("%d小时%d分钟", durationMin/60, durationMin%60)
+}
+
+// sourceTags 候选币种来源标记
+func sourceTags(sources []string) string {
+	if len(sources) > 1 {
+		return " (AI500+OI_Top双重信号)"
+	} else if len(sources) == 1 && sources[0] == "oi_top" {
+		return " (OI_Top持仓增长)"
+	}
+	return ""
+}
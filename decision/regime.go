@@ -0,0 +1,93 @@
+package decision
+
+import (
+	"fmt"
+	"nofx/market"
+	"strings"
+)
+
+// RegimeRule 市场状态规则：处于指定状态时禁止某类开仓
+// 状态取自 market.ClassifyRegime 的判定结果（market.Data.Regime），这里不重复判定阈值
+type RegimeRule struct {
+	Regime string `json:"regime"`          // 市场状态（见 market.Regimes）: trending_up / trending_down / ranging / high_volatility
+	Scope  string `json:"scope,omitempty"` // symbol（币种自身状态，默认）或 market（以BTC代表整体市场）
+	Block  string `json:"block"`           // 禁止的操作: open（所有开仓）/ open_long / open_short
+}
+
+const (
+	RegimeScopeSymbol = "symbol"
+	RegimeScopeMarket = "market"
+)
+
+// ValidateRegimeRules 校验市场状态规则配置
+func ValidateRegimeRules(rules []RegimeRule) error {
+	for i, rule := range rules {
+		valid := false
+		for _, r := range market.Regimes {
+			if rule.Regime == string(r) {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("regime_rules[%d]: 无效的市场状态 %q", i, rule.Regime)
+		}
+		if rule.Scope != "" && rule.Scope != RegimeScopeSymbol && rule.Scope != RegimeScopeMarket {
+			return fmt.Errorf("regime_rules[%d]: scope必须是symbol或market: %q", i, rule.Scope)
+		}
+		if rule.Block != "open" && rule.Block != "open_long" && rule.Block != "open_short" {
+			return fmt.Errorf("regime_rules[%d]: block必须是open、open_long或open_short: %q", i, rule.Block)
+		}
+	}
+	return nil
+}
+
+// blocks 规则是否禁止该操作
+func (r RegimeRule) blocks(action string) bool {
+	return r.Block == action || (r.Block == "open" && (action == "open_long" || action == "open_short"))
+}
+
+// String 规则描述（用于prompt和错误信息）
+func (r RegimeRule) String() string {
+	scope := "币种"
+	if r.Scope == RegimeScopeMarket {
+		scope = "市场(BTC)"
+	}
+	block := "开仓"
+	switch r.Block {
+	case "open_long":
+		block = "开多"
+	case "open_short":
+		block = "开空"
+	}
+	return fmt.Sprintf("%s处于%s时禁止%s", scope, r.Regime, block)
+}
+
+// checkRegimeRules 检查开仓决策是否违反市场状态规则（状态未知时不限制）
+func checkRegimeRules(d *Decision, ctx *Context) error {
+	for _, rule := range ctx.RegimeRules {
+		if !rule.blocks(d.Action) {
+			continue
+		}
+
+		var data *market.Data
+		if rule.Scope == RegimeScopeMarket {
+			data = ctx.BTCData
+		} else {
+			data = ctx.MarketDataMap[d.Symbol]
+		}
+		if data != nil && data.Regime != nil && string(data.Regime.Regime) == rule.Regime {
+			return fmt.Errorf("%s %s 违反市场状态规则：%s", d.Symbol, d.Action, rule)
+		}
+	}
+	return nil
+}
+
+// describeRegimeRules 规则列表描述
+func describeRegimeRules(rules []RegimeRule) string {
+	descriptions := make([]string, len(rules))
+	for i, rule := range rules {
+		descriptions[i] = rule.String()
+	}
+	return strings.Join(descriptions, "；")
+}
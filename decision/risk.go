@@ -0,0 +1,328 @@
+package decision
+
+import (
+	"fmt"
+	"log"
+	"nofx/market"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maintenanceMarginRate 估算强平价使用的维持保证金率（交易所最低档位通常为0.4%-1%，取偏保守的值）
+const maintenanceMarginRate = 0.005
+
+// PositionMultiple 单币仓位价值范围（账户净值的倍数）
+type PositionMultiple struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+// RiskProfile 策略风险参数
+// 同一份参数既渲染进prompt，也在 validateDecision 中强制执行，避免两处数字不一致
+// 零值使用默认值；持仓数、风险回报比、信心度、冷却期设为-1表示不检查该项
+type RiskProfile struct {
+	MaxPositions      int     `json:"max_positions,omitempty"`        // 最多同时持仓币种数（默认3，-1表示不限）
+	MinRiskReward     float64 `json:"min_risk_reward,omitempty"`      // 最低风险回报比（默认3，即1:3，-1表示不限）
+	MinConfidence     int     `json:"min_confidence,omitempty"`       // 开仓最低信心度（默认75，-1表示不限）
+	MaxMarginUsagePct float64 `json:"max_margin_usage_pct,omitempty"` // 开仓后总保证金使用率上限（百分比，默认90）
+	CooldownMinutes   int     `json:"cooldown_minutes,omitempty"`     // 同币种平仓后禁止再开仓的分钟数（默认15，-1表示不冷却）
+	MinStopATR        float64 `json:"min_stop_atr,omitempty"`         // 止损距离至少为多少个ATR（基于最短周期，0表示不限制）
+
+	BTCETHPosition  PositionMultiple            `json:"btc_eth_position"`           // BTC/ETH仓位价值（默认5-10倍净值）
+	AltcoinPosition PositionMultiple            `json:"altcoin_position"`           // 山寨币仓位价值（默认0.8-1.5倍净值）
+	SymbolPositions map[string]PositionMultiple `json:"symbol_positions,omitempty"` // 单个币种覆盖（如 {"SOLUSDT": {"min": 1, "max": 3}}）
+}
+
+// DefaultRiskProfile 默认风险参数
+var DefaultRiskProfile = RiskProfile{
+	MaxPositions:      3,
+	MinRiskReward:     3,
+	MinConfidence:     75,
+	MaxMarginUsagePct: 90,
+	CooldownMinutes:   15,
+	BTCETHPosition:    PositionMultiple{Min: 5, Max: 10},
+	AltcoinPosition:   PositionMultiple{Min: 0.8, Max: 1.5},
+}
+
+// WithDefaults 未配置（零值）的字段使用 DefaultRiskProfile
+// -1 原样保留（可重复调用），各检查对小于等于0的值一律跳过
+func (p RiskProfile) WithDefaults() RiskProfile {
+	if p.MaxPositions == 0 {
+		p.MaxPositions = DefaultRiskProfile.MaxPositions
+	}
+	if p.MinRiskReward == 0 {
+		p.MinRiskReward = DefaultRiskProfile.MinRiskReward
+	}
+	if p.MinConfidence == 0 {
+		p.MinConfidence = DefaultRiskProfile.MinConfidence
+	}
+	if p.MaxMarginUsagePct == 0 {
+		p.MaxMarginUsagePct = DefaultRiskProfile.MaxMarginUsagePct
+	}
+	if p.CooldownMinutes == 0 {
+		p.CooldownMinutes = DefaultRiskProfile.CooldownMinutes
+	}
+	if p.BTCETHPosition == (PositionMultiple{}) {
+		p.BTCETHPosition = DefaultRiskProfile.BTCETHPosition
+	}
+	if p.AltcoinPosition == (PositionMultiple{}) {
+		p.AltcoinPosition = DefaultRiskProfile.AltcoinPosition
+	}
+	return p
+}
+
+// Validate 校验风险参数配置
+func (p RiskProfile) Validate() error {
+	if p.MaxPositions < -1 {
+		return fmt.Errorf("risk_profile.max_positions不能小于-1（-1表示不限）")
+	}
+	if p.MinRiskReward < 0 && p.MinRiskReward != -1 {
+		return fmt.Errorf("risk_profile.min_risk_reward不能为负数（-1表示不限）")
+	}
+	if p.MinConfidence < -1 || p.MinConfidence > 100 {
+		return fmt.Errorf("risk_profile.min_confidence必须在0到100之间（-1表示不限）")
+	}
+	if p.MaxMarginUsagePct < 0 || p.MaxMarginUsagePct > 100 {
+		return fmt.Errorf("risk_profile.max_margin_usage_pct必须在0到100之间")
+	}
+	if p.CooldownMinutes < -1 {
+		return fmt.Errorf("risk_profile.cooldown_minutes不能小于-1（-1表示不冷却）")
+	}
+	if p.MinStopATR < 0 {
+		return fmt.Errorf("risk_profile.min_stop_atr不能为负数")
+	}
+	if err := p.BTCETHPosition.validate(); err != nil {
+		return fmt.Errorf("risk_profile.btc_eth_position: %w", err)
+	}
+	if err := p.AltcoinPosition.validate(); err != nil {
+		return fmt.Errorf("risk_profile.altcoin_position: %w", err)
+	}
+	for symbol, m := range p.SymbolPositions {
+		if m == (PositionMultiple{}) {
+			return fmt.Errorf("risk_profile.symbol_positions[%s]: max必须大于0", symbol)
+		}
+		if err := m.validate(); err != nil {
+			return fmt.Errorf("risk_profile.symbol_positions[%s]: %w", symbol, err)
+		}
+	}
+	return nil
+}
+
+func (m PositionMultiple) validate() error {
+	if m == (PositionMultiple{}) {
+		return nil // 使用默认值
+	}
+	if m.Min < 0 || m.Max <= 0 {
+		return fmt.Errorf("min不能为负数且max必须大于0")
+	}
+	if m.Min > m.Max {
+		return fmt.Errorf("min(%.2f)不能大于max(%.2f)", m.Min, m.Max)
+	}
+	return nil
+}
+
+// PositionMultipleFor 币种的仓位价值范围（单币覆盖 > BTC/ETH > 山寨币）
+func (p RiskProfile) PositionMultipleFor(symbol string) PositionMultiple {
+	if m, ok := p.SymbolPositions[symbol]; ok {
+		return m
+	}
+	if symbol == "BTCUSDT" || symbol == "ETHUSDT" {
+		return p.BTCETHPosition
+	}
+	return p.AltcoinPosition
+}
+
+// SymbolOverrides 单币覆盖列表（按币种排序，便于模板输出）
+func (p RiskProfile) SymbolOverrides() []string {
+	symbols := make([]string, 0, len(p.SymbolPositions))
+	for symbol := range p.SymbolPositions {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+
+	overrides := make([]string, len(symbols))
+	for i, symbol := range symbols {
+		m := p.SymbolPositions[symbol]
+		overrides[i] = fmt.Sprintf("%s %g-%g倍净值", symbol, m.Min, m.Max)
+	}
+	return overrides
+}
+
+// cooldownRemaining 币种距离冷却结束的剩余时间（不在冷却期返回0）
+func (ctx *Context) cooldownRemaining(symbol string, now time.Time) time.Duration {
+	closedAt, ok := ctx.RecentCloses[symbol]
+	if !ok {
+		return 0
+	}
+	cooldown := time.Duration(ctx.Risk.WithDefaults().CooldownMinutes) * time.Minute
+	if cooldown <= 0 {
+		return 0
+	}
+	if remaining := closedAt.Add(cooldown).Sub(now); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// riskBudget 跟踪一批决策执行后的持仓数、保证金占用和同方向高相关持仓
+// 平仓先于开仓执行（见 trader.sortDecisionsByPriority），因此本批平仓释放的名额和保证金可用于本批开仓
+type riskBudget struct {
+	profile RiskProfile
+	equity  float64
+	symbols map[string]bool // 持仓币种
+	margin  float64         // 已用保证金
+	held    []heldPosition  // 持仓（含本批已通过的开仓）
+
+	correlations  *market.CorrelationMatrix
+	corrThreshold float64
+	maxCorrelated int // 同方向高相关持仓数上限（0表示不限）
+}
+
+// heldPosition 风险预算中的一个持仓
+type heldPosition struct {
+	symbol string
+	side   string
+}
+
+func newRiskBudget(ctx *Context, decisions []Decision) *riskBudget {
+	b := &riskBudget{
+		profile:       ctx.Risk.WithDefaults(),
+		equity:        ctx.Account.TotalEquity,
+		symbols:       make(map[string]bool),
+		correlations:  ctx.Correlations,
+		corrThreshold: ctx.CorrelationThreshold,
+		maxCorrelated: ctx.MaxCorrelatedPositions,
+	}
+	closing := make(map[string]bool)
+	for _, d := range decisions {
+		if d.Action == "close_long" || d.Action == "close_short" {
+			closing[d.Symbol+"_"+d.Action[len("close_"):]] = true
+		}
+	}
+	for _, pos := range ctx.Positions {
+		if closing[pos.Symbol+"_"+pos.Side] {
+			continue
+		}
+		b.symbols[pos.Symbol] = true
+		b.margin += pos.MarginUsed
+		b.held = append(b.held, heldPosition{symbol: pos.Symbol, side: pos.Side})
+	}
+	return b
+}
+
+// reserve 检查开仓是否超出持仓数、保证金和高相关持仓上限，通过则计入预算
+func (b *riskBudget) reserve(d *Decision) error {
+	if b.profile.MaxPositions > 0 && !b.symbols[d.Symbol] && len(b.symbols) >= b.profile.MaxPositions {
+		return fmt.Errorf("%s 开仓后持仓币种数将超过上限%d个", d.Symbol, b.profile.MaxPositions)
+	}
+
+	margin := d.PositionSizeUSD / float64(d.Leverage)
+	if b.equity > 0 {
+		usage := (b.margin + margin) / b.equity * 100
+		if usage > b.profile.MaxMarginUsagePct {
+			return fmt.Errorf("%s 开仓后保证金使用率%.1f%%将超过上限%g%%", d.Symbol, usage, b.profile.MaxMarginUsagePct)
+		}
+	}
+
+	side := strings.TrimPrefix(d.Action, "open_")
+	if err := b.checkCorrelated(d.Symbol, side); err != nil {
+		return err
+	}
+
+	b.symbols[d.Symbol] = true
+	b.margin += margin
+	b.held = append(b.held, heldPosition{symbol: d.Symbol, side: side})
+	return nil
+}
+
+// checkCorrelated 检查同方向高相关持仓数量（含本批已通过的开仓）
+// 多个高相关币种同向持仓本质上是同一笔BTC beta交易，风险会成倍放大
+func (b *riskBudget) checkCorrelated(symbol, side string) error {
+	if b.correlations == nil || b.maxCorrelated <= 0 {
+		return nil
+	}
+
+	var correlated []string
+	for _, pos := range b.held {
+		if pos.side != side || pos.symbol == symbol {
+			continue
+		}
+		if c, ok := b.correlations.Corr(symbol, pos.symbol); ok && c >= b.corrThreshold {
+			correlated = append(correlated, fmt.Sprintf("%s(%.2f)", pos.symbol, c))
+		}
+	}
+
+	if len(correlated)+1 > b.maxCorrelated {
+		return fmt.Errorf("%s 与%s仓 %s 收益率高度相关（≥%.2f），同方向高相关持仓最多%d个",
+			symbol, side, strings.Join(correlated, ", "), b.corrThreshold, b.maxCorrelated)
+	}
+	if len(correlated) > 0 {
+		log.Printf("  🔗 %s 与%s仓 %s 高度相关（%d/%d）", symbol, side, strings.Join(correlated, ", "), len(correlated)+1, b.maxCorrelated)
+	}
+	return nil
+}
+
+// EntryRisk 按实时价格计算的开仓风险指标
+type EntryRisk struct {
+	EntryPrice      float64 // 入场价（当前价）
+	RiskReward      float64 // 风险回报比
+	StopDistancePct float64 // 止损距离（%）
+	StopATR         float64 // 止损距离（ATR倍数，ATR未知时为0）
+	LiquidationPct  float64 // 强平距离（%，按所选杠杆和维持保证金率估算）
+}
+
+func (r *EntryRisk) String() string {
+	return fmt.Sprintf("入场%.4f | R:R %.2f:1 | 止损距离%.2f%% (%.1f ATR) | 强平距离%.2f%%",
+		r.EntryPrice, r.RiskReward, r.StopDistancePct, r.StopATR, r.LiquidationPct)
+}
+
+// checkEntryRisk 以实时价格为入场价校验开仓的止损止盈
+// 拒绝已被触发的止损/止盈、风险回报比不足、止损过近（ATR）以及止损在强平价之外的决策
+func checkEntryRisk(d *Decision, data *market.Data, risk RiskProfile) (*EntryRisk, error) {
+	if data == nil || data.CurrentPrice <= 0 {
+		return nil, fmt.Errorf("%s 缺少实时价格，无法校验止损止盈", d.Symbol)
+	}
+	price := data.CurrentPrice
+
+	var stopDistance, reward float64
+	if d.Action == "open_long" {
+		if d.StopLoss >= price {
+			return nil, fmt.Errorf("做多止损价%.4f不低于当前价%.4f，止损已触发", d.StopLoss, price)
+		}
+		if d.TakeProfit <= price {
+			return nil, fmt.Errorf("做多止盈价%.4f不高于当前价%.4f，止盈已触发", d.TakeProfit, price)
+		}
+		stopDistance, reward = price-d.StopLoss, d.TakeProfit-price
+	} else {
+		if d.StopLoss <= price {
+			return nil, fmt.Errorf("做空止损价%.4f不高于当前价%.4f，止损已触发", d.StopLoss, price)
+		}
+		if d.TakeProfit >= price {
+			return nil, fmt.Errorf("做空止盈价%.4f不低于当前价%.4f，止盈已触发", d.TakeProfit, price)
+		}
+		stopDistance, reward = d.StopLoss-price, price-d.TakeProfit
+	}
+
+	r := &EntryRisk{
+		EntryPrice:      price,
+		RiskReward:      reward / stopDistance,
+		StopDistancePct: stopDistance / price * 100,
+		LiquidationPct:  (1/float64(d.Leverage) - maintenanceMarginRate) * 100,
+	}
+	if data.CurrentATR14 > 0 {
+		r.StopATR = stopDistance / data.CurrentATR14
+	}
+
+	if risk.MinRiskReward > 0 && r.RiskReward < risk.MinRiskReward {
+		return r, fmt.Errorf("风险回报比过低(%.2f:1)，必须≥%.1f:1 [入场:%.4f 止损:%.4f 止盈:%.4f]",
+			r.RiskReward, risk.MinRiskReward, price, d.StopLoss, d.TakeProfit)
+	}
+	if r.StopDistancePct >= r.LiquidationPct {
+		return r, fmt.Errorf("止损距离%.2f%%超过%d倍杠杆的强平距离%.2f%%，会先被强平", r.StopDistancePct, d.Leverage, r.LiquidationPct)
+	}
+	if risk.MinStopATR > 0 && r.StopATR > 0 && r.StopATR < risk.MinStopATR {
+		return r, fmt.Errorf("止损距离仅%.2f ATR，小于下限%g ATR（容易被正常波动扫损）", r.StopATR, risk.MinStopATR)
+	}
+	return r, nil
+}
@@ -0,0 +1,244 @@
+package decision
+
+import (
+	"fmt"
+	"nofx/market"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckEntryRisk(t *testing.T) {
+	risk := RiskProfile{MinRiskReward: 3, MinStopATR: 1}
+	data := &market.Data{CurrentPrice: 100, CurrentATR14: 2}
+
+	tests := []struct {
+		name    string
+		action  string
+		sl, tp  float64
+		lev     int
+		data    *market.Data
+		wantErr string // 为空表示通过
+	}{
+		{"做多通过", "open_long", 95, 116, 10, data, ""},
+		{"做空通过", "open_short", 105, 84, 10, data, ""},
+		{"缺少实时价格", "open_long", 95, 116, 10, nil, "缺少实时价格"},
+		{"价格为0", "open_long", 95, 116, 10, &market.Data{}, "缺少实时价格"},
+		{"做多止损等于当前价", "open_long", 100, 116, 10, data, "止损已触发"},
+		{"做多止盈低于当前价", "open_long", 95, 99, 10, data, "止盈已触发"},
+		{"做空止损低于当前价", "open_short", 99, 84, 10, data, "止损已触发"},
+		{"做空止盈等于当前价", "open_short", 105, 100, 10, data, "止盈已触发"},
+		{"风险回报比刚好达标", "open_long", 95, 115, 10, data, ""},
+		{"风险回报比不足", "open_long", 95, 114.9, 10, data, "风险回报比过低"},
+		{"做空风险回报比不足", "open_short", 105, 85.1, 10, data, "风险回报比过低"},
+		{"止损在强平价之内", "open_long", 90.6, 140, 10, data, ""},
+		{"止损在强平价之外", "open_long", 90.4, 140, 10, data, "会先被强平"},
+		{"高杠杆强平距离更近", "open_short", 105, 80, 20, data, "会先被强平"},
+		{"止损小于ATR下限", "open_long", 99, 104, 10, data, "ATR"},
+		{"无ATR时不检查止损距离", "open_long", 99, 104, 10, &market.Data{CurrentPrice: 100}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &Decision{Symbol: "BTCUSDT", Action: tt.action, StopLoss: tt.sl, TakeProfit: tt.tp, Leverage: tt.lev}
+			_, err := checkEntryRisk(d, tt.data, risk)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("期望通过, 实际: %v", err)
+			case tt.wantErr != "" && err == nil:
+				t.Fatalf("期望错误包含 %q, 实际通过", tt.wantErr)
+			case tt.wantErr != "" && !strings.Contains(err.Error(), tt.wantErr):
+				t.Fatalf("错误 = %v, 期望包含 %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckEntryRiskMetrics(t *testing.T) {
+	d := &Decision{Symbol: "BTCUSDT", Action: "open_short", StopLoss: 104, TakeProfit: 88, Leverage: 5}
+	r, err := checkEntryRisk(d, &market.Data{CurrentPrice: 100, CurrentATR14: 2}, RiskProfile{MinRiskReward: 3})
+	if err != nil {
+		t.Fatalf("checkEntryRisk: %v", err)
+	}
+
+	for _, c := range []struct {
+		field     string
+		got, want float64
+	}{
+		{"entry_price", r.EntryPrice, 100},
+		{"risk_reward", r.RiskReward, 3},
+		{"stop_distance_pct", r.StopDistancePct, 4},
+		{"liquidation_pct", r.LiquidationPct, 19.5},
+		{"stop_atr", r.StopATR, 2},
+	} {
+		if diff := c.got - c.want; diff > 1e-9 || diff < -1e-9 {
+			t.Errorf("%s = %v, 期望 %v", c.field, c.got, c.want)
+		}
+	}
+}
+
+func TestRiskProfileDisabled(t *testing.T) {
+	disabled := RiskProfile{MaxPositions: -1, MinRiskReward: -1, MinConfidence: -1, CooldownMinutes: -1}
+
+	p := disabled.WithDefaults()
+	if !reflect.DeepEqual(p.WithDefaults(), p) {
+		t.Fatalf("WithDefaults 重复调用结果不一致")
+	}
+	if p.MaxPositions != -1 || p.MinRiskReward != -1 || p.MinConfidence != -1 || p.CooldownMinutes != -1 {
+		t.Errorf("-1 应原样保留: %+v", p)
+	}
+	if p.MaxMarginUsagePct != DefaultRiskProfile.MaxMarginUsagePct {
+		t.Errorf("未配置的字段应使用默认值: %+v", p)
+	}
+
+	for _, c := range []struct {
+		name    string
+		profile RiskProfile
+		wantErr bool
+	}{
+		{"-1表示不限", disabled, false},
+		{"持仓数小于-1", RiskProfile{MaxPositions: -2}, true},
+		{"风险回报比为其他负数", RiskProfile{MinRiskReward: -0.5}, true},
+		{"信心度小于-1", RiskProfile{MinConfidence: -2}, true},
+		{"冷却期小于-1", RiskProfile{CooldownMinutes: -2}, true},
+	} {
+		if err := c.profile.Validate(); (err != nil) != c.wantErr {
+			t.Errorf("%s: Validate() = %v, 期望出错 %v", c.name, err, c.wantErr)
+		}
+	}
+}
+
+func TestValidateDecisionsDisabledRisk(t *testing.T) {
+	now := time.Now()
+	// 已有3个持仓、刚平仓SOL、信心度10、风险回报比1.5：默认参数下每一项都不满足
+	entry := Decision{Symbol: "SOLUSDT", Action: "open_long", Leverage: 5, PositionSizeUSD: 1000,
+		StopLoss: 95, TakeProfit: 107.5, Confidence: 10}
+
+	tests := []struct {
+		name    string
+		risk    RiskProfile
+		wantErr string // 为空表示通过
+	}{
+		{"默认参数", RiskProfile{}, "冷却中"},
+		{"关闭冷却期", RiskProfile{CooldownMinutes: -1}, "信心度"},
+		{"再关闭信心度", RiskProfile{CooldownMinutes: -1, MinConfidence: -1}, "风险回报比"},
+		{"再关闭风险回报比", RiskProfile{CooldownMinutes: -1, MinConfidence: -1, MinRiskReward: -1}, "持仓币种数"},
+		{"全部关闭", RiskProfile{CooldownMinutes: -1, MinConfidence: -1, MinRiskReward: -1, MaxPositions: -1}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := &Context{
+				Account: AccountInfo{TotalEquity: 1000},
+				Positions: []PositionInfo{
+					{Symbol: "BTCUSDT", Side: "long", MarginUsed: 50},
+					{Symbol: "ETHUSDT", Side: "long", MarginUsed: 50},
+					{Symbol: "XRPUSDT", Side: "short", MarginUsed: 50},
+				},
+				MarketDataMap:   map[string]*market.Data{"SOLUSDT": {Symbol: "SOLUSDT", CurrentPrice: 100}},
+				BTCETHLeverage:  5,
+				AltcoinLeverage: 5,
+				Risk:            tt.risk,
+				RecentCloses:    map[string]time.Time{"SOLUSDT": now.Add(-time.Minute)},
+			}
+			valid, rejected := validateDecisions([]Decision{entry}, ctx)
+			if tt.wantErr == "" {
+				if len(valid) != 1 {
+					t.Fatalf("期望通过, 实际被拒绝: %+v", rejected)
+				}
+				return
+			}
+			if len(rejected) != 1 || !strings.Contains(rejected[0].Reason, tt.wantErr) {
+				t.Fatalf("拒绝原因 = %+v, 期望包含 %q", rejected, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPromptDisabledRisk(t *testing.T) {
+	ctx := samplePromptContext()
+	ctx.Risk = RiskProfile{MaxPositions: -1, MinRiskReward: -1, MinConfidence: -1, CooldownMinutes: -1}
+
+	system, err := defaultPromptTemplates.renderSystem(ctx, false)
+	if err != nil {
+		t.Fatalf("renderSystem: %v", err)
+	}
+	correction, err := defaultPromptTemplates.renderCorrection(ctx, false, []string{"错误"}, "[]")
+	if err != nil {
+		t.Fatalf("renderCorrection: %v", err)
+	}
+	for _, bad := range []string{"1:-1", "≥ -1", "≥-1", "-1个币种", "-1分钟"} {
+		if strings.Contains(system, bad) || strings.Contains(correction, bad) {
+			t.Errorf("prompt中出现了未关闭的约束 %q", bad)
+		}
+	}
+}
+
+func TestValidateDecisionsCorrelatedExposure(t *testing.T) {
+	open := func(symbol, action string) Decision {
+		return Decision{Symbol: symbol, Action: action, Leverage: 5, PositionSizeUSD: 1000, StopLoss: 95, TakeProfit: 110,
+			Confidence: 80}
+	}
+	short := func(symbol string) Decision {
+		d := open(symbol, "open_short")
+		d.StopLoss, d.TakeProfit = 105, 90 // 做空的止损止盈方向相反
+		return d
+	}
+
+	tests := []struct {
+		name       string
+		decisions  []Decision
+		max        int
+		wantValid  string
+		wantReason string // 被拒绝的决策原因（为空表示没有被拒绝的决策）
+	}{
+		{"同批第二个高相关开仓被拒", []Decision{open("SOLUSDT", "open_long"), open("AVAXUSDT", "open_long")}, 2,
+			"[SOLUSDT]", "同方向高相关持仓最多2个"},
+		{"反方向不计入", []Decision{open("SOLUSDT", "open_long"), short("AVAXUSDT")}, 2,
+			"[SOLUSDT AVAXUSDT]", ""},
+		{"同批平仓释放名额", []Decision{{Symbol: "ETHUSDT", Action: "close_long"}, open("SOLUSDT", "open_long"), open("AVAXUSDT", "open_long")}, 2,
+			"[ETHUSDT SOLUSDT AVAXUSDT]", ""},
+		{"上限为1时与已有持仓冲突", []Decision{open("SOLUSDT", "open_long")}, 1,
+			"[]", "ETHUSDT(0.90)"},
+		{"0表示不限", []Decision{open("SOLUSDT", "open_long"), open("AVAXUSDT", "open_long")}, 0,
+			"[SOLUSDT AVAXUSDT]", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := &Context{
+				Account:   AccountInfo{TotalEquity: 1000},
+				Positions: []PositionInfo{{Symbol: "ETHUSDT", Side: "long", MarginUsed: 50}},
+				MarketDataMap: map[string]*market.Data{
+					"SOLUSDT":  {Symbol: "SOLUSDT", CurrentPrice: 100},
+					"AVAXUSDT": {Symbol: "AVAXUSDT", CurrentPrice: 100},
+				},
+				BTCETHLeverage:  5,
+				AltcoinLeverage: 5,
+				Risk:            RiskProfile{MinRiskReward: 2},
+				Correlations: &market.CorrelationMatrix{Correlation: map[string]map[string]float64{
+					"SOLUSDT":  {"ETHUSDT": 0.9, "AVAXUSDT": 0.85},
+					"AVAXUSDT": {"ETHUSDT": 0.9, "SOLUSDT": 0.85},
+				}},
+				CorrelationThreshold:   0.8,
+				MaxCorrelatedPositions: tt.max,
+			}
+
+			valid, rejected := validateDecisions(tt.decisions, ctx)
+			var symbols []string
+			for _, d := range valid {
+				symbols = append(symbols, d.Symbol)
+			}
+			if got := fmt.Sprint(symbols); got != tt.wantValid {
+				t.Errorf("通过 = %s, 期望 %s（拒绝: %+v）", got, tt.wantValid, rejected)
+			}
+			switch {
+			case tt.wantReason == "" && len(rejected) > 0:
+				t.Errorf("期望全部通过, 被拒绝: %+v", rejected)
+			case tt.wantReason != "" && (len(rejected) != 1 || !strings.Contains(rejected[0].Reason, tt.wantReason)):
+				t.Errorf("拒绝 = %+v, 期望原因包含 %q", rejected, tt.wantReason)
+			}
+		})
+	}
+}
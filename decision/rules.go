@@ -0,0 +1,471 @@
+package decision
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"nofx/market"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RuleConfig 规则策略配置（不调用AI，按技术指标给出确定性的决策）
+type RuleConfig struct {
+	Type     string             `json:"type"`             // 策略类型（见 ruleFactories）
+	Interval string             `json:"interval"`         // 信号周期（必须是 timeframes 中配置的周期）
+	Params   map[string]float64 `json:"params,omitempty"` // 策略参数（未配置的使用默认值，见各策略）
+}
+
+// 所有规则策略共用的止损止盈参数
+var ruleStopParams = map[string]float64{
+	"atr_period":  14, // 止损使用的ATR周期（信号周期）
+	"stop_atr":    2,  // 止损距离（ATR倍数）
+	"risk_reward": 0,  // 止盈距离 = 止损距离 × 风险回报比（0或低于风控下限时使用 risk_profile.min_risk_reward）
+}
+
+// ruleSignal 规则在最新一根已收盘K线上的信号
+type ruleSignal struct {
+	entry     string // 开仓方向: "long" / "short"，无信号为空
+	exitLong  bool   // 多头持仓应平仓
+	exitShort bool   // 空头持仓应平仓
+	reason    string // 信号说明（记录在思维链中）
+}
+
+// signalRule 信号规则
+type signalRule interface {
+	// evaluate 基于已收盘K线（旧→新）计算信号
+	evaluate(klines []market.Kline) ruleSignal
+
+	// warmup 计算信号所需的最少K线数量
+	warmup() int
+}
+
+type ruleFactory func(params map[string]float64) (signalRule, error)
+
+// ruleFactories 内置规则策略及其参数默认值
+var ruleFactories = map[string]struct {
+	defaults map[string]float64
+	create   ruleFactory
+}{
+	"ema_crossover": {
+		defaults: map[string]float64{"fast": 9, "slow": 21},
+		create:   newEMACrossoverRule,
+	},
+	"rsi_reversion": {
+		defaults: map[string]float64{"period": 14, "oversold": 30, "overbought": 70, "exit": 50},
+		create:   newRSIReversionRule,
+	},
+	"breakout": {
+		defaults: map[string]float64{"lookback": 20, "exit_lookback": 10},
+		create:   newBreakoutRule,
+	},
+}
+
+// RuleStrategy 规则策略：候选币种出现开仓信号时按ATR设置止损止盈开仓，持仓出现离场信号时平仓
+// 同一根K线上的开仓信号通过验证后只触发一次（平仓后不会在同一根K线上反复开仓；被风控拒绝的信号下个扫描周期重试）
+// 规则信号没有置信度，不检查 risk_profile.min_confidence，其余风控与AI决策一致
+type RuleStrategy struct {
+	name       string
+	interval   string
+	rule       signalRule
+	atrPeriod  int
+	stopATR    float64
+	riskReward float64
+	fired      map[string]int64 // 币种 -> 开仓信号已通过验证的K线开盘时间
+}
+
+// NewRuleStrategy 按配置创建规则策略
+func NewRuleStrategy(cfg RuleConfig) (*RuleStrategy, error) {
+	factory, ok := ruleFactories[cfg.Type]
+	if !ok {
+		types := make([]string, 0, len(ruleFactories))
+		for name := range ruleFactories {
+			types = append(types, name)
+		}
+		sort.Strings(types)
+		return nil, fmt.Errorf("不支持的规则策略 %q（可选: %s）", cfg.Type, strings.Join(types, ", "))
+	}
+	if cfg.Interval == "" {
+		return nil, fmt.Errorf("规则策略必须配置interval")
+	}
+
+	params := make(map[string]float64)
+	for key, value := range ruleStopParams {
+		params[key] = value
+	}
+	for key, value := range factory.defaults {
+		params[key] = value
+	}
+	for key, value := range cfg.Params {
+		if _, known := params[key]; !known {
+			return nil, fmt.Errorf("%s 不支持参数 %q", cfg.Type, key)
+		}
+		if value < 0 {
+			return nil, fmt.Errorf("%s 参数 %s 不能为负数", cfg.Type, key)
+		}
+		params[key] = value
+	}
+
+	rule, err := factory.create(params)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", cfg.Type, err)
+	}
+	if params["atr_period"] < 1 || params["stop_atr"] <= 0 {
+		return nil, fmt.Errorf("%s: atr_period必须≥1且stop_atr必须大于0", cfg.Type)
+	}
+	return &RuleStrategy{
+		name:       cfg.Type,
+		interval:   cfg.Interval,
+		rule:       rule,
+		atrPeriod:  int(params["atr_period"]),
+		stopATR:    params["stop_atr"],
+		riskReward: params["risk_reward"],
+		fired:      make(map[string]int64),
+	}, nil
+}
+
+// Validate 校验规则策略配置（信号周期必须已配置且K线数量足够计算信号）
+func (c RuleConfig) Validate(timeframes []market.TimeframeConfig) error {
+	strategy, err := NewRuleStrategy(c)
+	if err != nil {
+		return err
+	}
+	if len(timeframes) == 0 {
+		timeframes = market.DefaultTimeframes
+	}
+	for _, tf := range timeframes {
+		if tf.Interval != c.Interval {
+			continue
+		}
+		// 最后一根K线可能尚未收盘，不参与计算
+		if need := strategy.warmup() + 1; tf.Limit < need {
+			return fmt.Errorf("%s 周期K线数量(%d)不足，规则策略至少需要%d根", tf.Interval, tf.Limit, need)
+		}
+		return nil
+	}
+	return fmt.Errorf("规则策略的信号周期 %s 不在timeframes中", c.Interval)
+}
+
+// Name 策略名称
+func (s *RuleStrategy) Name() string {
+	return s.name
+}
+
+func (s *RuleStrategy) warmup() int {
+	return max(s.rule.warmup(), s.atrPeriod+1)
+}
+
+// Decide 实现 Strategy
+func (s *RuleStrategy) Decide(ctx *Context) (*FullDecision, error) {
+	if err := fetchMarketDataForContext(ctx); err != nil {
+		return nil, fmt.Errorf("获取市场数据失败: %w", err)
+	}
+	return s.decide(ctx), nil
+}
+
+// decide 基于已获取的市场数据生成并验证决策
+func (s *RuleStrategy) decide(ctx *Context) *FullDecision {
+	risk := ctx.Risk.WithDefaults()
+
+	var (
+		decisions []Decision
+		trace     strings.Builder
+	)
+	fmt.Fprintf(&trace, "规则策略 %s（%s）\n", s.name, s.interval)
+
+	// 1. 持仓：出现离场信号或反向开仓信号时平仓
+	held := make(map[string]bool)
+	for _, pos := range ctx.Positions {
+		held[pos.Symbol] = true
+		klines := s.closedKlines(ctx.MarketDataMap[pos.Symbol])
+		if klines == nil {
+			fmt.Fprintf(&trace, "- %s %s: %s K线不足，继续持有\n", pos.Symbol, pos.Side, s.interval)
+			continue
+		}
+		signal := s.rule.evaluate(klines)
+		exit := (pos.Side == "long" && (signal.exitLong || signal.entry == "short")) ||
+			(pos.Side == "short" && (signal.exitShort || signal.entry == "long"))
+		if !exit {
+			fmt.Fprintf(&trace, "- %s %s: 继续持有（%s）\n", pos.Symbol, pos.Side, signal.reason)
+			continue
+		}
+		fmt.Fprintf(&trace, "- %s %s: 离场（%s）\n", pos.Symbol, pos.Side, signal.reason)
+		decisions = append(decisions, Decision{
+			Symbol:    pos.Symbol,
+			Action:    "close_" + pos.Side,
+			Reasoning: fmt.Sprintf("%s 离场信号: %s", s.name, signal.reason),
+		})
+	}
+
+	// 2. 候选币种：出现开仓信号时按ATR设置止损止盈
+	bars := make(map[string]int64) // 本次开仓信号所在的K线
+	for _, coin := range ctx.CandidateCoins {
+		data := ctx.MarketDataMap[coin.Symbol]
+		if held[coin.Symbol] || data == nil {
+			continue
+		}
+		klines := s.closedKlines(data)
+		if klines == nil {
+			continue
+		}
+		signal := s.rule.evaluate(klines)
+		if signal.entry == "" {
+			continue
+		}
+		bar := klines[len(klines)-1].OpenTime
+		if s.fired[coin.Symbol] == bar {
+			continue // 本根K线的信号已经开过仓
+		}
+
+		d, err := s.entryDecision(ctx, risk, coin.Symbol, signal, data, klines)
+		if err != nil {
+			fmt.Fprintf(&trace, "- %s: %s信号但跳过（%v）\n", coin.Symbol, signal.entry, err)
+			continue
+		}
+		fmt.Fprintf(&trace, "- %s: %s（%s）\n", coin.Symbol, d.Action, signal.reason)
+		decisions = append(decisions, *d)
+		bars[coin.Symbol] = bar
+	}
+	if len(decisions) == 0 {
+		trace.WriteString("无信号，等待\n")
+	}
+	log.Printf("📏 规则策略 %s: %d个信号", s.name, len(decisions))
+
+	// 规则信号没有置信度，显式关闭信心度下限（其余风控参数不变）
+	ruleCtx := *ctx
+	ruleCtx.Risk.MinConfidence = -1
+	valid, rejected := validateDecisions(decisions, &ruleCtx)
+	for _, d := range valid {
+		if isOpenAction(d.Action) {
+			s.fired[d.Symbol] = bars[d.Symbol]
+		}
+	}
+	return &FullDecision{
+		CoTTrace:  strings.TrimSpace(trace.String()),
+		Decisions: valid,
+		Rejected:  rejected,
+		Timestamp: time.Now(),
+	}
+}
+
+// closedKlines 信号周期已收盘的K线（数量不足以计算信号时返回nil）
+func (s *RuleStrategy) closedKlines(data *market.Data) []market.Kline {
+	if data == nil || data.Timeframes[s.interval] == nil {
+		return nil
+	}
+	klines := data.Timeframes[s.interval].Klines
+	if n := len(klines); n > 0 && klines[n-1].CloseTime > time.Now().UnixMilli() {
+		klines = klines[:n-1]
+	}
+	if len(klines) < s.warmup() {
+		return nil
+	}
+	return klines
+}
+
+// entryDecision 开仓决策：以实时价格为入场价，止损为ATR倍数（不低于 risk_profile.min_stop_atr），
+// 止盈按风险回报比设置；杠杆取配置上限和止损不被强平所允许的最大值中的较小者，仓位取风控范围的中值
+func (s *RuleStrategy) entryDecision(ctx *Context, risk RiskProfile, symbol string, signal ruleSignal,
+	data *market.Data, klines []market.Kline) (*Decision, error) {
+	price := data.CurrentPrice
+	if price <= 0 {
+		return nil, fmt.Errorf("缺少实时价格")
+	}
+	atr := latestIndicator(klines, "atr", s.atrPeriod)
+	if math.IsNaN(atr) || atr <= 0 {
+		return nil, fmt.Errorf("ATR无效")
+	}
+
+	stopDistance := s.stopATR * atr
+	if risk.MinStopATR > 0 && data.CurrentATR14 > 0 {
+		stopDistance = math.Max(stopDistance, risk.MinStopATR*data.CurrentATR14)
+	}
+	riskReward := math.Max(s.riskReward, risk.MinRiskReward)
+	if riskReward <= 0 {
+		riskReward = DefaultRiskProfile.MinRiskReward // 策略和风险参数都未限制时仍需要止盈位
+	}
+	reward := stopDistance * riskReward * (1 + 1e-9) // 避免浮点误差导致风险回报比略低于下限
+
+	maxLeverage := ctx.AltcoinLeverage
+	if symbol == "BTCUSDT" || symbol == "ETHUSDT" {
+		maxLeverage = ctx.BTCETHLeverage
+	}
+	// 止损距离必须小于强平距离: stop/price < 1/leverage - maintenanceMarginRate
+	leverage := min(maxLeverage, int(math.Ceil(1/(stopDistance/price+maintenanceMarginRate)))-1)
+	if leverage < 1 {
+		return nil, fmt.Errorf("止损距离%.2f%%过大，任何杠杆都会先被强平", stopDistance/price*100)
+	}
+
+	multiple := risk.PositionMultipleFor(symbol)
+	size := ctx.Account.TotalEquity * (multiple.Min + multiple.Max) / 2
+
+	d := &Decision{
+		Symbol:          symbol,
+		Leverage:        leverage,
+		PositionSizeUSD: size,
+		RiskUSD:         size * stopDistance / price,
+		Reasoning:       fmt.Sprintf("%s: %s | 止损%.1f ATR，R:R %.1f", s.name, signal.reason, stopDistance/atr, riskReward),
+	}
+	if signal.entry == "long" {
+		d.Action = "open_long"
+		d.StopLoss, d.TakeProfit = price-stopDistance, price+reward
+	} else {
+		d.Action = "open_short"
+		d.StopLoss, d.TakeProfit = price+stopDistance, price-reward
+	}
+	return d, nil
+}
+
+// latestIndicator 单线指标在最后一根K线上的值（指标计算复用 market 的指标注册表）
+func latestIndicator(klines []market.Kline, name string, period int) float64 {
+	values := indicatorValues(klines, name, period)
+	if len(values) == 0 {
+		return math.NaN()
+	}
+	return values[len(values)-1]
+}
+
+// indicatorValues 单线指标的完整序列（与K线等长，预热期为NaN）
+func indicatorValues(klines []market.Kline, name string, period int) []float64 {
+	indicator, err := market.NewIndicator(market.IndicatorConfig{Name: name, Params: map[string]float64{"period": float64(period)}})
+	if err != nil {
+		return nil
+	}
+	lines := indicator.Calculate(klines)
+	if len(lines) == 0 {
+		return nil
+	}
+	return lines[0].Values
+}
+
+// emaCrossoverRule EMA交叉：快线上穿慢线做多，下穿做空；快线位于慢线另一侧时离场
+type emaCrossoverRule struct {
+	fast, slow int
+}
+
+func newEMACrossoverRule(params map[string]float64) (signalRule, error) {
+	fast, slow := int(params["fast"]), int(params["slow"])
+	if fast < 1 || fast >= slow {
+		return nil, fmt.Errorf("fast必须≥1且小于slow")
+	}
+	return &emaCrossoverRule{fast: fast, slow: slow}, nil
+}
+
+func (r *emaCrossoverRule) warmup() int { return r.slow + 2 }
+
+func (r *emaCrossoverRule) evaluate(klines []market.Kline) ruleSignal {
+	fast := indicatorValues(klines, "ema", r.fast)
+	slow := indicatorValues(klines, "ema", r.slow)
+	n := len(klines) - 1
+	if len(fast) != len(klines) || len(slow) != len(klines) || math.IsNaN(slow[n-1]) {
+		return ruleSignal{reason: "EMA预热不足"}
+	}
+
+	signal := ruleSignal{
+		exitLong:  fast[n] < slow[n],
+		exitShort: fast[n] > slow[n],
+		reason:    fmt.Sprintf("EMA%d=%.4f EMA%d=%.4f", r.fast, fast[n], r.slow, slow[n]),
+	}
+	if fast[n-1] <= slow[n-1] && fast[n] > slow[n] {
+		signal.entry = "long"
+		signal.reason += " 金叉"
+	} else if fast[n-1] >= slow[n-1] && fast[n] < slow[n] {
+		signal.entry = "short"
+		signal.reason += " 死叉"
+	}
+	return signal
+}
+
+// rsiReversionRule RSI均值回归：RSI从超卖区回升做多，从超买区回落做空；RSI回到中线时离场
+type rsiReversionRule struct {
+	period                     int
+	oversold, overbought, exit float64
+}
+
+func newRSIReversionRule(params map[string]float64) (signalRule, error) {
+	r := &rsiReversionRule{
+		period:     int(params["period"]),
+		oversold:   params["oversold"],
+		overbought: params["overbought"],
+		exit:       params["exit"],
+	}
+	if r.period < 2 {
+		return nil, fmt.Errorf("period必须≥2")
+	}
+	if !(r.oversold < r.exit && r.exit < r.overbought && r.overbought <= 100) {
+		return nil, fmt.Errorf("必须满足 oversold < exit < overbought ≤ 100")
+	}
+	return r, nil
+}
+
+func (r *rsiReversionRule) warmup() int { return r.period + 2 }
+
+func (r *rsiReversionRule) evaluate(klines []market.Kline) ruleSignal {
+	rsi := indicatorValues(klines, "rsi", r.period)
+	n := len(klines) - 1
+	if len(rsi) != len(klines) || math.IsNaN(rsi[n-1]) {
+		return ruleSignal{reason: "RSI预热不足"}
+	}
+
+	signal := ruleSignal{
+		exitLong:  rsi[n] >= r.exit,
+		exitShort: rsi[n] <= r.exit,
+		reason:    fmt.Sprintf("RSI%d %.1f→%.1f", r.period, rsi[n-1], rsi[n]),
+	}
+	if rsi[n-1] < r.oversold && rsi[n] >= r.oversold {
+		signal.entry = "long"
+		signal.reason += fmt.Sprintf(" 回升至超卖线%g以上", r.oversold)
+	} else if rsi[n-1] > r.overbought && rsi[n] <= r.overbought {
+		signal.entry = "short"
+		signal.reason += fmt.Sprintf(" 回落至超买线%g以下", r.overbought)
+	}
+	return signal
+}
+
+// breakoutRule 通道突破：收盘价突破前lookback根K线的最高价做多、跌破最低价做空；
+// 收盘价跌破（升破）前exit_lookback根K线的最低价（最高价）时多头（空头）离场
+type breakoutRule struct {
+	lookback, exitLookback int
+}
+
+func newBreakoutRule(params map[string]float64) (signalRule, error) {
+	r := &breakoutRule{lookback: int(params["lookback"]), exitLookback: int(params["exit_lookback"])}
+	if r.lookback < 2 || r.exitLookback < 1 {
+		return nil, fmt.Errorf("lookback必须≥2且exit_lookback必须≥1")
+	}
+	return r, nil
+}
+
+func (r *breakoutRule) warmup() int { return max(r.lookback, r.exitLookback) + 1 }
+
+func (r *breakoutRule) evaluate(klines []market.Kline) ruleSignal {
+	n := len(klines) - 1
+	last := klines[n].Close
+	high, low := channel(klines[n-r.lookback : n])
+	exitHigh, exitLow := channel(klines[n-r.exitLookback : n])
+
+	signal := ruleSignal{
+		exitLong:  last < exitLow,
+		exitShort: last > exitHigh,
+		reason:    fmt.Sprintf("收盘%.4f，%d根通道 %.4f-%.4f", last, r.lookback, low, high),
+	}
+	if last > high {
+		signal.entry = "long"
+		signal.reason += " 向上突破"
+	} else if last < low {
+		signal.entry = "short"
+		signal.reason += " 向下跌破"
+	}
+	return signal
+}
+
+// channel K线的最高价和最低价
+func channel(klines []market.Kline) (high, low float64) {
+	high, low = math.Inf(-1), math.Inf(1)
+	for _, k := range klines {
+		high = math.Max(high, k.High)
+		low = math.Min(low, k.Low)
+	}
+	return high, low
+}
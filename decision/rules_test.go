@@ -0,0 +1,60 @@
+package decision
+
+import (
+	"testing"
+	"time"
+
+	"nofx/market"
+)
+
+// breakoutKlines 30根已收盘的1h K线，最后一根向上突破前20根的通道
+func breakoutKlines() []market.Kline {
+	d := time.Hour
+	lastOpen := time.Now().Truncate(d).Add(-2 * d)
+	klines := make([]market.Kline, 30)
+	for i := range klines {
+		open := lastOpen.Add(-time.Duration(len(klines)-1-i) * d)
+		klines[i] = market.Kline{OpenTime: open.UnixMilli(), Open: 100, High: 101, Low: 99, Close: 100, CloseTime: open.Add(d).UnixMilli() - 1}
+	}
+	klines[len(klines)-1].High, klines[len(klines)-1].Close = 106, 105
+	return klines
+}
+
+func TestRuleStrategyRetriesRejectedSignal(t *testing.T) {
+	strategy, err := NewRuleStrategy(RuleConfig{Type: "breakout", Interval: "1h"})
+	if err != nil {
+		t.Fatalf("NewRuleStrategy: %v", err)
+	}
+	ctx := &Context{
+		Account:        AccountInfo{TotalEquity: 1000},
+		CandidateCoins: []CandidateCoin{{Symbol: "SOLUSDT"}},
+		MarketDataMap: map[string]*market.Data{"SOLUSDT": {
+			Symbol:       "SOLUSDT",
+			CurrentPrice: 105,
+			Timeframes:   map[string]*market.TimeframeData{"1h": {Interval: "1h", Klines: breakoutKlines()}},
+		}},
+		BTCETHLeverage:  5,
+		AltcoinLeverage: 5,
+		RecentCloses:    map[string]time.Time{"SOLUSDT": time.Now()},
+	}
+
+	// 1. 冷却期内被拒绝，不标记本根K线
+	if full := strategy.decide(ctx); len(full.Decisions) != 0 || len(full.Rejected) != 1 {
+		t.Fatalf("冷却期内期望被拒绝: %+v", full)
+	}
+
+	// 2. 冷却结束后同一根K线的信号重试；规则策略不检查信心度下限
+	delete(ctx.RecentCloses, "SOLUSDT")
+	full := strategy.decide(ctx)
+	if len(full.Decisions) != 1 || full.Decisions[0].Action != "open_long" {
+		t.Fatalf("冷却结束后期望开多: %+v", full)
+	}
+	if full.Decisions[0].Confidence != 0 {
+		t.Errorf("规则信号不应填写置信度: %d", full.Decisions[0].Confidence)
+	}
+
+	// 3. 已开过仓的K线不再触发
+	if full := strategy.decide(ctx); len(full.Decisions) != 0 || len(full.Rejected) != 0 {
+		t.Fatalf("同一根K线重复触发: %+v", full)
+	}
+}
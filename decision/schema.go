@@ -0,0 +1,91 @@
+package decision
+
+import (
+	"encoding/json"
+	"nofx/mcp"
+	"reflect"
+	"strings"
+)
+
+// structuredResponse 结构化输出的响应：思维链 + 决策数组
+type structuredResponse struct {
+	ChainOfThought string            `json:"chain_of_thought"`
+	Decisions      []json.RawMessage `json:"decisions"`
+}
+
+// decisionResponseSchema 结构化输出的schema（决策字段由 Decision 结构体生成）
+var decisionResponseSchema = &mcp.ResponseSchema{
+	Name:        "submit_decisions",
+	Description: "提交本周期的交易决策：先给出思维链分析，再给出决策数组",
+	Schema: map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"chain_of_thought": map[string]interface{}{"type": "string", "description": "思维链分析（简洁）"},
+			"decisions":        map[string]interface{}{"type": "array", "items": schemaOf(reflect.TypeOf(Decision{}))},
+		},
+		"required":             []string{"chain_of_thought", "decisions"},
+		"additionalProperties": false,
+	},
+}
+
+// schemaOf 由Go类型生成JSON Schema
+// 字段名取json tag，没有omitempty的字段为必填，enum tag（逗号分隔）生成枚举
+func schemaOf(t reflect.Type) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem())}
+	case reflect.Ptr:
+		return schemaOf(t.Elem())
+	case reflect.Struct:
+		properties := make(map[string]interface{})
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			tag := field.Tag.Get("json")
+			if tag == "-" || field.PkgPath != "" {
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+			if name == "" {
+				name = field.Name
+			}
+
+			prop := schemaOf(field.Type)
+			if enum := field.Tag.Get("enum"); enum != "" {
+				prop["enum"] = strings.Split(enum, ",")
+			}
+			properties[name] = prop
+			if !strings.Contains(opts, "omitempty") {
+				required = append(required, name)
+			}
+		}
+		return map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"required":             required,
+			"additionalProperties": false,
+		}
+	}
+	return map[string]interface{}{}
+}
+
+// parseStructuredResponse 解析结构化输出（不是结构化输出的JSON对象时返回false，由调用方回退到文本解析）
+func parseStructuredResponse(response string) (*structuredResponse, bool) {
+	trimmed := strings.TrimSpace(response)
+	if !strings.HasPrefix(trimmed, "{") {
+		return nil, false
+	}
+	var result structuredResponse
+	if err := json.Unmarshal([]byte(trimmed), &result); err != nil || result.Decisions == nil {
+		return nil, false
+	}
+	return &result, true
+}
@@ -0,0 +1,30 @@
+package decision
+
+import "nofx/mcp"
+
+// Strategy 决策策略：AutoTrader 每个周期调用 Decide 获取决策
+// AI单次决策、多模型集成、多空辩论和规则策略输出相同的 FullDecision，共用执行、日志和看板
+type Strategy interface {
+	// Decide 获取行情数据并给出本周期的决策（返回的决策需已通过 validateDecisions 验证）
+	Decide(ctx *Context) (*FullDecision, error)
+}
+
+// AIStrategy 单个AI模型的单次决策
+type AIStrategy struct {
+	Client *mcp.Client
+}
+
+// Decide 实现 Strategy
+func (s *AIStrategy) Decide(ctx *Context) (*FullDecision, error) {
+	return GetFullDecision(ctx, s.Client)
+}
+
+// Decide 实现 Strategy（多模型集成）
+func (e *Ensemble) Decide(ctx *Context) (*FullDecision, error) {
+	return GetEnsembleDecision(ctx, e)
+}
+
+// Decide 实现 Strategy（多空辩论流水线）
+func (d *Debate) Decide(ctx *Context) (*FullDecision, error) {
+	return GetDebateDecision(ctx, d)
+}
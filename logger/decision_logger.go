@@ -12,18 +12,27 @@ import (
 
 // DecisionRecord 决策记录
 type DecisionRecord struct {
-	Timestamp      time.Time          `json:"timestamp"`       // 决策时间
-	CycleNumber    int                `json:"cycle_number"`    // 周期编号
-	InputPrompt    string             `json:"input_prompt"`    // 发送给AI的输入prompt
-	CoTTrace       string             `json:"cot_trace"`       // AI思维链（输出）
-	DecisionJSON   string             `json:"decision_json"`   // 决策JSON
-	AccountState   AccountSnapshot    `json:"account_state"`   // 账户状态快照
-	Positions      []PositionSnapshot `json:"positions"`       // 持仓快照
-	CandidateCoins []string           `json:"candidate_coins"` // 候选币种列表
-	Decisions      []DecisionAction   `json:"decisions"`       // 执行的决策
-	ExecutionLog   []string           `json:"execution_log"`   // 执行日志
-	Success        bool               `json:"success"`         // 是否成功
-	ErrorMessage   string             `json:"error_message"`   // 错误信息（如果有）
+	Timestamp         time.Time          `json:"timestamp"`                     // 决策时间
+	CycleNumber       int                `json:"cycle_number"`                  // 周期编号
+	InputPrompt       string             `json:"input_prompt"`                  // 发送给AI的输入prompt
+	CoTTrace          string             `json:"cot_trace"`                     // AI思维链（输出）
+	DecisionJSON      string             `json:"decision_json"`                 // 决策JSON
+	AccountState      AccountSnapshot    `json:"account_state"`                 // 账户状态快照
+	Positions         []PositionSnapshot `json:"positions"`                     // 持仓快照
+	CandidateCoins    []string           `json:"candidate_coins"`               // 候选币种列表
+	MarketDataErrors  map[string]string  `json:"market_data_errors,omitempty"`  // 获取行情失败的币种及原因
+	DataQualityIssues map[string]string  `json:"data_quality_issues,omitempty"` // 行情数据质量不合格（排除交易）的币种及原因
+	FilteredCoins     map[string]string  `json:"filtered_coins,omitempty"`      // 被过滤链排除的候选币种 -> "过滤器: 原因"
+	Decisions         []DecisionAction   `json:"decisions"`                     // 执行的决策
+	Rejected          []RejectedDecision `json:"rejected_decisions,omitempty"`  // 未通过验证（未执行）的决策
+	Corrections       []CorrectionRound  `json:"corrections,omitempty"`         // 校验失败后让AI修正的各轮记录
+	EnsemblePolicy    string             `json:"ensemble_policy,omitempty"`     // 多模型集成的合并策略
+	ModelOutputs      []ModelOutput      `json:"model_outputs,omitempty"`       // 多模型集成时各模型的原始输出
+	EnsembleVotes     []EnsembleVote     `json:"ensemble_votes,omitempty"`      // 多模型集成的投票结果（合并后的决策见DecisionJSON）
+	Debate            []DebateStage      `json:"debate,omitempty"`              // 多空辩论流水线各阶段的输出（裁判的输入见InputPrompt）
+	ExecutionLog      []string           `json:"execution_log"`                 // 执行日志
+	Success           bool               `json:"success"`                       // 是否成功
+	ErrorMessage      string             `json:"error_message"`                 // 错误信息（如果有）
 }
 
 // AccountSnapshot 账户状态快照
@@ -47,6 +56,51 @@ type PositionSnapshot struct {
 	LiquidationPrice float64 `json:"liquidation_price"`
 }
 
+// RejectedDecision 未通过验证的决策
+type RejectedDecision struct {
+	Symbol string `json:"symbol"` // 币种
+	Action string `json:"action"` // 操作
+	Reason string `json:"reason"` // 拒绝原因
+}
+
+// CorrectionRound 一轮决策修正
+type CorrectionRound struct {
+	Round    int                `json:"round"`              // 第几轮
+	Feedback string             `json:"feedback"`           // 发送给AI的修正提示
+	Response string             `json:"response"`           // AI的修正输出
+	Error    string             `json:"error,omitempty"`    // 调用或解析失败的原因
+	Rejected []RejectedDecision `json:"rejected,omitempty"` // 修正后仍未通过验证的决策
+}
+
+// ModelOutput 多模型集成中单个模型的输出
+type ModelOutput struct {
+	Model        string             `json:"model"`                 // 模型名称
+	Response     string             `json:"response"`              // 原始输出
+	DecisionJSON string             `json:"decision_json"`         // 通过验证的决策JSON
+	Rejected     []RejectedDecision `json:"rejected,omitempty"`    // 未通过验证的决策
+	Corrections  []CorrectionRound  `json:"corrections,omitempty"` // 该模型的修正记录
+	Error        string             `json:"error,omitempty"`       // 调用或解析失败的原因
+}
+
+// EnsembleVote 多模型集成中一个决策的投票结果
+type EnsembleVote struct {
+	Symbol  string   `json:"symbol"`         // 币种
+	Action  string   `json:"action"`         // 操作
+	Models  []string `json:"models"`         // 给出该决策的模型
+	Support float64  `json:"support"`        // 得票
+	Total   float64  `json:"total"`          // 满票
+	Adopted bool     `json:"adopted"`        // 是否采纳
+	Note    string   `json:"note,omitempty"` // 未采纳的原因
+}
+
+// DebateStage 多空辩论中一个阶段的输出
+type DebateStage struct {
+	Stage    string `json:"stage"`           // bull / bear / judge
+	Model    string `json:"model"`           // 模型名称
+	Response string `json:"response"`        // 该阶段的原始输出
+	Error    string `json:"error,omitempty"` // 调用或解析失败的原因
+}
+
 // DecisionAction 决策动作
 type DecisionAction struct {
 	Action    string    `json:"action"`    // open_long, open_short, close_long, close_short
@@ -14,6 +14,14 @@ import (
 )
 
 func main() {
+	// 子命令: nofx data download ...
+	if len(os.Args) > 1 && os.Args[1] == "data" {
+		if err := runDataCommand(os.Args[2:]); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		return
+	}
+
 	fmt.Println("╔════════════════════════════════════════════════════════════╗")
 	fmt.Println("║    🏆 AI模型交易竞赛系统 - Qwen vs DeepSeek               ║")
 	fmt.Println("╚════════════════════════════════════════════════════════════╝")
@@ -34,6 +42,9 @@ func main() {
 	log.Printf("✓ 配置加载成功，共%d个trader参赛", len(cfg.Traders))
 	fmt.Println()
 
+	// 本地历史行情（由 nofx data download 生成）
+	useLocalMarketStore(cfg.MarketDataDir)
+
 	// 设置默认主流币种列表
 	pool.SetDefaultCoins(cfg.DefaultCoins)
 
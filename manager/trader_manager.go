@@ -42,6 +42,9 @@ func (tm *TraderManager) AddTrader(cfg config.TraderConfig, coinPoolURL string,
 		HyperliquidPrivateKey: cfg.HyperliquidPrivateKey,
 		HyperliquidWalletAddr: cfg.HyperliquidWalletAddr,
 		HyperliquidTestnet:    cfg.HyperliquidTestnet,
+		MarketDataFallback:    cfg.MarketDataFallback,
+		Timeframes:            cfg.Timeframes,
+		MaxImpactPct:          cfg.MaxImpactPct,
 		AsterUser:             cfg.AsterUser,
 		AsterSigner:           cfg.AsterSigner,
 		AsterPrivateKey:       cfg.AsterPrivateKey,
@@ -52,6 +55,12 @@ func (tm *TraderManager) AddTrader(cfg config.TraderConfig, coinPoolURL string,
 		CustomAPIURL:          cfg.CustomAPIURL,
 		CustomAPIKey:          cfg.CustomAPIKey,
 		CustomModelName:       cfg.CustomModelName,
+		AIOutputMode:          cfg.AIOutputMode,
+		Ensemble:              cfg.Ensemble,
+		Pipeline:              cfg.Pipeline,
+		Debate:                cfg.Debate,
+		Strategy:              cfg.Strategy,
+		Memory:                cfg.Memory,
 		ScanInterval:          cfg.GetScanInterval(),
 		InitialBalance:        cfg.InitialBalance,
 		BTCETHLeverage:        leverage.BTCETHLeverage,  // 使用配置的杠杆倍数
@@ -59,6 +68,15 @@ func (tm *TraderManager) AddTrader(cfg config.TraderConfig, coinPoolURL string,
 		MaxDailyLoss:          maxDailyLoss,
 		MaxDrawdown:           maxDrawdown,
 		StopTradingTime:       time.Duration(stopTradingMinutes) * time.Minute,
+
+		MaxCorrelatedPositions: cfg.MaxCorrelatedPositions,
+		CorrelationThreshold:   cfg.CorrelationThreshold,
+		RegimeRules:            cfg.RegimeRules,
+		Filters:                cfg.Filters,
+		SystemPromptTemplate:   cfg.SystemPromptTemplate,
+		UserPromptTemplate:     cfg.UserPromptTemplate,
+		RiskProfile:            cfg.RiskProfile,
+		MaxCorrectionRounds:    cfg.MaxCorrectionRounds,
 	}
 
 	// 创建trader实例
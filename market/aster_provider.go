@@ -0,0 +1,48 @@
+package market
+
+import (
+	"context"
+	"fmt"
+)
+
+// AsterProvider Aster行情数据源（接口与币安合约兼容）
+type AsterProvider struct {
+	BinanceProvider
+}
+
+// NewAsterProvider 创建Aster行情数据源
+func NewAsterProvider() *AsterProvider {
+	return &AsterProvider{
+		BinanceProvider: BinanceProvider{
+			baseURL: "https://fapi.asterdex.com",
+			wsURL:   "wss://fstream.asterdex.com/stream",
+		},
+	}
+}
+
+// Name 数据源名称
+func (p *AsterProvider) Name() string {
+	return "aster"
+}
+
+// WithContext 返回绑定请求上下文的副本
+func (p *AsterProvider) WithContext(ctx context.Context) Provider {
+	cp := *p
+	cp.ctx = ctx
+	return &cp
+}
+
+// GetOpenInterestHistory Aster未提供历史持仓量统计接口
+func (p *AsterProvider) GetOpenInterestHistory(symbol, period string, limit int) ([]OIPoint, error) {
+	return nil, fmt.Errorf("%w: aster 历史持仓量", ErrNotSupported)
+}
+
+// GetLongShortRatio Aster未提供多空比统计接口
+func (p *AsterProvider) GetLongShortRatio(symbol, scope, period string, limit int) ([]LongShortPoint, error) {
+	return nil, fmt.Errorf("%w: aster 多空比", ErrNotSupported)
+}
+
+// GetTakerVolume Aster未提供主动买卖量统计接口
+func (p *AsterProvider) GetTakerVolume(symbol, period string, limit int) ([]TakerVolumePoint, error) {
+	return nil, fmt.Errorf("%w: aster 主动买卖量", ErrNotSupported)
+}
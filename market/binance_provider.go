@@ -0,0 +1,384 @@
+package market
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// BinanceProvider 币安合约行情数据源
+type BinanceProvider struct {
+	baseURL string
+	wsURL   string
+	ctx     context.Context // 请求上下文（见 WithContext）
+}
+
+// NewBinanceProvider 创建币安行情数据源
+func NewBinanceProvider() *BinanceProvider {
+	return &BinanceProvider{
+		baseURL: "https://fapi.binance.com",
+		wsURL:   "wss://fstream.binance.com/stream",
+	}
+}
+
+// Name 数据源名称
+func (p *BinanceProvider) Name() string {
+	return "binance"
+}
+
+// WithContext 返回绑定请求上下文的副本
+func (p *BinanceProvider) WithContext(ctx context.Context) Provider {
+	cp := *p
+	cp.ctx = ctx
+	return &cp
+}
+
+// klineStreamCodec K线推送协议
+func (p *BinanceProvider) klineStreamCodec() klineStreamCodec {
+	return &binanceStreamCodec{wsURL: p.wsURL}
+}
+
+// GetKlines 从币安获取K线数据
+func (p *BinanceProvider) GetKlines(symbol, interval string, limit int) ([]Kline, error) {
+	url := fmt.Sprintf("%s/fapi/v1/klines?symbol=%s&interval=%s&limit=%d",
+		p.baseURL, symbol, interval, limit)
+	return p.getKlines(url)
+}
+
+// getKlines 请求并解析K线数据
+func (p *BinanceProvider) getKlines(url string) ([]Kline, error) {
+	body, err := p.get(url)
+	if err != nil {
+		return nil, err
+	}
+
+	var rawData [][]interface{}
+	if err := json.Unmarshal(body, &rawData); err != nil {
+		return nil, err
+	}
+
+	klines := make([]Kline, len(rawData))
+	for i, item := range rawData {
+		openTime := int64(item[0].(float64))
+		open, _ := parseFloat(item[1])
+		high, _ := parseFloat(item[2])
+		low, _ := parseFloat(item[3])
+		close, _ := parseFloat(item[4])
+		volume, _ := parseFloat(item[5])
+		closeTime := int64(item[6].(float64))
+
+		klines[i] = Kline{
+			OpenTime:  openTime,
+			Open:      open,
+			High:      high,
+			Low:       low,
+			Close:     close,
+			Volume:    volume,
+			CloseTime: closeTime,
+		}
+	}
+
+	return klines, nil
+}
+
+// GetOpenInterest 从币安获取持仓量
+func (p *BinanceProvider) GetOpenInterest(symbol string) (*OIData, error) {
+	url := fmt.Sprintf("%s/fapi/v1/openInterest?symbol=%s", p.baseURL, symbol)
+
+	body, err := p.get(url)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		OpenInterest string `json:"openInterest"`
+		Symbol       string `json:"symbol"`
+		Time         int64  `json:"time"`
+	}
+
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+
+	oi, _ := strconv.ParseFloat(result.OpenInterest, 64)
+
+	return &OIData{Latest: oi}, nil
+}
+
+// binanceOIPeriods /futures/data 统计接口（持仓量、多空比、主动买卖量）支持的周期
+var binanceOIPeriods = map[string]bool{
+	"5m": true, "15m": true, "30m": true, "1h": true, "2h": true,
+	"4h": true, "6h": true, "12h": true, "1d": true,
+}
+
+// GetOpenInterestHistory 从币安获取历史持仓量（仅保留最近30天，limit最大500）
+func (p *BinanceProvider) GetOpenInterestHistory(symbol, period string, limit int) ([]OIPoint, error) {
+	if !binanceOIPeriods[period] {
+		return nil, fmt.Errorf("%w: 持仓量统计周期 %s", ErrNotSupported, period)
+	}
+	if limit > 500 {
+		limit = 500
+	}
+
+	url := fmt.Sprintf("%s/futures/data/openInterestHist?symbol=%s&period=%s&limit=%d",
+		p.baseURL, symbol, period, limit)
+	return p.getOpenInterestHistory(url)
+}
+
+// getOpenInterestHistory 请求并解析历史持仓量
+func (p *BinanceProvider) getOpenInterestHistory(url string) ([]OIPoint, error) {
+	body, err := p.get(url)
+	if err != nil {
+		return nil, err
+	}
+
+	var rawData []struct {
+		SumOpenInterest      string      `json:"sumOpenInterest"`
+		SumOpenInterestValue string      `json:"sumOpenInterestValue"`
+		Timestamp            interface{} `json:"timestamp"`
+	}
+	if err := json.Unmarshal(body, &rawData); err != nil {
+		return nil, err
+	}
+
+	history := make([]OIPoint, len(rawData))
+	for i, item := range rawData {
+		ts, _ := parseFloat(item.Timestamp)
+		oi, _ := strconv.ParseFloat(item.SumOpenInterest, 64)
+		value, _ := strconv.ParseFloat(item.SumOpenInterestValue, 64)
+		history[i] = OIPoint{Time: int64(ts), OpenInterest: oi, Value: value}
+	}
+
+	return history, nil
+}
+
+// GetFunding 从币安获取资金费率、标记价格和指数价格
+func (p *BinanceProvider) GetFunding(symbol string) (*FundingData, error) {
+	url := fmt.Sprintf("%s/fapi/v1/premiumIndex?symbol=%s", p.baseURL, symbol)
+
+	body, err := p.get(url)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Symbol          string `json:"symbol"`
+		MarkPrice       string `json:"markPrice"`
+		IndexPrice      string `json:"indexPrice"`
+		LastFundingRate string `json:"lastFundingRate"`
+		NextFundingTime int64  `json:"nextFundingTime"`
+		InterestRate    string `json:"interestRate"`
+		Time            int64  `json:"time"`
+	}
+
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+
+	rate, _ := strconv.ParseFloat(result.LastFundingRate, 64)
+	markPrice, _ := strconv.ParseFloat(result.MarkPrice, 64)
+	indexPrice, _ := strconv.ParseFloat(result.IndexPrice, 64)
+
+	return &FundingData{
+		Rate:            rate,
+		IntervalHours:   8, // 默认8小时，实际间隔由历史结算时间修正
+		NextFundingTime: result.NextFundingTime,
+		MarkPrice:       markPrice,
+		IndexPrice:      indexPrice,
+	}, nil
+}
+
+// GetFundingHistory 从币安获取已结算的资金费率历史
+func (p *BinanceProvider) GetFundingHistory(symbol string, limit int) ([]FundingPoint, error) {
+	url := fmt.Sprintf("%s/fapi/v1/fundingRate?symbol=%s&limit=%d", p.baseURL, symbol, limit)
+	return p.getFundingHistory(url)
+}
+
+// getFundingHistory 请求并解析资金费率历史
+func (p *BinanceProvider) getFundingHistory(url string) ([]FundingPoint, error) {
+	body, err := p.get(url)
+	if err != nil {
+		return nil, err
+	}
+
+	var rawData []struct {
+		FundingRate string `json:"fundingRate"`
+		FundingTime int64  `json:"fundingTime"`
+	}
+	if err := json.Unmarshal(body, &rawData); err != nil {
+		return nil, err
+	}
+
+	history := make([]FundingPoint, len(rawData))
+	for i, item := range rawData {
+		rate, _ := strconv.ParseFloat(item.FundingRate, 64)
+		history[i] = FundingPoint{Time: item.FundingTime, Rate: rate}
+	}
+
+	return history, nil
+}
+
+// GetLongShortRatio 从币安获取多空账户比（统计周期同openInterestHist，仅保留最近30天）
+func (p *BinanceProvider) GetLongShortRatio(symbol, scope, period string, limit int) ([]LongShortPoint, error) {
+	if !binanceOIPeriods[period] {
+		return nil, fmt.Errorf("%w: 多空比统计周期 %s", ErrNotSupported, period)
+	}
+
+	var endpoint string
+	switch scope {
+	case LongShortTopTraders:
+		endpoint = "topLongShortAccountRatio"
+	case LongShortGlobal:
+		endpoint = "globalLongShortAccountRatio"
+	default:
+		return nil, fmt.Errorf("无效的多空比范围: %s", scope)
+	}
+
+	url := fmt.Sprintf("%s/futures/data/%s?symbol=%s&period=%s&limit=%d",
+		p.baseURL, endpoint, symbol, period, limit)
+
+	body, err := p.get(url)
+	if err != nil {
+		return nil, err
+	}
+
+	var rawData []struct {
+		LongShortRatio string      `json:"longShortRatio"`
+		LongAccount    string      `json:"longAccount"`
+		ShortAccount   string      `json:"shortAccount"`
+		Timestamp      interface{} `json:"timestamp"`
+	}
+	if err := json.Unmarshal(body, &rawData); err != nil {
+		return nil, err
+	}
+
+	points := make([]LongShortPoint, len(rawData))
+	for i, item := range rawData {
+		ts, _ := parseFloat(item.Timestamp)
+		ratio, _ := strconv.ParseFloat(item.LongShortRatio, 64)
+		long, _ := strconv.ParseFloat(item.LongAccount, 64)
+		short, _ := strconv.ParseFloat(item.ShortAccount, 64)
+		points[i] = LongShortPoint{Time: int64(ts), Ratio: ratio, LongAccount: long, ShortAccount: short}
+	}
+
+	return points, nil
+}
+
+// GetTakerVolume 从币安获取主动买卖量
+func (p *BinanceProvider) GetTakerVolume(symbol, period string, limit int) ([]TakerVolumePoint, error) {
+	if !binanceOIPeriods[period] {
+		return nil, fmt.Errorf("%w: 主动买卖量统计周期 %s", ErrNotSupported, period)
+	}
+
+	url := fmt.Sprintf("%s/futures/data/takerlongshortRatio?symbol=%s&period=%s&limit=%d",
+		p.baseURL, symbol, period, limit)
+
+	body, err := p.get(url)
+	if err != nil {
+		return nil, err
+	}
+
+	var rawData []struct {
+		BuySellRatio string      `json:"buySellRatio"`
+		BuyVol       string      `json:"buyVol"`
+		SellVol      string      `json:"sellVol"`
+		Timestamp    interface{} `json:"timestamp"`
+	}
+	if err := json.Unmarshal(body, &rawData); err != nil {
+		return nil, err
+	}
+
+	points := make([]TakerVolumePoint, len(rawData))
+	for i, item := range rawData {
+		ts, _ := parseFloat(item.Timestamp)
+		ratio, _ := strconv.ParseFloat(item.BuySellRatio, 64)
+		buy, _ := strconv.ParseFloat(item.BuyVol, 64)
+		sell, _ := strconv.ParseFloat(item.SellVol, 64)
+		points[i] = TakerVolumePoint{Time: int64(ts), BuyVolume: buy, SellVolume: sell, BuySellRatio: ratio}
+	}
+
+	return points, nil
+}
+
+// GetLiquidations 从全市场强平推送获取统计（首次调用时建立连接）
+func (p *BinanceProvider) GetLiquidations(symbol string) (*LiquidationStats, error) {
+	url := strings.TrimSuffix(p.wsURL, "/stream") + "/ws/!forceOrder@arr"
+	return sharedLiquidationFeed(url).Stats(symbol)
+}
+
+// binanceDepthLimits depth接口支持的档位数
+var binanceDepthLimits = []int{5, 10, 20, 50, 100, 500, 1000}
+
+// GetOrderBook 从币安获取订单簿快照
+func (p *BinanceProvider) GetOrderBook(symbol string, limit int) (*OrderBook, error) {
+	// 取不小于limit的最小合法档位数
+	depth := binanceDepthLimits[len(binanceDepthLimits)-1]
+	for _, l := range binanceDepthLimits {
+		if l >= limit {
+			depth = l
+			break
+		}
+	}
+
+	url := fmt.Sprintf("%s/fapi/v1/depth?symbol=%s&limit=%d", p.baseURL, symbol, depth)
+
+	body, err := p.get(url)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Time int64       `json:"T"`
+		Bids [][2]string `json:"bids"`
+		Asks [][2]string `json:"asks"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+
+	return &OrderBook{
+		Bids: parseBinanceLevels(result.Bids),
+		Asks: parseBinanceLevels(result.Asks),
+		Time: result.Time,
+	}, nil
+}
+
+// parseBinanceLevels 解析 [价格, 数量] 格式的盘口档位
+func parseBinanceLevels(raw [][2]string) []BookLevel {
+	levels := make([]BookLevel, 0, len(raw))
+	for _, item := range raw {
+		price, _ := strconv.ParseFloat(item[0], 64)
+		qty, _ := strconv.ParseFloat(item[1], 64)
+		levels = append(levels, BookLevel{Price: price, Quantity: qty})
+	}
+	return levels
+}
+
+// get 发送GET请求并检查状态码
+func (p *BinanceProvider) get(url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(requestContext(p.ctx), http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(body))
+	}
+
+	return body, nil
+}
@@ -0,0 +1,211 @@
+package market
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// BTCSymbol 计算beta和市场状态使用的基准币种
+	BTCSymbol = "BTCUSDT"
+
+	correlationWindow     = 60 // 计算相关性使用的最大收益率样本数
+	correlationMinSamples = 20 // 重叠样本少于该值时不计算
+)
+
+// CorrelationMatrix 币种间滚动收益率相关性和相对BTC的beta
+type CorrelationMatrix struct {
+	Interval    string                        // 使用的K线周期
+	Symbols     []string                      // 参与计算的币种（BTC在前，其余按字母序）
+	Correlation map[string]map[string]float64 // 两两相关系数（样本不足的币对不存在）
+	Beta        map[string]float64            // 相对BTC的beta（无BTC数据时为空）
+	Samples     map[string]int                // 各币种与BTC的重叠样本数
+}
+
+// Corr 获取两个币种的相关系数（同一币种返回1）
+func (m *CorrelationMatrix) Corr(a, b string) (float64, bool) {
+	if m == nil {
+		return 0, false
+	}
+	if a == b {
+		return 1, true
+	}
+	c, ok := m.Correlation[a][b]
+	return c, ok
+}
+
+// CorrelationInterval 选择计算相关性的K线周期：覆盖时间最长的周期（样本跨度越长，beta越稳定）
+func CorrelationInterval(timeframes []TimeframeConfig) string {
+	if len(timeframes) == 0 {
+		timeframes = DefaultTimeframes
+	}
+	return longestInterval(timeframes)
+}
+
+// ComputeCorrelations 根据各币种指定周期的K线计算滚动收益率相关性和BTC beta
+// 只使用已收盘的K线，收益率按开盘时间对齐，每个币对取最近 correlationWindow 个重叠样本
+func ComputeCorrelations(dataMap map[string]*Data, interval string) *CorrelationMatrix {
+	now := time.Now()
+	returns := make(map[string]map[int64]float64)
+	for symbol, data := range dataMap {
+		tf, ok := data.Timeframes[interval]
+		if !ok {
+			continue
+		}
+		if r := logReturns(closedKlines(tf.Klines, now)); len(r) >= correlationMinSamples {
+			returns[symbol] = r
+		}
+	}
+
+	m := &CorrelationMatrix{
+		Interval:    interval,
+		Correlation: make(map[string]map[string]float64),
+		Beta:        make(map[string]float64),
+		Samples:     make(map[string]int),
+	}
+	for symbol := range returns {
+		m.Symbols = append(m.Symbols, symbol)
+	}
+	sort.Slice(m.Symbols, func(i, j int) bool {
+		if (m.Symbols[i] == BTCSymbol) != (m.Symbols[j] == BTCSymbol) {
+			return m.Symbols[i] == BTCSymbol
+		}
+		return m.Symbols[i] < m.Symbols[j]
+	})
+
+	for i, a := range m.Symbols {
+		for _, b := range m.Symbols[i+1:] {
+			x, y := alignReturns(returns[a], returns[b])
+			if len(x) < correlationMinSamples {
+				continue
+			}
+			c := pearson(x, y)
+			if m.Correlation[a] == nil {
+				m.Correlation[a] = make(map[string]float64)
+			}
+			if m.Correlation[b] == nil {
+				m.Correlation[b] = make(map[string]float64)
+			}
+			m.Correlation[a][b] = c
+			m.Correlation[b][a] = c
+
+			if a == BTCSymbol {
+				m.Beta[b] = beta(y, x)
+				m.Samples[b] = len(x)
+			}
+		}
+	}
+
+	return m
+}
+
+// logReturns 计算对数收益率（键为K线开盘时间）
+func logReturns(klines []Kline) map[int64]float64 {
+	returns := make(map[int64]float64, len(klines))
+	for i := 1; i < len(klines); i++ {
+		if klines[i-1].Close > 0 && klines[i].Close > 0 {
+			returns[klines[i].OpenTime] = math.Log(klines[i].Close / klines[i-1].Close)
+		}
+	}
+	return returns
+}
+
+// alignReturns 取两个收益率序列最近 correlationWindow 个共同时间点
+func alignReturns(a, b map[int64]float64) ([]float64, []float64) {
+	times := make([]int64, 0, len(a))
+	for t := range a {
+		if _, ok := b[t]; ok {
+			times = append(times, t)
+		}
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	if len(times) > correlationWindow {
+		times = times[len(times)-correlationWindow:]
+	}
+
+	x := make([]float64, len(times))
+	y := make([]float64, len(times))
+	for i, t := range times {
+		x[i], y[i] = a[t], b[t]
+	}
+	return x, y
+}
+
+// pearson 皮尔逊相关系数
+func pearson(x, y []float64) float64 {
+	mx, my := mean(x), mean(y)
+	var cov, vx, vy float64
+	for i := range x {
+		dx, dy := x[i]-mx, y[i]-my
+		cov += dx * dy
+		vx += dx * dx
+		vy += dy * dy
+	}
+	if vx == 0 || vy == 0 {
+		return 0
+	}
+	return cov / math.Sqrt(vx*vy)
+}
+
+// beta 资产收益率y相对基准收益率x的beta = cov(x,y) / var(x)
+func beta(y, x []float64) float64 {
+	mx, my := mean(x), mean(y)
+	var cov, vx float64
+	for i := range x {
+		cov += (x[i] - mx) * (y[i] - my)
+		vx += (x[i] - mx) * (x[i] - mx)
+	}
+	if vx == 0 {
+		return 0
+	}
+	return cov / vx
+}
+
+func mean(values []float64) float64 {
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// FormatCorrelations 紧凑格式输出：每个币种的BTC beta和相关性最高的币种
+func FormatCorrelations(m *CorrelationMatrix, threshold float64) string {
+	if m == nil || len(m.Symbols) < 2 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Return correlation (%s, last %d returns) — beta vs BTC | peers with corr ≥ %.2f:\n",
+		m.Interval, correlationWindow, threshold))
+	for _, symbol := range m.Symbols {
+		if symbol == BTCSymbol {
+			continue
+		}
+		line := symbol + ":"
+		if b, ok := m.Beta[symbol]; ok {
+			line += fmt.Sprintf(" beta %.2f, corr(BTC) %.2f", b, m.Correlation[symbol][BTCSymbol])
+		} else {
+			line += " beta n/a"
+		}
+
+		var peers []string
+		for _, other := range m.Symbols {
+			if other == symbol || other == BTCSymbol {
+				continue
+			}
+			if c, ok := m.Correlation[symbol][other]; ok && c >= threshold {
+				peers = append(peers, fmt.Sprintf("%s %.2f", other, c))
+			}
+		}
+		if len(peers) > 0 {
+			line += " | " + strings.Join(peers, ", ")
+		}
+		sb.WriteString(line + "\n")
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
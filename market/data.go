@@ -1,55 +1,88 @@
 package market
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io/ioutil"
 	"math"
-	"net/http"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Data 市场数据结构
 type Data struct {
-	Symbol            string
-	CurrentPrice      float64
-	PriceChange1h     float64 // 1小时价格变化百分比
-	PriceChange4h     float64 // 4小时价格变化百分比
-	CurrentEMA20      float64
-	CurrentMACD       float64
-	CurrentRSI7       float64
-	OpenInterest      *OIData
-	FundingRate       float64
-	IntradaySeries    *IntradayData
-	LongerTermContext *LongerTermData
+	Symbol         string
+	CurrentPrice   float64
+	PriceChange1h  float64 // 1小时价格变化百分比
+	PriceChange4h  float64 // 4小时价格变化百分比
+	CurrentEMA20   float64 // 基于最短周期
+	CurrentMACD    float64 // 基于最短周期
+	CurrentRSI7    float64 // 基于最短周期
+	CurrentATR14   float64 // 基于最短周期（止损距离按ATR衡量）
+	OpenInterest   *OIData
+	FundingRate    float64
+	Funding        *FundingData              // 资金费率历史、结算倒计时和基差（获取失败时为nil）
+	Depth          *DepthData                // 盘口深度（获取失败时为nil）
+	Positioning    *PositioningData          // 多空账户比和主动买卖量（数据源不支持时为nil）
+	Liquidations   *LiquidationStats         // 强平统计（推送未连接或数据源不支持时为nil）
+	Regime         *RegimeData               // 市场状态（基于覆盖时间最长的周期，K线不足时为nil）
+	QualityIssues  []string                  // 数据质量问题（K线过期、缺口、零成交），非空时不应开仓
+	QuoteVolume24h float64                   // 近24小时成交额（USD，由K线估算，周期覆盖不足24小时时为0）
+	ListedSince    time.Time                 // 最早K线时间（K线少于请求数量时视为上线时间，否则为零值）
+	Timeframes     map[string]*TimeframeData // 周期 -> 序列数据
+	TimeframeOrder []string                  // 周期渲染顺序（与配置一致）
 }
 
 // OIData Open Interest数据
 type OIData struct {
-	Latest  float64
-	Average float64
+	Latest     float64 // 当前持仓量（币数）
+	Value      float64 // 当前持仓价值（USD）= 持仓量 × 当前价格
+	Average    float64 // 近24小时平均持仓量（无历史数据时为0）
+	Change1h   float64 // 持仓量1小时变化百分比
+	Change4h   float64 // 持仓量4小时变化百分比
+	Change24h  float64 // 持仓量24小时变化百分比
+	HasHistory bool    // 是否有历史数据（Average和变化百分比是否有效）
 }
 
-// IntradayData 日内数据(3分钟间隔)
-type IntradayData struct {
-	MidPrices   []float64
-	EMA20Values []float64
-	MACDValues  []float64
-	RSI7Values  []float64
-	RSI14Values []float64
+// OIPoint 历史持仓量数据点
+type OIPoint struct {
+	Time         int64   // 统计时间（毫秒）
+	OpenInterest float64 // 持仓量（币数）
+	Value        float64 // 持仓价值（USD）
 }
 
-// LongerTermData 长期数据(4小时时间框架)
-type LongerTermData struct {
-	EMA20         float64
-	EMA50         float64
-	ATR3          float64
-	ATR14         float64
-	CurrentVolume float64
-	AverageVolume float64
-	MACDValues    []float64
-	RSI14Values   []float64
+const (
+	oiHistoryPeriod     = "15m" // 历史持仓量的统计周期（各周期序列和1h/4h/24h变化都由这一份数据推导）
+	oiHistoryFinePeriod = "5m"  // 配置了不能被15m整除的周期（如5m）时改用的统计周期
+	oiHistoryMaxLimit   = 500   // 单次请求的最大点数（币安上限）
+	oiStatsWindow       = 24 * time.Hour
+)
+
+// TimeframeData 单个时间框架的序列数据
+type TimeframeData struct {
+	Interval     string
+	Klines       []Kline           // 原始K线（供后续分析使用）
+	ClosePrices  []float64         // 最近N个收盘价（旧→新）
+	OpenInterest []float64         // 最近N个周期的持仓量（旧→新，数据源不支持该周期时为空）
+	Indicators   []IndicatorSeries // 按配置顺序的指标序列
+}
+
+// IndicatorSeries 单个指标的序列
+type IndicatorSeries struct {
+	Name   string    // 指标Key，多输出线指标为 "Key.线名"，如 "ema20"、"bollinger20.upper"
+	Label  string    // 展示名称，如 "EMA (20‑period)"
+	Values []float64 // 最近N个值（旧→新）
+}
+
+// Latest 获取指定指标的最新值
+func (t *TimeframeData) Latest(name string) (float64, bool) {
+	for _, ind := range t.Indicators {
+		if ind.Name == name && len(ind.Values) > 0 {
+			return ind.Values[len(ind.Values)-1], true
+		}
+	}
+	return 0, false
 }
 
 // Kline K线数据
@@ -63,122 +96,346 @@ type Kline struct {
 	CloseTime int64
 }
 
-// Get 获取指定代币的市场数据
+// TimeframeConfig 单个时间框架的配置
+type TimeframeConfig struct {
+	Interval     string            `json:"interval"`                // K线周期: 1m/3m/5m/15m/30m/1h/2h/4h/6h/8h/12h/1d/3d/1w
+	Limit        int               `json:"limit"`                   // 获取的K线数量（需覆盖指标计算所需周期）
+	Indicators   []IndicatorConfig `json:"indicators"`              // 需要计算的指标（见 RegisteredIndicators）
+	SeriesLength int               `json:"series_length,omitempty"` // 输出的序列长度（默认10）
+}
+
+// DefaultTimeframes 默认时间框架：3分钟日内 + 4小时长期
+var DefaultTimeframes = []TimeframeConfig{
+	{Interval: "3m", Limit: 40, Indicators: mustIndicators("ema20", "macd", "rsi7", "rsi14")},
+	{Interval: "4h", Limit: 60, Indicators: mustIndicators("ema20", "ema50", "atr3", "atr14", "volume", "macd", "rsi14")},
+}
+
+const defaultSeriesLength = 10
+
+// Options 市场数据获取选项
+type Options struct {
+	Provider   Provider          // 行情数据源（为空时使用币安）
+	Timeframes []TimeframeConfig // 时间框架（为空时使用DefaultTimeframes）
+}
+
+// Get 获取指定代币的市场数据（使用默认数据源）
 func Get(symbol string) (*Data, error) {
+	return GetWithOptions(symbol, nil)
+}
+
+// GetWithOptions 按选项获取指定代币的市场数据
+func GetWithOptions(symbol string, opts *Options) (*Data, error) {
+	return GetWithContext(context.Background(), symbol, opts)
+}
+
+// GetWithContext 按选项获取指定代币的市场数据，ctx取消或到期时中止进行中的请求
+func GetWithContext(ctx context.Context, symbol string, opts *Options) (*Data, error) {
+	provider := defaultProvider
+	timeframes := DefaultTimeframes
+	if opts != nil {
+		if opts.Provider != nil {
+			provider = opts.Provider
+		}
+		if len(opts.Timeframes) > 0 {
+			timeframes = opts.Timeframes
+		}
+	}
+
+	provider = WithContext(ctx, provider)
+
 	// 标准化symbol
 	symbol = Normalize(symbol)
 
-	// 获取3分钟K线数据 (最近10个)
-	klines3m, err := getKlines(symbol, "3m", 40) // 多获取一些用于计算
-	if err != nil {
-		return nil, fmt.Errorf("获取3分钟K线失败: %v", err)
+	data := &Data{
+		Symbol:     symbol,
+		Timeframes: make(map[string]*TimeframeData),
+	}
+
+	// 获取各周期K线并计算指标
+	klinesByInterval := make(map[string][]Kline)
+	for _, tf := range timeframes {
+		klines, err := fetchKlines(provider, symbol, tf.Interval, tf.Limit)
+		if err != nil {
+			return nil, fmt.Errorf("获取%s K线失败: %v", tf.Interval, err)
+		}
+		if len(klines) == 0 {
+			return nil, fmt.Errorf("获取%s K线失败: %s 无数据", tf.Interval, provider.Name())
+		}
+		klinesByInterval[tf.Interval] = klines
+		data.Timeframes[tf.Interval] = calculateTimeframeData(tf, klines)
+		data.TimeframeOrder = append(data.TimeframeOrder, tf.Interval)
+	}
+
+	// 数据质量检查
+	data.QualityIssues = checkDataQuality(klinesByInterval, data.TimeframeOrder, time.Now())
+
+	// 计算当前指标 (基于最短周期的最新数据)
+	primary := klinesByInterval[shortestInterval(timeframes)]
+	data.CurrentPrice = primary[len(primary)-1].Close
+	data.CurrentEMA20 = calculateEMA(primary, 20)
+	data.CurrentMACD = calculateMACD(primary)
+	data.CurrentRSI7 = calculateRSI(primary, 7)
+	data.CurrentATR14 = calculateATR(primary, 14)
+
+	// 计算价格变化百分比（从能精确覆盖该时长的最短周期取历史价格）
+	data.PriceChange1h = priceChangeOver(klinesByInterval, data.CurrentPrice, time.Hour)
+	data.PriceChange4h = priceChangeOver(klinesByInterval, data.CurrentPrice, 4*time.Hour)
+
+	// 判定市场状态
+	regimeInterval := longestInterval(timeframes)
+	data.Regime = ClassifyRegime(klinesByInterval[regimeInterval], regimeInterval)
+
+	// 24小时成交额和上线时间（K线不足请求数量说明已覆盖全部历史）
+	data.QuoteVolume24h = quoteVolumeOver(klinesByInterval, 24*time.Hour)
+	for _, tf := range timeframes {
+		if tf.Interval == regimeInterval {
+			if klines := klinesByInterval[tf.Interval]; len(klines) < tf.Limit {
+				data.ListedSince = time.UnixMilli(klines[0].OpenTime)
+			}
+		}
 	}
 
-	// 获取4小时K线数据 (最近10个)
-	klines4h, err := getKlines(symbol, "4h", 60) // 多获取用于计算指标
+	// 获取OI数据
+	oiData, err := provider.GetOpenInterest(symbol)
 	if err != nil {
-		return nil, fmt.Errorf("获取4小时K线失败: %v", err)
+		// OI失败不影响整体,使用默认值
+		oiData = &OIData{}
+	}
+	oiData.Value = oiData.Latest * data.CurrentPrice
+
+	// 历史持仓量只请求一次，推导各周期序列和1h/4h/24h变化（失败不影响整体）
+	period, limit := oiHistoryRequest(data.Timeframes)
+	if history, err := provider.GetOpenInterestHistory(symbol, period, limit); err == nil {
+		applyOIHistory(oiData, history, time.Now())
+		for interval, tfData := range data.Timeframes {
+			tfData.OpenInterest = resampleOIHistory(history, period, interval, len(tfData.ClosePrices))
+		}
+	}
+	data.OpenInterest = oiData
+
+	// 获取Funding Rate
+	if funding, err := getFunding(provider, symbol); err == nil {
+		data.Funding = funding
+		data.FundingRate = funding.Rate
 	}
 
-	// 计算当前指标 (基于3分钟最新数据)
-	currentPrice := klines3m[len(klines3m)-1].Close
-	currentEMA20 := calculateEMA(klines3m, 20)
-	currentMACD := calculateMACD(klines3m)
-	currentRSI7 := calculateRSI(klines3m, 7)
+	// 获取盘口深度（失败不影响整体）
+	if book, err := provider.GetOrderBook(symbol, orderBookDepthLimit); err == nil {
+		data.Depth = summarizeDepth(book)
+	}
+
+	// 获取多空比和主动买卖量（失败不影响整体）
+	data.Positioning = getPositioning(provider, symbol)
+
+	// 获取强平统计（失败不影响整体）
+	data.Liquidations, _ = provider.GetLiquidations(symbol)
 
-	// 计算价格变化百分比
-	// 1小时价格变化 = 20个3分钟K线前的价格
-	priceChange1h := 0.0
-	if len(klines3m) >= 21 { // 至少需要21根K线 (当前 + 20根前)
-		price1hAgo := klines3m[len(klines3m)-21].Close
-		if price1hAgo > 0 {
-			priceChange1h = ((currentPrice - price1hAgo) / price1hAgo) * 100
+	return data, nil
+}
+
+// oiHistoryRequest 历史持仓量的统计周期和点数：覆盖24小时统计以及各周期的持仓量序列
+// 默认使用15m；有周期不能被15m整除但能被5m整除时使用5m（更短的周期数据源不提供，不输出持仓量序列）
+func oiHistoryRequest(timeframes map[string]*TimeframeData) (string, int) {
+	fine, _ := IntervalDuration(oiHistoryFinePeriod)
+	base, _ := IntervalDuration(oiHistoryPeriod)
+	period := oiHistoryPeriod
+	for interval := range timeframes {
+		if d, err := IntervalDuration(interval); err == nil && d%fine == 0 && d%base != 0 {
+			period, base = oiHistoryFinePeriod, fine
+			break
 		}
 	}
 
-	// 4小时价格变化 = 1个4小时K线前的价格
-	priceChange4h := 0.0
-	if len(klines4h) >= 2 {
-		price4hAgo := klines4h[len(klines4h)-2].Close
-		if price4hAgo > 0 {
-			priceChange4h = ((currentPrice - price4hAgo) / price4hAgo) * 100
+	limit := int(oiStatsWindow/base) + 1
+	for interval, tfData := range timeframes {
+		d, err := IntervalDuration(interval)
+		if err != nil || !resampleableOI(d, base) {
+			continue
 		}
+		// 多取一个周期，保证对齐到周期边界后仍有足够的点
+		limit = max(limit, len(tfData.ClosePrices)*int(d/base)+1)
 	}
+	return period, min(limit, oiHistoryMaxLimit)
+}
 
-	// 获取OI数据
-	oiData, err := getOpenInterestData(symbol)
+// resampleableOI 周期d的持仓量序列能否由统计周期base的数据推导（日线以上的周期边界与UTC整除不一致，不推导）
+func resampleableOI(d, base time.Duration) bool {
+	return d >= base && d%base == 0 && d <= 24*time.Hour
+}
+
+// resampleOIHistory 从统计周期为period的历史持仓量中取出对齐到interval周期边界的最近n个点（旧→新）
+func resampleOIHistory(history []OIPoint, period, interval string, n int) []float64 {
+	d, err := IntervalDuration(interval)
 	if err != nil {
-		// OI失败不影响整体,使用默认值
-		oiData = &OIData{Latest: 0, Average: 0}
+		return nil
+	}
+	if base, err := IntervalDuration(period); err != nil || !resampleableOI(d, base) {
+		return nil
 	}
 
-	// 获取Funding Rate
-	fundingRate, _ := getFundingRate(symbol)
-
-	// 计算日内系列数据
-	intradayData := calculateIntradaySeries(klines3m)
-
-	// 计算长期数据
-	longerTermData := calculateLongerTermData(klines4h)
-
-	return &Data{
-		Symbol:            symbol,
-		CurrentPrice:      currentPrice,
-		PriceChange1h:     priceChange1h,
-		PriceChange4h:     priceChange4h,
-		CurrentEMA20:      currentEMA20,
-		CurrentMACD:       currentMACD,
-		CurrentRSI7:       currentRSI7,
-		OpenInterest:      oiData,
-		FundingRate:       fundingRate,
-		IntradaySeries:    intradayData,
-		LongerTermContext: longerTermData,
-	}, nil
-}
-
-// getKlines 从Binance获取K线数据
-func getKlines(symbol, interval string, limit int) ([]Kline, error) {
-	url := fmt.Sprintf("https://fapi.binance.com/fapi/v1/klines?symbol=%s&interval=%s&limit=%d",
-		symbol, interval, limit)
-
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, err
+	var series []float64
+	for i := len(history) - 1; i >= 0 && len(series) < n; i-- {
+		if history[i].Time%d.Milliseconds() == 0 {
+			series = append(series, history[i].OpenInterest)
+		}
 	}
-	defer resp.Body.Close()
+	for i, j := 0, len(series)-1; i < j; i, j = i+1, j-1 {
+		series[i], series[j] = series[j], series[i]
+	}
+	return series
+}
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+// applyOIHistory 根据历史持仓量计算24小时均值和1h/4h/24h变化
+// 变化百分比以历史序列自身的最新点为基准（回退数据源时历史与当前值可能来自不同平台）
+func applyOIHistory(oi *OIData, history []OIPoint, now time.Time) {
+	if len(history) == 0 {
+		return
 	}
 
-	var rawData [][]interface{}
-	if err := json.Unmarshal(body, &rawData); err != nil {
-		return nil, err
+	latest := history[len(history)-1]
+	sum, count := 0.0, 0
+	for _, point := range history {
+		if point.Time >= latest.Time-oiStatsWindow.Milliseconds() {
+			sum += point.OpenInterest
+			count++
+		}
 	}
+	oi.Average = sum / float64(count)
 
-	klines := make([]Kline, len(rawData))
-	for i, item := range rawData {
-		openTime := int64(item[0].(float64))
-		open, _ := parseFloat(item[1])
-		high, _ := parseFloat(item[2])
-		low, _ := parseFloat(item[3])
-		close, _ := parseFloat(item[4])
-		volume, _ := parseFloat(item[5])
-		closeTime := int64(item[6].(float64))
+	oi.Change1h = oiChangeSince(history, latest.OpenInterest, now.Add(-time.Hour))
+	oi.Change4h = oiChangeSince(history, latest.OpenInterest, now.Add(-4*time.Hour))
+	oi.Change24h = oiChangeSince(history, latest.OpenInterest, now.Add(-oiStatsWindow))
+	oi.HasHistory = true
+}
 
-		klines[i] = Kline{
-			OpenTime:  openTime,
-			Open:      open,
-			High:      high,
-			Low:       low,
-			Close:     close,
-			Volume:    volume,
-			CloseTime: closeTime,
+// oiChangeSince 计算相对于t时刻（取不晚于t的最近一个点）的持仓量变化百分比
+func oiChangeSince(history []OIPoint, latest float64, t time.Time) float64 {
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Time <= t.UnixMilli() {
+			if history[i].OpenInterest <= 0 {
+				return 0
+			}
+			return (latest - history[i].OpenInterest) / history[i].OpenInterest * 100
 		}
 	}
+	return 0
+}
 
-	return klines, nil
+// ValidateTimeframes 验证时间框架配置
+func ValidateTimeframes(timeframes []TimeframeConfig) error {
+	seen := make(map[string]bool)
+	for i, tf := range timeframes {
+		if _, err := IntervalDuration(tf.Interval); err != nil {
+			return fmt.Errorf("timeframes[%d]: %w", i, err)
+		}
+		if seen[tf.Interval] {
+			return fmt.Errorf("timeframes[%d]: 周期 %s 重复", i, tf.Interval)
+		}
+		seen[tf.Interval] = true
+
+		if tf.Limit <= 0 || tf.Limit > 1500 {
+			return fmt.Errorf("timeframes[%d]: limit必须在1-1500之间: %d", i, tf.Limit)
+		}
+		if tf.SeriesLength < 0 {
+			return fmt.Errorf("timeframes[%d]: series_length不能为负数", i)
+		}
+		for _, cfg := range tf.Indicators {
+			if _, err := NewIndicator(cfg); err != nil {
+				return fmt.Errorf("timeframes[%d]: %w", i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// DescribeTimeframes 时间框架的简短描述（用于prompt），如 "3m、4h"
+func DescribeTimeframes(timeframes []TimeframeConfig) string {
+	if len(timeframes) == 0 {
+		timeframes = DefaultTimeframes
+	}
+	intervals := make([]string, len(timeframes))
+	for i, tf := range timeframes {
+		intervals[i] = tf.Interval
+	}
+	return strings.Join(intervals, "、")
+}
+
+// shortestInterval 找出最短的周期
+func shortestInterval(timeframes []TimeframeConfig) string {
+	sorted := make([]TimeframeConfig, len(timeframes))
+	copy(sorted, timeframes)
+	sort.Slice(sorted, func(i, j int) bool {
+		di, _ := IntervalDuration(sorted[i].Interval)
+		dj, _ := IntervalDuration(sorted[j].Interval)
+		return di < dj
+	})
+	return sorted[0].Interval
+}
+
+// longestInterval 覆盖时间最长（周期 × K线数量）的周期
+func longestInterval(timeframes []TimeframeConfig) string {
+	best, bestSpan := "", time.Duration(0)
+	for _, tf := range timeframes {
+		d, err := IntervalDuration(tf.Interval)
+		if err != nil {
+			continue
+		}
+		if span := d * time.Duration(tf.Limit); span > bestSpan {
+			best, bestSpan = tf.Interval, span
+		}
+	}
+	return best
+}
+
+// quoteVolumeOver 估算指定时长内的成交额（成交量 × 收盘价）
+// 选择K线数量足够覆盖该时长的最短周期，覆盖不足时返回0
+func quoteVolumeOver(klinesByInterval map[string][]Kline, d time.Duration) float64 {
+	bestInterval := time.Duration(0)
+	var best []Kline
+	for interval, klines := range klinesByInterval {
+		step, err := IntervalDuration(interval)
+		if err != nil || d%step != 0 || len(klines) < int(d/step) {
+			continue
+		}
+		if bestInterval == 0 || step < bestInterval {
+			bestInterval, best = step, klines
+		}
+	}
+	if best == nil {
+		return 0
+	}
+
+	total := 0.0
+	for _, k := range best[len(best)-int(d/bestInterval):] {
+		total += k.Volume * k.Close
+	}
+	return total
+}
+
+// priceChangeOver 计算指定时长内的价格变化百分比
+// 选择能整除该时长且K线数量足够的最短周期，例如1小时 = 20根3分钟K线前的收盘价
+func priceChangeOver(klinesByInterval map[string][]Kline, currentPrice float64, d time.Duration) float64 {
+	bestInterval := time.Duration(0)
+	change := 0.0
+	for interval, klines := range klinesByInterval {
+		id, err := IntervalDuration(interval)
+		if err != nil || id > d || d%id != 0 {
+			continue
+		}
+		bars := int(d / id)
+		if len(klines) <= bars {
+			continue
+		}
+		if bestInterval != 0 && id >= bestInterval {
+			continue
+		}
+		priceAgo := klines[len(klines)-1-bars].Close
+		if priceAgo > 0 {
+			bestInterval = id
+			change = ((currentPrice - priceAgo) / priceAgo) * 100
+		}
+	}
+	return change
 }
 
 // calculateEMA 计算EMA
@@ -295,161 +552,37 @@ func calculateATR(klines []Kline, period int) float64 {
 	return atr
 }
 
-// calculateIntradaySeries 计算日内系列数据
-func calculateIntradaySeries(klines []Kline) *IntradayData {
-	data := &IntradayData{
-		MidPrices:   make([]float64, 0, 10),
-		EMA20Values: make([]float64, 0, 10),
-		MACDValues:  make([]float64, 0, 10),
-		RSI7Values:  make([]float64, 0, 10),
-		RSI14Values: make([]float64, 0, 10),
+// calculateTimeframeData 计算单个时间框架的序列数据
+func calculateTimeframeData(tf TimeframeConfig, klines []Kline) *TimeframeData {
+	points := tf.SeriesLength
+	if points <= 0 {
+		points = defaultSeriesLength
 	}
 
-	// 获取最近10个数据点
-	start := len(klines) - 10
-	if start < 0 {
-		start = 0
+	data := &TimeframeData{
+		Interval:    tf.Interval,
+		Klines:      klines,
+		ClosePrices: make([]float64, 0, points),
 	}
 
-	for i := start; i < len(klines); i++ {
-		data.MidPrices = append(data.MidPrices, klines[i].Close)
-
-		// 计算每个点的EMA20
-		if i >= 19 {
-			ema20 := calculateEMA(klines[:i+1], 20)
-			data.EMA20Values = append(data.EMA20Values, ema20)
-		}
-
-		// 计算每个点的MACD
-		if i >= 25 {
-			macd := calculateMACD(klines[:i+1])
-			data.MACDValues = append(data.MACDValues, macd)
-		}
-
-		// 计算每个点的RSI
-		if i >= 7 {
-			rsi7 := calculateRSI(klines[:i+1], 7)
-			data.RSI7Values = append(data.RSI7Values, rsi7)
-		}
-		if i >= 14 {
-			rsi14 := calculateRSI(klines[:i+1], 14)
-			data.RSI14Values = append(data.RSI14Values, rsi14)
-		}
-	}
-
-	return data
-}
-
-// calculateLongerTermData 计算长期数据
-func calculateLongerTermData(klines []Kline) *LongerTermData {
-	data := &LongerTermData{
-		MACDValues:  make([]float64, 0, 10),
-		RSI14Values: make([]float64, 0, 10),
-	}
-
-	// 计算EMA
-	data.EMA20 = calculateEMA(klines, 20)
-	data.EMA50 = calculateEMA(klines, 50)
-
-	// 计算ATR
-	data.ATR3 = calculateATR(klines, 3)
-	data.ATR14 = calculateATR(klines, 14)
-
-	// 计算成交量
-	if len(klines) > 0 {
-		data.CurrentVolume = klines[len(klines)-1].Volume
-		// 计算平均成交量
-		sum := 0.0
-		for _, k := range klines {
-			sum += k.Volume
-		}
-		data.AverageVolume = sum / float64(len(klines))
-	}
-
-	// 计算MACD和RSI序列
-	start := len(klines) - 10
+	// 获取最近N个数据点
+	start := len(klines) - points
 	if start < 0 {
 		start = 0
 	}
-
 	for i := start; i < len(klines); i++ {
-		if i >= 25 {
-			macd := calculateMACD(klines[:i+1])
-			data.MACDValues = append(data.MACDValues, macd)
-		}
-		if i >= 14 {
-			rsi14 := calculateRSI(klines[:i+1], 14)
-			data.RSI14Values = append(data.RSI14Values, rsi14)
-		}
+		data.ClosePrices = append(data.ClosePrices, klines[i].Close)
 	}
 
-	return data
-}
-
-// getOpenInterestData 获取OI数据
-func getOpenInterestData(symbol string) (*OIData, error) {
-	url := fmt.Sprintf("https://fapi.binance.com/fapi/v1/openInterest?symbol=%s", symbol)
-
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	var result struct {
-		OpenInterest string `json:"openInterest"`
-		Symbol       string `json:"symbol"`
-		Time         int64  `json:"time"`
-	}
-
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, err
-	}
-
-	oi, _ := strconv.ParseFloat(result.OpenInterest, 64)
-
-	return &OIData{
-		Latest:  oi,
-		Average: oi * 0.999, // 近似平均值
-	}, nil
-}
-
-// getFundingRate 获取资金费率
-func getFundingRate(symbol string) (float64, error) {
-	url := fmt.Sprintf("https://fapi.binance.com/fapi/v1/premiumIndex?symbol=%s", symbol)
-
-	resp, err := http.Get(url)
-	if err != nil {
-		return 0, err
-	}
-	defer resp.Body.Close()
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return 0, err
-	}
-
-	var result struct {
-		Symbol          string `json:"symbol"`
-		MarkPrice       string `json:"markPrice"`
-		IndexPrice      string `json:"indexPrice"`
-		LastFundingRate string `json:"lastFundingRate"`
-		NextFundingTime int64  `json:"nextFundingTime"`
-		InterestRate    string `json:"interestRate"`
-		Time            int64  `json:"time"`
-	}
-
-	if err := json.Unmarshal(body, &result); err != nil {
-		return 0, err
+	for _, cfg := range tf.Indicators {
+		indicator, err := NewIndicator(cfg)
+		if err != nil {
+			continue // 配置加载时已校验
+		}
+		data.Indicators = append(data.Indicators, calculateIndicatorSeries(indicator, klines, points)...)
 	}
 
-	rate, _ := strconv.ParseFloat(result.LastFundingRate, 64)
-	return rate, nil
+	return data
 }
 
 // Format 格式化输出市场数据
@@ -459,58 +592,69 @@ func Format(data *Data) string {
 	sb.WriteString(fmt.Sprintf("current_price = %.2f, current_ema20 = %.3f, current_macd = %.3f, current_rsi (7 period) = %.3f\n\n",
 		data.CurrentPrice, data.CurrentEMA20, data.CurrentMACD, data.CurrentRSI7))
 
-	sb.WriteString(fmt.Sprintf("In addition, here is the latest %s open interest and funding rate for perps:\n\n",
-		data.Symbol))
-
-	if data.OpenInterest != nil {
-		sb.WriteString(fmt.Sprintf("Open Interest: Latest: %.2f Average: %.2f\n\n",
-			data.OpenInterest.Latest, data.OpenInterest.Average))
+	if len(data.QualityIssues) > 0 {
+		sb.WriteString(fmt.Sprintf("⚠️ Data quality issues (do not open new positions): %s\n\n", strings.Join(data.QualityIssues, "; ")))
 	}
 
-	sb.WriteString(fmt.Sprintf("Funding Rate: %.2e\n\n", data.FundingRate))
+	if data.Regime != nil {
+		sb.WriteString(formatRegime(data.Regime))
+	}
 
-	if data.IntradaySeries != nil {
-		sb.WriteString("Intraday series (3‑minute intervals, oldest → latest):\n\n")
+	sb.WriteString(fmt.Sprintf("In addition, here is the latest %s open interest and funding rate for perps:\n\n",
+		data.Symbol))
 
-		if len(data.IntradaySeries.MidPrices) > 0 {
-			sb.WriteString(fmt.Sprintf("Mid prices: %s\n\n", formatFloatSlice(data.IntradaySeries.MidPrices)))
+	if oi := data.OpenInterest; oi != nil {
+		sb.WriteString(fmt.Sprintf("Open Interest: Latest: %.2f (≈ %.2fM USD)", oi.Latest, oi.Value/1_000_000))
+		if oi.HasHistory {
+			sb.WriteString(fmt.Sprintf(" 24h Average: %.2f | Change: 1h %+.2f%%, 4h %+.2f%%, 24h %+.2f%%",
+				oi.Average, oi.Change1h, oi.Change4h, oi.Change24h))
 		}
+		sb.WriteString("\n\n")
+	}
 
-		if len(data.IntradaySeries.EMA20Values) > 0 {
-			sb.WriteString(fmt.Sprintf("EMA indicators (20‑period): %s\n\n", formatFloatSlice(data.IntradaySeries.EMA20Values)))
-		}
+	sb.WriteString(fmt.Sprintf("Funding Rate: %.2e\n\n", data.FundingRate))
 
-		if len(data.IntradaySeries.MACDValues) > 0 {
-			sb.WriteString(fmt.Sprintf("MACD indicators: %s\n\n", formatFloatSlice(data.IntradaySeries.MACDValues)))
-		}
+	if data.Funding != nil {
+		sb.WriteString(formatFunding(data.Funding, time.Now()))
+	}
 
-		if len(data.IntradaySeries.RSI7Values) > 0 {
-			sb.WriteString(fmt.Sprintf("RSI indicators (7‑Period): %s\n\n", formatFloatSlice(data.IntradaySeries.RSI7Values)))
+	if d := data.Depth; d != nil {
+		sb.WriteString(fmt.Sprintf("Order Book: spread %.2f bps | depth ±0.5%%: bid $%.0f / ask $%.0f | depth ±1%%: bid $%.0f / ask $%.0f | top-of-book imbalance %+.2f",
+			d.SpreadBps, d.BidDepth05, d.AskDepth05, d.BidDepth1, d.AskDepth1, d.Imbalance))
+		if d.Truncated {
+			sb.WriteString(" (book snapshot does not reach ±1%, depth is a lower bound)")
 		}
+		sb.WriteString("\n\n")
+	}
 
-		if len(data.IntradaySeries.RSI14Values) > 0 {
-			sb.WriteString(fmt.Sprintf("RSI indicators (14‑Period): %s\n\n", formatFloatSlice(data.IntradaySeries.RSI14Values)))
-		}
+	if data.Liquidations != nil {
+		sb.WriteString(formatLiquidations(data.Liquidations))
 	}
 
-	if data.LongerTermContext != nil {
-		sb.WriteString("Longer‑term context (4‑hour timeframe):\n\n")
+	if data.Positioning != nil {
+		sb.WriteString(formatPositioning(data.Positioning))
+	}
 
-		sb.WriteString(fmt.Sprintf("20‑Period EMA: %.3f vs. 50‑Period EMA: %.3f\n\n",
-			data.LongerTermContext.EMA20, data.LongerTermContext.EMA50))
+	for _, interval := range data.TimeframeOrder {
+		tf := data.Timeframes[interval]
+		if tf == nil {
+			continue
+		}
 
-		sb.WriteString(fmt.Sprintf("3‑Period ATR: %.3f vs. 14‑Period ATR: %.3f\n\n",
-			data.LongerTermContext.ATR3, data.LongerTermContext.ATR14))
+		sb.WriteString(fmt.Sprintf("Series (%s intervals, oldest → latest):\n\n", interval))
 
-		sb.WriteString(fmt.Sprintf("Current Volume: %.3f vs. Average Volume: %.3f\n\n",
-			data.LongerTermContext.CurrentVolume, data.LongerTermContext.AverageVolume))
+		if len(tf.ClosePrices) > 0 {
+			sb.WriteString(fmt.Sprintf("Close prices: %s\n\n", formatFloatSlice(tf.ClosePrices)))
+		}
 
-		if len(data.LongerTermContext.MACDValues) > 0 {
-			sb.WriteString(fmt.Sprintf("MACD indicators: %s\n\n", formatFloatSlice(data.LongerTermContext.MACDValues)))
+		if len(tf.OpenInterest) > 0 {
+			sb.WriteString(fmt.Sprintf("Open interest: %s\n\n", formatFloatSlice(tf.OpenInterest)))
 		}
 
-		if len(data.LongerTermContext.RSI14Values) > 0 {
-			sb.WriteString(fmt.Sprintf("RSI indicators (14‑Period): %s\n\n", formatFloatSlice(data.LongerTermContext.RSI14Values)))
+		for _, ind := range tf.Indicators {
+			if len(ind.Values) > 0 {
+				sb.WriteString(fmt.Sprintf("%s: %s\n\n", ind.Label, formatFloatSlice(ind.Values)))
+			}
 		}
 	}
 
@@ -0,0 +1,126 @@
+package market
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// testOIHistory 生成以lastTime为最后一个点、统计周期为d的n个持仓量点（持仓量为点的序号，便于核对）
+func testOIHistory(lastTime time.Time, d time.Duration, n int) []OIPoint {
+	history := make([]OIPoint, n)
+	for i := range history {
+		history[i] = OIPoint{
+			Time:         lastTime.Add(-time.Duration(n-1-i) * d).UnixMilli(),
+			OpenInterest: float64(i + 1),
+		}
+	}
+	return history
+}
+
+func TestOIHistoryRequest(t *testing.T) {
+	tf := func(intervals ...string) map[string]*TimeframeData {
+		timeframes := make(map[string]*TimeframeData)
+		for _, interval := range intervals {
+			timeframes[interval] = &TimeframeData{ClosePrices: make([]float64, 10)}
+		}
+		return timeframes
+	}
+
+	tests := []struct {
+		name       string
+		timeframes map[string]*TimeframeData
+		wantPeriod string
+		wantLimit  int
+	}{
+		{"默认3m+4h", tf("3m", "4h"), "15m", 161},
+		{"只需24小时统计", tf("3m", "15m"), "15m", 97},
+		{"有5m周期时用5m", tf("5m", "1h"), "5m", 289},
+		{"超过单次上限", tf("5m", "1d"), "5m", oiHistoryMaxLimit},
+		{"日线以上周期不推导", tf("15m", "1w"), "15m", 97},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			period, limit := oiHistoryRequest(tt.timeframes)
+			if period != tt.wantPeriod || limit != tt.wantLimit {
+				t.Errorf("oiHistoryRequest = %s, %d, 期望 %s, %d", period, limit, tt.wantPeriod, tt.wantLimit)
+			}
+		})
+	}
+}
+
+func TestResampleOIHistory(t *testing.T) {
+	// 最后一个点（第161个）在 10:45，整点 10:00 为第158个，4小时边界 08:00 为第150个
+	last := time.Date(2024, 1, 2, 10, 45, 0, 0, time.UTC)
+	history := testOIHistory(last, 15*time.Minute, 161)
+
+	tests := []struct {
+		name     string
+		interval string
+		n        int
+		want     string
+	}{
+		{"同周期取最后n个", "15m", 3, "[159 160 161]"},
+		{"1h对齐整点", "1h", 3, "[150 154 158]"},
+		{"4h对齐4小时边界", "4h", 2, "[134 150]"},
+		{"历史不足时返回已有的点", "4h", 20, "[6 22 38 54 70 86 102 118 134 150]"},
+		{"比统计周期短", "3m", 3, "[]"},
+		{"日线以上", "1w", 3, "[]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fmt.Sprint(resampleOIHistory(history, "15m", tt.interval, tt.n)); got != tt.want {
+				t.Errorf("resampleOIHistory(%s, %d) = %s, 期望 %s", tt.interval, tt.n, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyOIHistory(t *testing.T) {
+	now := time.Date(2024, 1, 2, 10, 50, 0, 0, time.UTC)
+	// 5m统计周期取了超过24小时的数据，均值只统计最近24小时
+	history := testOIHistory(now.Add(-5*time.Minute), 5*time.Minute, 400)
+
+	oi := &OIData{}
+	applyOIHistory(oi, history, now)
+
+	if !oi.HasHistory {
+		t.Fatalf("HasHistory = false")
+	}
+	// 最近24小时为第112-400个点（289个）；1h/4h/24h前不晚于该时刻的点为第389/353/113个
+	if want := float64(112+400) / 2; oi.Average != want {
+		t.Errorf("Average = %v, 期望 %v", oi.Average, want)
+	}
+	for _, c := range []struct {
+		name      string
+		got, want float64
+	}{
+		{"1h", oi.Change1h, (400.0 - 389) / 389 * 100},
+		{"4h", oi.Change4h, (400.0 - 353) / 353 * 100},
+		{"24h", oi.Change24h, (400.0 - 113) / 113 * 100},
+	} {
+		if diff := c.got - c.want; diff > 1e-9 || diff < -1e-9 {
+			t.Errorf("Change%s = %v, 期望 %v", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestValidateTimeframesInterval(t *testing.T) {
+	tests := []struct {
+		interval string
+		wantErr  bool
+	}{
+		{"3m", false},
+		{"4h", false},
+		{"1w", false},
+		{"1M", true}, // 自然月长度不固定
+		{"0m", true},
+		{"3x", true},
+	}
+	for _, tt := range tests {
+		err := ValidateTimeframes([]TimeframeConfig{{Interval: tt.interval, Limit: 10}})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ValidateTimeframes(%s) = %v, 期望出错 %v", tt.interval, err, tt.wantErr)
+		}
+	}
+}
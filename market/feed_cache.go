@@ -0,0 +1,124 @@
+package market
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	liveFeedTTL    = 30 * time.Second // 当前持仓量和资金费率（预测费率、标记价格随时变化）的缓存时长
+	minFeedTTL     = time.Minute      // 按统计周期更新的数据的最短缓存时长
+	maxFeedTTL     = 5 * time.Minute  // 按统计周期更新的数据的最长缓存时长
+	fundingHistTTL = maxFeedTTL       // 资金费率历史（按结算周期更新，至少1小时）
+)
+
+// feedCache 变化缓慢的统计数据（持仓量、资金费率、多空比、主动买卖量）的短期缓存
+// 这些数据按统计周期更新，每个扫描周期为每个币种重复请求只会消耗接口权重；
+// 同一数据源的trader共用一份（见 SharedKlineCache），按 币种+数据类型+周期+数量 索引
+type feedCache struct {
+	mu      sync.Mutex
+	entries map[string]feedEntry
+}
+
+// feedEntry 单项缓存
+type feedEntry struct {
+	value     interface{}
+	expiresAt time.Time
+}
+
+func newFeedCache() *feedCache {
+	return &feedCache{entries: make(map[string]feedEntry)}
+}
+
+// periodFeedTTL 按统计周期更新的数据的缓存时长（周期的1/3，限制在1-5分钟）
+func periodFeedTTL(period string) time.Duration {
+	d, err := IntervalDuration(period)
+	if err != nil {
+		return minFeedTTL
+	}
+	return min(max(d/3, minFeedTTL), maxFeedTTL)
+}
+
+// cachedFeed 读取缓存，未命中或过期时调用fetch（失败不缓存）
+// 请求期间不持有锁，不同币种的请求可以并发；同一项并发未命中时可能重复请求一次
+func cachedFeed[T any](c *feedCache, key string, ttl time.Duration, fetch func() (T, error)) (T, error) {
+	now := time.Now()
+	c.mu.Lock()
+	if entry, ok := c.entries[key]; ok && now.Before(entry.expiresAt) {
+		c.mu.Unlock()
+		return entry.value.(T), nil
+	}
+	c.mu.Unlock()
+
+	value, err := fetch()
+	if err != nil {
+		return value, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, entry := range c.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = feedEntry{value: value, expiresAt: now.Add(ttl)}
+	return value, nil
+}
+
+// openInterest 当前持仓量（返回副本，调用方会填充持仓价值和历史统计）
+func (c *feedCache) openInterest(provider Provider, symbol string) (*OIData, error) {
+	oi, err := cachedFeed(c, "oi|"+symbol, liveFeedTTL, func() (*OIData, error) {
+		return provider.GetOpenInterest(symbol)
+	})
+	if err != nil {
+		return nil, err
+	}
+	cp := *oi
+	return &cp, nil
+}
+
+// openInterestHistory 历史持仓量
+func (c *feedCache) openInterestHistory(provider Provider, symbol, period string, limit int) ([]OIPoint, error) {
+	key := fmt.Sprintf("oi_hist|%s|%s|%d", symbol, period, limit)
+	return cachedFeed(c, key, periodFeedTTL(period), func() ([]OIPoint, error) {
+		return provider.GetOpenInterestHistory(symbol, period, limit)
+	})
+}
+
+// funding 当前资金费率（返回副本，调用方会填充基差和历史统计）
+func (c *feedCache) funding(provider Provider, symbol string) (*FundingData, error) {
+	funding, err := cachedFeed(c, "funding|"+symbol, liveFeedTTL, func() (*FundingData, error) {
+		return provider.GetFunding(symbol)
+	})
+	if err != nil {
+		return nil, err
+	}
+	cp := *funding
+	return &cp, nil
+}
+
+// fundingHistory 资金费率历史
+func (c *feedCache) fundingHistory(provider Provider, symbol string, limit int) ([]FundingPoint, error) {
+	key := fmt.Sprintf("funding_hist|%s|%d", symbol, limit)
+	return cachedFeed(c, key, fundingHistTTL, func() ([]FundingPoint, error) {
+		return provider.GetFundingHistory(symbol, limit)
+	})
+}
+
+// longShortRatio 多空账户比序列
+func (c *feedCache) longShortRatio(provider Provider, symbol, scope, period string, limit int) ([]LongShortPoint, error) {
+	key := fmt.Sprintf("long_short|%s|%s|%s|%d", symbol, scope, period, limit)
+	return cachedFeed(c, key, periodFeedTTL(period), func() ([]LongShortPoint, error) {
+		return provider.GetLongShortRatio(symbol, scope, period, limit)
+	})
+}
+
+// takerVolume 主动买卖量序列
+func (c *feedCache) takerVolume(provider Provider, symbol, period string, limit int) ([]TakerVolumePoint, error) {
+	key := fmt.Sprintf("taker|%s|%s|%d", symbol, period, limit)
+	return cachedFeed(c, key, periodFeedTTL(period), func() ([]TakerVolumePoint, error) {
+		return provider.GetTakerVolume(symbol, period, limit)
+	})
+}
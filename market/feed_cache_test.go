@@ -0,0 +1,89 @@
+package market
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// countingFeedProvider 记录统计数据请求次数的数据源
+type countingFeedProvider struct {
+	Provider
+	calls map[string]int
+	err   error
+}
+
+func (p *countingFeedProvider) GetOpenInterest(symbol string) (*OIData, error) {
+	p.calls["oi"]++
+	return &OIData{Latest: 100}, p.err
+}
+
+func (p *countingFeedProvider) GetLongShortRatio(symbol, scope, period string, limit int) ([]LongShortPoint, error) {
+	p.calls["long_short_"+period]++
+	if p.err != nil {
+		return nil, p.err
+	}
+	return []LongShortPoint{{Ratio: 1.2}}, nil
+}
+
+func TestPeriodFeedTTL(t *testing.T) {
+	tests := []struct {
+		period string
+		want   time.Duration
+	}{
+		{"1m", minFeedTTL},
+		{"5m", 100 * time.Second},
+		{"15m", maxFeedTTL},
+		{"1d", maxFeedTTL},
+		{"bad", minFeedTTL},
+	}
+	for _, tt := range tests {
+		if got := periodFeedTTL(tt.period); got != tt.want {
+			t.Errorf("periodFeedTTL(%s) = %v, 期望 %v", tt.period, got, tt.want)
+		}
+	}
+}
+
+func TestFeedCache(t *testing.T) {
+	provider := &countingFeedProvider{calls: make(map[string]int)}
+	cache := newFeedCache()
+
+	// 同一周期内重复请求只发送一次，不同周期分别缓存
+	for i := 0; i < 3; i++ {
+		cache.longShortRatio(provider, "BTCUSDT", LongShortTopTraders, "1h", 10)
+	}
+	cache.longShortRatio(provider, "BTCUSDT", LongShortTopTraders, "4h", 10)
+	if provider.calls["long_short_1h"] != 1 || provider.calls["long_short_4h"] != 1 {
+		t.Fatalf("请求次数 = %v, 期望每个周期1次", provider.calls)
+	}
+
+	// 过期后重新请求
+	cache.mu.Lock()
+	for key, entry := range cache.entries {
+		entry.expiresAt = time.Now().Add(-time.Second)
+		cache.entries[key] = entry
+	}
+	cache.mu.Unlock()
+	cache.longShortRatio(provider, "BTCUSDT", LongShortTopTraders, "1h", 10)
+	if provider.calls["long_short_1h"] != 2 {
+		t.Fatalf("过期后未重新请求: %v", provider.calls)
+	}
+
+	// 返回副本，调用方修改不影响缓存
+	oi, _ := cache.openInterest(provider, "BTCUSDT")
+	oi.Value = 1e6
+	if again, _ := cache.openInterest(provider, "BTCUSDT"); again.Value != 0 || provider.calls["oi"] != 1 {
+		t.Errorf("缓存被调用方修改或重复请求: %+v, %v", again, provider.calls)
+	}
+
+	// 失败不缓存
+	failing := &countingFeedProvider{calls: make(map[string]int), err: errors.New("down")}
+	for i := 0; i < 2; i++ {
+		if _, err := cache.longShortRatio(failing, "ETHUSDT", LongShortGlobal, "1h", 10); err == nil {
+			t.Fatalf("期望返回错误")
+		}
+	}
+	if failing.calls["long_short_1h"] != 2 {
+		t.Errorf("失败结果被缓存: %v", failing.calls)
+	}
+}
@@ -0,0 +1,97 @@
+package market
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const fundingHistoryLimit = 10 // 资金费率历史条数
+
+// FundingData 资金费率数据
+type FundingData struct {
+	Rate            float64 // 当前（下一期预测）资金费率
+	IntervalHours   float64 // 结算间隔（小时），币安通常为8，Hyperliquid为1
+	NextFundingTime int64   // 下次结算时间（毫秒）
+	MarkPrice       float64 // 标记价格
+	IndexPrice      float64 // 指数价格
+	BasisPct        float64 // 基差百分比 = (标记价格-指数价格)/指数价格
+
+	History        []FundingPoint // 最近N期已结算的资金费率（旧→新）
+	AnnualizedRate float64        // 当前费率年化百分比
+	HistoryAvgAPR  float64        // 历史费率均值的年化百分比
+}
+
+// FundingPoint 已结算的资金费率
+type FundingPoint struct {
+	Time int64   // 结算时间（毫秒）
+	Rate float64 // 资金费率
+}
+
+// getFunding 获取资金费率、结算倒计时、基差和历史（历史获取失败不影响整体）
+func getFunding(provider Provider, symbol string) (*FundingData, error) {
+	funding, err := provider.GetFunding(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	if funding.IndexPrice > 0 && funding.MarkPrice > 0 {
+		funding.BasisPct = (funding.MarkPrice - funding.IndexPrice) / funding.IndexPrice * 100
+	}
+
+	if history, err := provider.GetFundingHistory(symbol, fundingHistoryLimit); err == nil && len(history) > 0 {
+		funding.History = history
+		// 部分币种结算间隔不是8小时，以历史结算时间间隔为准
+		if n := len(history); n >= 2 {
+			if hours := float64(history[n-1].Time-history[n-2].Time) / float64(time.Hour.Milliseconds()); hours > 0 {
+				funding.IntervalHours = hours
+			}
+		}
+
+		sum := 0.0
+		for _, point := range history {
+			sum += point.Rate
+		}
+		funding.HistoryAvgAPR = annualizeFunding(sum/float64(len(history)), funding.IntervalHours)
+	}
+
+	funding.AnnualizedRate = annualizeFunding(funding.Rate, funding.IntervalHours)
+	return funding, nil
+}
+
+// annualizeFunding 将单期资金费率换算为年化百分比
+func annualizeFunding(rate, intervalHours float64) float64 {
+	if intervalHours <= 0 {
+		return 0
+	}
+	return rate * (24 / intervalHours) * 365 * 100
+}
+
+// formatFunding 格式化资金费率信息
+func formatFunding(f *FundingData, now time.Time) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("Funding: current %.4f%% per %gh (annualized %+.1f%%)", f.Rate*100, f.IntervalHours, f.AnnualizedRate))
+	if f.NextFundingTime > 0 {
+		untilNext := time.UnixMilli(f.NextFundingTime).Sub(now)
+		if untilNext < 0 {
+			untilNext = 0
+		}
+		sb.WriteString(fmt.Sprintf(" | next funding in %dm", int(untilNext.Minutes())))
+	}
+	if f.MarkPrice > 0 && f.IndexPrice > 0 {
+		sb.WriteString(fmt.Sprintf(" | mark %.4f / index %.4f (basis %+.3f%%)", f.MarkPrice, f.IndexPrice, f.BasisPct))
+	}
+	sb.WriteString("\n\n")
+
+	if len(f.History) > 0 {
+		rates := make([]string, len(f.History))
+		for i, point := range f.History {
+			rates[i] = fmt.Sprintf("%.4f", point.Rate*100)
+		}
+		sb.WriteString(fmt.Sprintf("Funding history (last %d settlements, %%, oldest → latest): [%s] (average annualized %+.1f%%)\n\n",
+			len(f.History), strings.Join(rates, ", "), f.HistoryAvgAPR))
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,225 @@
+package market
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HistoryProvider 支持按时间区间分页拉取历史数据的数据源（用于下载到本地存储）
+// 每次调用返回 [start, end] 区间内从start开始的一页数据（按时间正序），返回空表示区间内没有更多数据
+type HistoryProvider interface {
+	Name() string
+
+	// GetKlinesRange 获取区间内的K线
+	GetKlinesRange(symbol, interval string, start, end time.Time) ([]Kline, error)
+
+	// GetFundingHistoryRange 获取区间内已结算的资金费率
+	GetFundingHistoryRange(symbol string, start, end time.Time) ([]FundingPoint, error)
+
+	// GetOpenInterestHistoryRange 获取区间内的历史持仓量
+	GetOpenInterestHistoryRange(symbol, period string, start, end time.Time) ([]OIPoint, error)
+}
+
+// NewHistoryProvider 根据交易平台创建历史数据源（不带缓存和回退）
+func NewHistoryProvider(exchange string, testnet bool) (HistoryProvider, error) {
+	switch exchange {
+	case "", "binance":
+		return NewBinanceProvider(), nil
+	case "hyperliquid":
+		return NewHyperliquidProvider(testnet), nil
+	case "aster":
+		return NewAsterProvider(), nil
+	default:
+		return nil, fmt.Errorf("不支持的历史数据源: %s", exchange)
+	}
+}
+
+// GetKlinesRange 从币安获取区间内的K线（单页最多1500根）
+func (p *BinanceProvider) GetKlinesRange(symbol, interval string, start, end time.Time) ([]Kline, error) {
+	url := fmt.Sprintf("%s/fapi/v1/klines?symbol=%s&interval=%s&startTime=%d&endTime=%d&limit=1500",
+		p.baseURL, symbol, interval, start.UnixMilli(), end.UnixMilli())
+	return p.getKlines(url)
+}
+
+// GetFundingHistoryRange 从币安获取区间内的资金费率（单页最多1000条）
+func (p *BinanceProvider) GetFundingHistoryRange(symbol string, start, end time.Time) ([]FundingPoint, error) {
+	url := fmt.Sprintf("%s/fapi/v1/fundingRate?symbol=%s&startTime=%d&endTime=%d&limit=1000",
+		p.baseURL, symbol, start.UnixMilli(), end.UnixMilli())
+	return p.getFundingHistory(url)
+}
+
+// GetOpenInterestHistoryRange 从币安获取区间内的历史持仓量（单页最多500条，仅保留最近30天）
+func (p *BinanceProvider) GetOpenInterestHistoryRange(symbol, period string, start, end time.Time) ([]OIPoint, error) {
+	if !binanceOIPeriods[period] {
+		return nil, fmt.Errorf("%w: 持仓量统计周期 %s", ErrNotSupported, period)
+	}
+	if earliest := time.Now().Add(-30 * 24 * time.Hour); start.Before(earliest) {
+		start = earliest
+	}
+	if !start.Before(end) {
+		return nil, nil
+	}
+
+	url := fmt.Sprintf("%s/futures/data/openInterestHist?symbol=%s&period=%s&startTime=%d&endTime=%d&limit=500",
+		p.baseURL, symbol, period, start.UnixMilli(), end.UnixMilli())
+	return p.getOpenInterestHistory(url)
+}
+
+// GetOpenInterestHistoryRange Aster未提供历史持仓量统计接口
+func (p *AsterProvider) GetOpenInterestHistoryRange(symbol, period string, start, end time.Time) ([]OIPoint, error) {
+	return nil, fmt.Errorf("%w: aster 历史持仓量", ErrNotSupported)
+}
+
+// GetKlinesRange 从Hyperliquid获取区间内的K线（单页最多5000根）
+func (p *HyperliquidProvider) GetKlinesRange(symbol, interval string, start, end time.Time) ([]Kline, error) {
+	return p.candleSnapshot(symbol, interval, start, end)
+}
+
+// GetFundingHistoryRange 从Hyperliquid获取区间内的资金费率（单页最多500条）
+func (p *HyperliquidProvider) GetFundingHistoryRange(symbol string, start, end time.Time) ([]FundingPoint, error) {
+	return p.fundingHistory(symbol, start, end)
+}
+
+// GetOpenInterestHistoryRange Hyperliquid未提供历史持仓量接口
+func (p *HyperliquidProvider) GetOpenInterestHistoryRange(symbol, period string, start, end time.Time) ([]OIPoint, error) {
+	return nil, fmt.Errorf("%w: hyperliquid 历史持仓量", ErrNotSupported)
+}
+
+// KlineStore 本地K线存储（实现见 market/store）
+type KlineStore interface {
+	// LatestKlines 读取本地最近limit根K线，本地没有该序列时返回空
+	LatestKlines(source, symbol, interval string, limit int) ([]Kline, error)
+
+	// AppendKlines 追加已收盘的K线（忽略已存在的K线）
+	AppendKlines(source, symbol, interval string, klines []Kline) error
+}
+
+var (
+	klineStoreMu sync.RWMutex
+	klineStore   KlineStore
+)
+
+// SetKlineStore 设置本地K线存储，设置后 market.Get 优先读取本地数据
+func SetKlineStore(store KlineStore) {
+	klineStoreMu.Lock()
+	defer klineStoreMu.Unlock()
+	klineStore = store
+}
+
+// StoreSource 数据源在本地存储中的目录名
+func StoreSource(provider interface{ Name() string }) string {
+	name, _, _ := strings.Cut(provider.Name(), "(")
+	return name
+}
+
+// fetchKlines 获取K线
+// 本地存储中有该序列时先读本地，只从数据源补齐缺失的尾部，并把新收盘的K线写回本地
+// 带回退的数据源分别使用主、备数据源各自的本地序列，避免两个平台的价格混在同一个文件里
+func fetchKlines(provider Provider, symbol, interval string, limit int) ([]Kline, error) {
+	if fb, ok := provider.(*FallbackProvider); ok {
+		klines, err := fetchKlines(fb.primary, symbol, interval, limit)
+		if err == nil && len(klines) > 0 {
+			return klines, nil
+		}
+		log.Printf("⚠️  %s 获取%s %s K线失败，回退到%s: %v", fb.primary.Name(), symbol, interval, fb.fallback.Name(), err)
+		return fetchKlines(fb.fallback, symbol, interval, limit)
+	}
+
+	klineStoreMu.RLock()
+	store := klineStore
+	klineStoreMu.RUnlock()
+	if store == nil {
+		return provider.GetKlines(symbol, interval, limit)
+	}
+
+	source := StoreSource(provider)
+	local, err := store.LatestKlines(source, symbol, interval, limit)
+	if err != nil || len(local) == 0 {
+		return provider.GetKlines(symbol, interval, limit)
+	}
+
+	d, err := IntervalDuration(interval)
+	if err != nil {
+		return nil, err
+	}
+
+	// 本地最后一根之后缺失的K线数（含当前未收盘K线）
+	last := local[len(local)-1]
+	missing := int(time.Since(time.UnixMilli(last.OpenTime)) / d)
+
+	// 单次请求不超过limit根（币安K线接口上限1500）
+	fetchLimit := limit
+	switch {
+	case missing >= limit:
+		local = nil // 本地数据过旧，与最近limit根没有重叠，整段从数据源获取
+	case len(local)+missing < limit:
+		// 本地数据不足，整段从数据源获取
+	default:
+		fetchLimit = missing + 1 // 多取一根与本地重叠，用于校验连续性
+	}
+
+	fresh, err := provider.GetKlines(symbol, interval, fetchLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	// 连续性校验：数据源返回的K线必须包含本地最后一根且数值一致（重启、时钟偏差或数据源修订都可能导致错位）
+	// 校验失败时不使用也不写入本地数据，整段从数据源获取
+	if local != nil && !overlapsLast(fresh, last) {
+		log.Printf("⚠️  %s %s 本地K线与数据源不连续（本地最后一根 %s），忽略本地数据",
+			symbol, interval, time.UnixMilli(last.OpenTime).Format("2006-01-02 15:04"))
+		if fetchLimit < limit {
+			if fresh, err = provider.GetKlines(symbol, interval, limit); err != nil {
+				return nil, err
+			}
+		}
+		return tailKlines(fresh, limit), nil
+	}
+
+	if closed := closedKlines(fresh, time.Now()); len(closed) > 0 {
+		if err := store.AppendKlines(source, symbol, interval, closed); err != nil {
+			log.Printf("⚠️  写入本地K线失败 %s %s: %v", symbol, interval, err)
+		}
+	}
+
+	return tailKlines(mergeKlines(local, fresh), limit), nil
+}
+
+// overlapsLast fresh中是否包含与last开盘时间和数值都一致的K线（本地存储的数值可无损还原，直接比较）
+func overlapsLast(fresh []Kline, last Kline) bool {
+	for _, k := range fresh {
+		if k.OpenTime == last.OpenTime {
+			return k.Open == last.Open && k.High == last.High && k.Low == last.Low &&
+				k.Close == last.Close && k.Volume == last.Volume
+		}
+	}
+	return false
+}
+
+// closedKlines 过滤出已收盘的K线
+func closedKlines(klines []Kline, now time.Time) []Kline {
+	for i := len(klines) - 1; i >= 0; i-- {
+		if klines[i].CloseTime < now.UnixMilli() {
+			return klines[:i+1]
+		}
+	}
+	return nil
+}
+
+// mergeKlines 按开盘时间合并两段K线（newer中的同一根覆盖older）
+func mergeKlines(older, newer []Kline) []Kline {
+	if len(newer) == 0 {
+		return older
+	}
+	first := newer[0].OpenTime
+	i := len(older)
+	for i > 0 && older[i-1].OpenTime >= first {
+		i--
+	}
+	merged := make([]Kline, 0, i+len(newer))
+	merged = append(merged, older[:i]...)
+	return append(merged, newer...)
+}
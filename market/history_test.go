@@ -0,0 +1,183 @@
+package market
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// fakeKlineProvider 按当前时间生成连续K线的数据源，记录每次请求的数量
+type fakeKlineProvider struct {
+	Provider
+	name   string
+	err    error
+	limits []int
+}
+
+func (p *fakeKlineProvider) Name() string { return p.name }
+
+func (p *fakeKlineProvider) GetKlines(symbol, interval string, limit int) ([]Kline, error) {
+	p.limits = append(p.limits, limit)
+	if p.err != nil {
+		return nil, p.err
+	}
+	d, _ := IntervalDuration(interval)
+	return testKlines(time.Now().Truncate(d), d, limit), nil
+}
+
+// fakeKlineStore 内存中的本地K线存储
+type fakeKlineStore struct {
+	series map[string][]Kline
+}
+
+func (s *fakeKlineStore) LatestKlines(source, symbol, interval string, limit int) ([]Kline, error) {
+	return tailKlines(s.series[source], limit), nil
+}
+
+func (s *fakeKlineStore) AppendKlines(source, symbol, interval string, klines []Kline) error {
+	s.series[source] = mergeKlines(s.series[source], klines)
+	return nil
+}
+
+// testKlines 生成n根以lastOpen为最后一根开盘时间的连续K线
+func testKlines(lastOpen time.Time, d time.Duration, n int) []Kline {
+	klines := make([]Kline, n)
+	for i := range klines {
+		open := lastOpen.Add(-time.Duration(n-1-i) * d)
+		klines[i] = Kline{
+			OpenTime:  open.UnixMilli(),
+			Close:     float64(open.Unix()),
+			CloseTime: open.Add(d).UnixMilli() - 1,
+		}
+	}
+	return klines
+}
+
+func TestFetchKlines(t *testing.T) {
+	const interval, limit = "3m", 200
+	d := 3 * time.Minute
+	current := time.Now().Truncate(d)
+
+	// 本地最后一根与数据源不一致（数据源修订或本地写入了错误数据）
+	mismatched := testKlines(current.Add(-5*d), d, limit)
+	mismatched[len(mismatched)-1].Close++
+	// 本地序列与周期边界错位（时钟偏差时写入）
+	misaligned := testKlines(current.Add(-5*d+time.Minute), d, limit)
+	misalignedFetch := int(time.Since(current.Add(-5*d+time.Minute))/d) + 1 // 取决于当前时间在周期内的位置
+
+	tests := []struct {
+		name       string
+		local      []Kline
+		wantFetch  []int
+		wantAppend bool
+	}{
+		{"本地过旧(10天)", testKlines(current.Add(-4800*d), d, limit), []int{limit}, true},
+		{"本地尾部重叠", testKlines(current.Add(-5*d), d, limit), []int{6}, true},
+		{"本地数据不足", testKlines(current.Add(-2*d), d, 50), []int{limit}, true},
+		{"本地刚好落后limit根", testKlines(current.Add(-limit*d), d, limit), []int{limit}, true},
+		{"重叠K线不一致", mismatched, []int{6, limit}, false},
+		{"本地序列错位", misaligned, []int{misalignedFetch, limit}, false},
+		{"本地数据不足且不一致", mismatched[limit-50:], []int{limit}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &fakeKlineProvider{name: "binance"}
+			store := &fakeKlineStore{series: map[string][]Kline{"binance": tt.local}}
+			SetKlineStore(store)
+			t.Cleanup(func() { SetKlineStore(nil) })
+			before := len(tt.local)
+
+			klines, err := fetchKlines(provider, "BTCUSDT", interval, limit)
+			if err != nil {
+				t.Fatalf("fetchKlines: %v", err)
+			}
+			if fmt.Sprint(provider.limits) != fmt.Sprint(tt.wantFetch) {
+				t.Errorf("请求数量 = %v, 期望 %v", provider.limits, tt.wantFetch)
+			}
+			if appended := len(store.series["binance"]) != before; appended != tt.wantAppend {
+				t.Errorf("写入本地 = %v, 期望 %v", appended, tt.wantAppend)
+			}
+			if len(klines) != limit {
+				t.Fatalf("返回%d根K线, 期望%d", len(klines), limit)
+			}
+			if got := klines[len(klines)-1].OpenTime; got != current.UnixMilli() {
+				t.Errorf("最后一根开盘时间 = %d, 期望当前K线 %d", got, current.UnixMilli())
+			}
+			for i := 1; i < len(klines); i++ {
+				if klines[i].OpenTime-klines[i-1].OpenTime != d.Milliseconds() {
+					t.Fatalf("第%d根K线不连续", i)
+				}
+			}
+		})
+	}
+}
+
+func TestFetchKlinesFallbackStoresByServingProvider(t *testing.T) {
+	d := 3 * time.Minute
+	current := time.Now().Truncate(d)
+	primary := &fakeKlineProvider{name: "hyperliquid", err: errors.New("down")}
+	fallback := &fakeKlineProvider{name: "binance"}
+	store := &fakeKlineStore{series: map[string][]Kline{
+		"hyperliquid": testKlines(current.Add(-3*d), d, 100),
+		"binance":     testKlines(current.Add(-3*d), d, 100),
+	}}
+	SetKlineStore(store)
+	t.Cleanup(func() { SetKlineStore(nil) })
+
+	before := len(store.series["hyperliquid"])
+	if _, err := fetchKlines(NewFallbackProvider(primary, fallback), "BTCUSDT", "3m", 100); err != nil {
+		t.Fatalf("fetchKlines: %v", err)
+	}
+	if got := len(store.series["hyperliquid"]); got != before {
+		t.Errorf("主数据源本地序列被写入了回退数据: %d -> %d", before, got)
+	}
+	if got := store.series["binance"]; got[len(got)-1].OpenTime != current.Add(-d).UnixMilli() {
+		t.Errorf("回退数据源的本地序列未补齐到最近收盘的K线")
+	}
+}
+
+func TestMergeKlines(t *testing.T) {
+	d := time.Minute
+	base := time.Unix(1_700_000_000, 0).Truncate(d)
+	older := testKlines(base, d, 5)
+	overlap := testKlines(base.Add(2*d), d, 4)
+	overlap[0].Close = -1 // 同一根K线以newer为准
+
+	tests := []struct {
+		name      string
+		older     []Kline
+		newer     []Kline
+		wantLen   int
+		wantFirst int64
+		wantLast  int64
+	}{
+		{"newer为空", older, nil, 5, older[0].OpenTime, older[4].OpenTime},
+		{"older为空", nil, overlap, 4, overlap[0].OpenTime, overlap[3].OpenTime},
+		{"尾部重叠", older, overlap, 7, older[0].OpenTime, overlap[3].OpenTime},
+		{"紧接其后", older, testKlines(base.Add(2*d), d, 2), 7, older[0].OpenTime, base.Add(2 * d).UnixMilli()},
+		{"newer覆盖全部", older, testKlines(base.Add(d), d, 8), 8, base.Add(-6 * d).UnixMilli(), base.Add(d).UnixMilli()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged := mergeKlines(tt.older, tt.newer)
+			if len(merged) != tt.wantLen {
+				t.Fatalf("长度 = %d, 期望 %d", len(merged), tt.wantLen)
+			}
+			if merged[0].OpenTime != tt.wantFirst || merged[len(merged)-1].OpenTime != tt.wantLast {
+				t.Errorf("范围 = [%d, %d], 期望 [%d, %d]", merged[0].OpenTime, merged[len(merged)-1].OpenTime, tt.wantFirst, tt.wantLast)
+			}
+			for i := 1; i < len(merged); i++ {
+				if merged[i].OpenTime <= merged[i-1].OpenTime {
+					t.Fatalf("第%d根K线顺序错误", i)
+				}
+			}
+		})
+	}
+
+	if merged := mergeKlines(older, overlap); merged[3].Close != -1 {
+		t.Errorf("重叠的K线应以newer为准")
+	}
+}
@@ -0,0 +1,47 @@
+package market
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+// httpClient 行情接口共享的HTTP客户端（复用连接，避免单个挂起的连接拖住整个决策周期）
+var httpClient = &http.Client{
+	Timeout: 15 * time.Second,
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   5 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   20,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: 10 * time.Second,
+	},
+}
+
+// ContextProvider 支持绑定请求上下文的数据源
+// 绑定后该数据源发出的HTTP请求在上下文取消或到期时立即中止
+type ContextProvider interface {
+	WithContext(ctx context.Context) Provider
+}
+
+// WithContext 返回绑定上下文的数据源（数据源不支持时原样返回）
+func WithContext(ctx context.Context, provider Provider) Provider {
+	if cp, ok := provider.(ContextProvider); ok && ctx != nil {
+		return cp.WithContext(ctx)
+	}
+	return provider
+}
+
+// requestContext 请求使用的上下文（未绑定时不设限，仅受httpClient超时约束）
+func requestContext(ctx context.Context) context.Context {
+	if ctx == nil {
+		return context.Background()
+	}
+	return ctx
+}
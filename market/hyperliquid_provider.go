@@ -0,0 +1,427 @@
+package market
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hyperliquidAssetCtxTTL metaAndAssetCtxs 的缓存时长
+// 该接口一次返回全部币种（权重20），同一周期内各币种共用一次请求，避免超过info接口每分钟1200的权重上限
+const hyperliquidAssetCtxTTL = 30 * time.Second
+
+// HyperliquidProvider Hyperliquid行情数据源
+// K线来自 candleSnapshot，持仓量和资金费率来自 metaAndAssetCtxs，资金费率历史来自 fundingHistory，订单簿来自 l2Book
+type HyperliquidProvider struct {
+	infoURL   string
+	wsURL     string
+	testnet   bool
+	ctx       context.Context        // 请求上下文（见 WithContext）
+	assetCtxs *hyperliquidAssetCache // 资产上下文缓存（WithContext 的副本共用）
+}
+
+// hyperliquidAssetCache metaAndAssetCtxs 的短期缓存（按币种名索引）
+type hyperliquidAssetCache struct {
+	mu        sync.Mutex
+	ctxs      map[string]hyperliquidAssetCtx
+	coins     map[string]string // 大写币种名 -> Hyperliquid币种名（如 "KPEPE" -> "kPEPE"）
+	fetchedAt time.Time
+}
+
+// NewHyperliquidProvider 创建Hyperliquid行情数据源
+func NewHyperliquidProvider(testnet bool) *HyperliquidProvider {
+	if testnet {
+		return &HyperliquidProvider{
+			infoURL:   "https://api.hyperliquid-testnet.xyz/info",
+			wsURL:     "wss://api.hyperliquid-testnet.xyz/ws",
+			testnet:   true,
+			assetCtxs: &hyperliquidAssetCache{},
+		}
+	}
+	return &HyperliquidProvider{
+		infoURL:   "https://api.hyperliquid.xyz/info",
+		wsURL:     "wss://api.hyperliquid.xyz/ws",
+		assetCtxs: &hyperliquidAssetCache{},
+	}
+}
+
+// Name 数据源名称
+func (p *HyperliquidProvider) Name() string {
+	if p.testnet {
+		return "hyperliquid-testnet"
+	}
+	return "hyperliquid"
+}
+
+// WithContext 返回绑定请求上下文的副本
+func (p *HyperliquidProvider) WithContext(ctx context.Context) Provider {
+	cp := *p
+	cp.ctx = ctx
+	return &cp
+}
+
+// klineStreamCodec K线推送协议
+func (p *HyperliquidProvider) klineStreamCodec() klineStreamCodec {
+	return &hyperliquidStreamCodec{wsURL: p.wsURL, coin: p.coin}
+}
+
+// GetKlines 从Hyperliquid获取K线数据
+func (p *HyperliquidProvider) GetKlines(symbol, interval string, limit int) ([]Kline, error) {
+	duration, err := IntervalDuration(interval)
+	if err != nil {
+		return nil, err
+	}
+
+	// candleSnapshot 按时间范围查询，根据limit反推起始时间
+	endTime := time.Now()
+	startTime := endTime.Add(-time.Duration(limit) * duration)
+
+	klines, err := p.candleSnapshot(symbol, interval, startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+	if len(klines) == 0 {
+		return nil, fmt.Errorf("Hyperliquid未返回 %s 的K线数据", symbol)
+	}
+
+	// 只保留最近limit根
+	if len(klines) > limit {
+		klines = klines[len(klines)-limit:]
+	}
+
+	return klines, nil
+}
+
+// candleSnapshot 按时间范围查询K线（单次最多返回5000根）
+func (p *HyperliquidProvider) candleSnapshot(symbol, interval string, start, end time.Time) ([]Kline, error) {
+	body, err := p.post(map[string]interface{}{
+		"type": "candleSnapshot",
+		"req": map[string]interface{}{
+			"coin":      p.coin(symbol),
+			"interval":  interval,
+			"startTime": start.UnixMilli(),
+			"endTime":   end.UnixMilli(),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var candles []struct {
+		OpenTime  int64  `json:"t"`
+		CloseTime int64  `json:"T"`
+		Open      string `json:"o"`
+		High      string `json:"h"`
+		Low       string `json:"l"`
+		Close     string `json:"c"`
+		Volume    string `json:"v"`
+	}
+	if err := json.Unmarshal(body, &candles); err != nil {
+		return nil, err
+	}
+
+	klines := make([]Kline, len(candles))
+	for i, c := range candles {
+		open, _ := strconv.ParseFloat(c.Open, 64)
+		high, _ := strconv.ParseFloat(c.High, 64)
+		low, _ := strconv.ParseFloat(c.Low, 64)
+		close, _ := strconv.ParseFloat(c.Close, 64)
+		volume, _ := strconv.ParseFloat(c.Volume, 64)
+
+		klines[i] = Kline{
+			OpenTime:  c.OpenTime,
+			Open:      open,
+			High:      high,
+			Low:       low,
+			Close:     close,
+			Volume:    volume,
+			CloseTime: c.CloseTime,
+		}
+	}
+
+	return klines, nil
+}
+
+// GetOpenInterest 从Hyperliquid获取持仓量
+func (p *HyperliquidProvider) GetOpenInterest(symbol string) (*OIData, error) {
+	ctx, err := p.getAssetCtx(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	oi, _ := strconv.ParseFloat(ctx.OpenInterest, 64)
+
+	return &OIData{Latest: oi}, nil
+}
+
+// GetOpenInterestHistory Hyperliquid未提供历史持仓量接口
+func (p *HyperliquidProvider) GetOpenInterestHistory(symbol, period string, limit int) ([]OIPoint, error) {
+	return nil, fmt.Errorf("%w: hyperliquid 历史持仓量", ErrNotSupported)
+}
+
+// GetFunding 从Hyperliquid获取资金费率、标记价格和预言机价格
+// 注意：Hyperliquid每小时结算一次资金费率（币安为8小时）
+func (p *HyperliquidProvider) GetFunding(symbol string) (*FundingData, error) {
+	ctx, err := p.getAssetCtx(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	rate, _ := strconv.ParseFloat(ctx.Funding, 64)
+	markPrice, _ := strconv.ParseFloat(ctx.MarkPx, 64)
+	oraclePrice, _ := strconv.ParseFloat(ctx.OraclePx, 64)
+
+	return &FundingData{
+		Rate:            rate,
+		IntervalHours:   1,
+		NextFundingTime: time.Now().Truncate(time.Hour).Add(time.Hour).UnixMilli(), // 每个整点结算
+		MarkPrice:       markPrice,
+		IndexPrice:      oraclePrice,
+	}, nil
+}
+
+// GetFundingHistory 从Hyperliquid获取已结算的资金费率历史
+func (p *HyperliquidProvider) GetFundingHistory(symbol string, limit int) ([]FundingPoint, error) {
+	now := time.Now()
+	history, err := p.fundingHistory(symbol, now.Add(-time.Duration(limit+1)*time.Hour), now)
+	if err != nil {
+		return nil, err
+	}
+	if len(history) > limit {
+		history = history[len(history)-limit:]
+	}
+	return history, nil
+}
+
+// fundingHistory 按时间范围查询资金费率历史（单次最多返回500条）
+func (p *HyperliquidProvider) fundingHistory(symbol string, start, end time.Time) ([]FundingPoint, error) {
+	body, err := p.post(map[string]interface{}{
+		"type":      "fundingHistory",
+		"coin":      p.coin(symbol),
+		"startTime": start.UnixMilli(),
+		"endTime":   end.UnixMilli(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var rawData []struct {
+		FundingRate string `json:"fundingRate"`
+		Time        int64  `json:"time"`
+	}
+	if err := json.Unmarshal(body, &rawData); err != nil {
+		return nil, err
+	}
+
+	history := make([]FundingPoint, 0, len(rawData))
+	for _, item := range rawData {
+		rate, _ := strconv.ParseFloat(item.FundingRate, 64)
+		history = append(history, FundingPoint{Time: item.Time, Rate: rate})
+	}
+
+	return history, nil
+}
+
+// GetLongShortRatio Hyperliquid未提供多空比接口
+func (p *HyperliquidProvider) GetLongShortRatio(symbol, scope, period string, limit int) ([]LongShortPoint, error) {
+	return nil, fmt.Errorf("%w: hyperliquid 多空比", ErrNotSupported)
+}
+
+// GetTakerVolume Hyperliquid未提供主动买卖量接口
+func (p *HyperliquidProvider) GetTakerVolume(symbol, period string, limit int) ([]TakerVolumePoint, error) {
+	return nil, fmt.Errorf("%w: hyperliquid 主动买卖量", ErrNotSupported)
+}
+
+// GetLiquidations Hyperliquid未提供公开的强平推送
+func (p *HyperliquidProvider) GetLiquidations(symbol string) (*LiquidationStats, error) {
+	return nil, fmt.Errorf("%w: hyperliquid 强平推送", ErrNotSupported)
+}
+
+// GetOrderBook 从Hyperliquid获取订单簿快照（接口每侧最多返回20档）
+func (p *HyperliquidProvider) GetOrderBook(symbol string, limit int) (*OrderBook, error) {
+	body, err := p.post(map[string]interface{}{
+		"type": "l2Book",
+		"coin": p.coin(symbol),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Time   int64 `json:"time"`
+		Levels [2][]struct {
+			Px string `json:"px"`
+			Sz string `json:"sz"`
+		} `json:"levels"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+
+	book := &OrderBook{Time: result.Time}
+	for side, levels := range result.Levels {
+		for i, level := range levels {
+			if i >= limit {
+				break
+			}
+			price, _ := strconv.ParseFloat(level.Px, 64)
+			qty, _ := strconv.ParseFloat(level.Sz, 64)
+			if side == 0 {
+				book.Bids = append(book.Bids, BookLevel{Price: price, Quantity: qty})
+			} else {
+				book.Asks = append(book.Asks, BookLevel{Price: price, Quantity: qty})
+			}
+		}
+	}
+
+	return book, nil
+}
+
+// hyperliquidAssetCtx metaAndAssetCtxs 返回的单个资产上下文
+type hyperliquidAssetCtx struct {
+	Funding      string `json:"funding"`
+	OpenInterest string `json:"openInterest"`
+	MarkPx       string `json:"markPx"`
+	OraclePx     string `json:"oraclePx"`
+	MidPx        string `json:"midPx"`
+	Premium      string `json:"premium"`
+	DayNtlVlm    string `json:"dayNtlVlm"`
+}
+
+// getAssetCtx 获取指定币种的资产上下文（全部币种缓存 hyperliquidAssetCtxTTL，并发请求只发送一次）
+func (p *HyperliquidProvider) getAssetCtx(symbol string) (*hyperliquidAssetCtx, error) {
+	cache := p.assetCtxs
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if err := p.loadAssetCtxsLocked(); err != nil {
+		return nil, err
+	}
+	if ctx, ok := cache.ctxs[cache.coins[hyperliquidCoin(symbol)]]; ok {
+		return &ctx, nil
+	}
+	return nil, fmt.Errorf("Hyperliquid未上线 %s", symbol)
+}
+
+// loadAssetCtxsLocked 缓存过期时重新获取全部币种的资产上下文（调用方需持有 assetCtxs.mu）
+func (p *HyperliquidProvider) loadAssetCtxsLocked() error {
+	cache := p.assetCtxs
+	if cache.ctxs != nil && time.Since(cache.fetchedAt) < hyperliquidAssetCtxTTL {
+		return nil
+	}
+	ctxs, err := p.fetchAssetCtxs()
+	if err != nil {
+		return err
+	}
+	cache.ctxs = ctxs
+	cache.coins = make(map[string]string, len(ctxs))
+	for name := range ctxs {
+		cache.coins[strings.ToUpper(name)] = name
+	}
+	cache.fetchedAt = time.Now()
+	return nil
+}
+
+// coin 将标准symbol转换为Hyperliquid币种名
+// 标准symbol是大写的，而部分币种名含小写（如 kPEPE、kSHIB），按 meta 中的币种名还原；
+// 币种名不会变化，已知的币种不触发刷新，meta获取失败时使用大写币种名
+func (p *HyperliquidProvider) coin(symbol string) string {
+	coin := hyperliquidCoin(symbol)
+	cache := p.assetCtxs
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if name, ok := cache.coins[coin]; ok {
+		return name
+	}
+	if err := p.loadAssetCtxsLocked(); err == nil {
+		if name, ok := cache.coins[coin]; ok {
+			return name
+		}
+	}
+	return coin
+}
+
+// fetchAssetCtxs 获取全部币种的资产上下文
+func (p *HyperliquidProvider) fetchAssetCtxs() (map[string]hyperliquidAssetCtx, error) {
+	body, err := p.post(map[string]interface{}{
+		"type": "metaAndAssetCtxs",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 返回格式: [meta, [assetCtx...]]，两个数组按下标一一对应
+	var raw []json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, err
+	}
+	if len(raw) < 2 {
+		return nil, fmt.Errorf("metaAndAssetCtxs 返回格式错误")
+	}
+
+	var meta struct {
+		Universe []struct {
+			Name string `json:"name"`
+		} `json:"universe"`
+	}
+	if err := json.Unmarshal(raw[0], &meta); err != nil {
+		return nil, err
+	}
+
+	var ctxs []hyperliquidAssetCtx
+	if err := json.Unmarshal(raw[1], &ctxs); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]hyperliquidAssetCtx, len(meta.Universe))
+	for i, asset := range meta.Universe {
+		if i < len(ctxs) {
+			result[asset.Name] = ctxs[i]
+		}
+	}
+	return result, nil
+}
+
+// post 向info接口发送POST请求
+func (p *HyperliquidProvider) post(payload map[string]interface{}) ([]byte, error) {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(requestContext(p.ctx), http.MethodPost, p.infoURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(body))
+	}
+
+	return body, nil
+}
+
+// hyperliquidCoin 将标准symbol转换为大写的Hyperliquid币种名（如 "BTCUSDT" -> "BTC"，请求时经 coin 还原大小写）
+func hyperliquidCoin(symbol string) string {
+	return strings.TrimSuffix(Normalize(symbol), "USDT")
+}
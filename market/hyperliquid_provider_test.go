@@ -0,0 +1,99 @@
+package market
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// fakeHyperliquidInfo 模拟info接口：返回固定的 meta 币种，记录其他请求使用的币种名
+func fakeHyperliquidInfo(t *testing.T) (*HyperliquidProvider, func() ([]string, int)) {
+	var (
+		mu        sync.Mutex
+		coins     []string
+		metaCalls int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("解析请求失败: %v", err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch req["type"] {
+		case "metaAndAssetCtxs":
+			metaCalls++
+			w.Write([]byte(`[{"universe": [{"name": "BTC"}, {"name": "kPEPE"}]}, [{"funding": "0.0001"}, {"funding": "0.0002"}]]`))
+		case "candleSnapshot":
+			coins = append(coins, req["req"].(map[string]interface{})["coin"].(string))
+			w.Write([]byte(`[]`))
+		default:
+			coins = append(coins, req["coin"].(string))
+			w.Write([]byte(`[]`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	p := &HyperliquidProvider{infoURL: srv.URL, assetCtxs: &hyperliquidAssetCache{}}
+	return p, func() ([]string, int) {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), coins...), metaCalls
+	}
+}
+
+func TestHyperliquidCoinCase(t *testing.T) {
+	p, requests := fakeHyperliquidInfo(t)
+
+	tests := []struct {
+		symbol string
+		want   string
+	}{
+		{"kPEPEUSDT", "kPEPE"},
+		{"KPEPEUSDT", "kPEPE"},
+		{"btc", "BTC"},
+		{"NEWUSDT", "NEW"}, // 未上线的币种保持大写
+	}
+	for _, tt := range tests {
+		if got := p.coin(tt.symbol); got != tt.want {
+			t.Errorf("coin(%s) = %s, 期望 %s", tt.symbol, got, tt.want)
+		}
+	}
+
+	// K线、订单簿、资金费率历史请求都使用还原大小写后的币种名
+	p.GetKlines("KPEPEUSDT", "1h", 10)
+	p.GetOrderBook("KPEPEUSDT", 20)
+	p.GetFundingHistory("KPEPEUSDT", 10)
+	coins, _ := requests()
+	for i, coin := range coins {
+		if coin != "kPEPE" {
+			t.Errorf("第%d个请求的币种名 = %s, 期望 kPEPE", i+1, coin)
+		}
+	}
+	if len(coins) != 3 {
+		t.Errorf("请求数 = %d, 期望3", len(coins))
+	}
+
+	// 资产上下文按还原后的币种名查找
+	funding, err := p.GetFunding("KPEPEUSDT")
+	if err != nil {
+		t.Fatalf("GetFunding: %v", err)
+	}
+	if funding.Rate != 0.0002 {
+		t.Errorf("资金费率 = %v, 期望 0.0002", funding.Rate)
+	}
+	if _, metaCalls := requests(); metaCalls != 1 {
+		t.Errorf("metaAndAssetCtxs 请求%d次, 期望缓存期内只请求1次", metaCalls)
+	}
+}
+
+func TestHyperliquidStreamCodecCoin(t *testing.T) {
+	p, _ := fakeHyperliquidInfo(t)
+	msgs := p.klineStreamCodec().subscribeMessages([]streamKey{{Symbol: "KPEPEUSDT", Interval: "1m"}})
+	sub := msgs[0].(map[string]interface{})["subscription"].(map[string]interface{})
+	if sub["coin"] != "kPEPE" {
+		t.Errorf("订阅币种名 = %v, 期望 kPEPE", sub["coin"])
+	}
+}
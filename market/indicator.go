@@ -0,0 +1,193 @@
+package market
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Indicator 技术指标接口
+// 新增指标只需实现该接口并通过 RegisterIndicator 注册，market.Data 和 market.Format 无需改动
+type Indicator interface {
+	// Key 指标唯一标识（如 "ema20"、"bollinger20"），用于 TimeframeData.Latest 查询
+	Key() string
+
+	// Label 展示名称（用于prompt）
+	Label() string
+
+	// Calculate 计算指标，每条输出线与klines等长，预热期内的值为NaN
+	Calculate(klines []Kline) []IndicatorLine
+}
+
+// IndicatorLine 指标的一条输出线（如布林带的 upper/middle/lower）
+type IndicatorLine struct {
+	Name   string    // 输出线名称（单线指标为空）
+	Label  string    // 展示名称（为空时由指标Label和Name组合）
+	Values []float64 // 与K线等长，预热期内为NaN
+}
+
+// IndicatorFactory 根据参数创建指标
+type IndicatorFactory func(params map[string]float64) (Indicator, error)
+
+// IndicatorConfig 指标配置
+// JSON中可以写成字符串简写（如 "ema20"、"rsi7"、"bollinger"），
+// 也可以写成对象 {"name": "bollinger", "params": {"period": 20, "stddev": 2}}
+type IndicatorConfig struct {
+	Name   string             `json:"name"`
+	Params map[string]float64 `json:"params,omitempty"`
+}
+
+// UnmarshalJSON 同时支持字符串简写和对象两种写法
+func (c *IndicatorConfig) UnmarshalJSON(data []byte) error {
+	var shorthand string
+	if err := json.Unmarshal(data, &shorthand); err == nil {
+		parsed, err := ParseIndicatorConfig(shorthand)
+		if err != nil {
+			return err
+		}
+		*c = parsed
+		return nil
+	}
+
+	type plain IndicatorConfig
+	var cfg plain
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return fmt.Errorf("无效的指标配置: %s", string(data))
+	}
+	*c = IndicatorConfig(cfg)
+	return nil
+}
+
+// ParseIndicatorConfig 解析指标简写，末尾数字作为period参数（如 "ema20" -> ema{period:20}）
+func ParseIndicatorConfig(s string) (IndicatorConfig, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	name := strings.TrimRight(s, "0123456789")
+	if name == "" {
+		return IndicatorConfig{}, fmt.Errorf("无效的指标: %s", s)
+	}
+
+	cfg := IndicatorConfig{Name: name}
+	if digits := s[len(name):]; digits != "" {
+		period, err := strconv.Atoi(digits)
+		if err != nil || period <= 0 {
+			return IndicatorConfig{}, fmt.Errorf("无效的指标周期: %s", s)
+		}
+		cfg.Params = map[string]float64{"period": float64(period)}
+	}
+	return cfg, nil
+}
+
+// mustIndicators 解析一组指标简写（仅用于内置默认配置）
+func mustIndicators(names ...string) []IndicatorConfig {
+	configs := make([]IndicatorConfig, len(names))
+	for i, name := range names {
+		cfg, err := ParseIndicatorConfig(name)
+		if err != nil {
+			panic(err)
+		}
+		configs[i] = cfg
+	}
+	return configs
+}
+
+var (
+	indicatorRegistryMu sync.RWMutex
+	indicatorRegistry   = make(map[string]IndicatorFactory)
+)
+
+// RegisterIndicator 注册指标
+func RegisterIndicator(name string, factory IndicatorFactory) {
+	indicatorRegistryMu.Lock()
+	defer indicatorRegistryMu.Unlock()
+	indicatorRegistry[strings.ToLower(name)] = factory
+}
+
+// NewIndicator 根据配置创建指标
+func NewIndicator(cfg IndicatorConfig) (Indicator, error) {
+	indicatorRegistryMu.RLock()
+	factory, ok := indicatorRegistry[strings.ToLower(cfg.Name)]
+	indicatorRegistryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("不支持的指标: %s（可用: %s）", cfg.Name, strings.Join(RegisteredIndicators(), ", "))
+	}
+
+	indicator, err := factory(cfg.Params)
+	if err != nil {
+		return nil, fmt.Errorf("指标 %s 参数错误: %w", cfg.Name, err)
+	}
+	return indicator, nil
+}
+
+// RegisteredIndicators 已注册的指标名称（按字母排序）
+func RegisteredIndicators() []string {
+	indicatorRegistryMu.RLock()
+	defer indicatorRegistryMu.RUnlock()
+
+	names := make([]string, 0, len(indicatorRegistry))
+	for name := range indicatorRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// intParam 读取整数参数（缺省时使用默认值）
+func intParam(params map[string]float64, key string, defaultValue int) (int, error) {
+	v, ok := params[key]
+	if !ok {
+		return defaultValue, nil
+	}
+	if v <= 0 || v != math.Trunc(v) {
+		return 0, fmt.Errorf("%s必须是正整数: %v", key, v)
+	}
+	return int(v), nil
+}
+
+// floatParam 读取浮点参数（缺省时使用默认值）
+func floatParam(params map[string]float64, key string, defaultValue float64) (float64, error) {
+	v, ok := params[key]
+	if !ok {
+		return defaultValue, nil
+	}
+	if v <= 0 {
+		return 0, fmt.Errorf("%s必须大于0: %v", key, v)
+	}
+	return v, nil
+}
+
+// calculateIndicatorSeries 计算指标并截取最近points个有效值
+func calculateIndicatorSeries(indicator Indicator, klines []Kline, points int) []IndicatorSeries {
+	lines := indicator.Calculate(klines)
+	result := make([]IndicatorSeries, 0, len(lines))
+
+	for _, line := range lines {
+		start := len(line.Values) - points
+		if start < 0 {
+			start = 0
+		}
+
+		values := make([]float64, 0, points)
+		for _, v := range line.Values[start:] {
+			if !math.IsNaN(v) {
+				values = append(values, v)
+			}
+		}
+
+		name := indicator.Key()
+		label := indicator.Label()
+		if line.Name != "" {
+			name += "." + line.Name
+			label += " " + line.Name
+		}
+		if line.Label != "" {
+			label = line.Label
+		}
+		result = append(result, IndicatorSeries{Name: name, Label: label, Values: values})
+	}
+
+	return result
+}
@@ -0,0 +1,658 @@
+package market
+
+import (
+	"fmt"
+	"math"
+)
+
+// 内置指标
+func init() {
+	RegisterIndicator("ema", newEMAIndicator)
+	RegisterIndicator("rsi", newRSIIndicator)
+	RegisterIndicator("atr", newATRIndicator)
+	RegisterIndicator("macd", newMACDIndicator)
+	RegisterIndicator("volume", newVolumeIndicator)
+	RegisterIndicator("bollinger", newBollingerIndicator)
+	RegisterIndicator("vwap", newVWAPIndicator)
+	RegisterIndicator("adx", newADXIndicator)
+	RegisterIndicator("dmi", newADXIndicator)
+	RegisterIndicator("stochrsi", newStochRSIIndicator)
+	RegisterIndicator("obv", newOBVIndicator)
+	RegisterIndicator("supertrend", newSupertrendIndicator)
+	RegisterIndicator("keltner", newKeltnerIndicator)
+}
+
+// emaIndicator 指数移动平均
+type emaIndicator struct {
+	period int
+}
+
+func newEMAIndicator(params map[string]float64) (Indicator, error) {
+	period, err := intParam(params, "period", 20)
+	if err != nil {
+		return nil, err
+	}
+	return &emaIndicator{period: period}, nil
+}
+
+func (ind *emaIndicator) Key() string { return fmt.Sprintf("ema%d", ind.period) }
+
+func (ind *emaIndicator) Label() string {
+	return fmt.Sprintf("EMA indicators (%d‑period)", ind.period)
+}
+
+func (ind *emaIndicator) Calculate(klines []Kline) []IndicatorLine {
+	return []IndicatorLine{{Values: emaOf(closesOf(klines), ind.period)}}
+}
+
+// rsiIndicator 相对强弱指数（Wilder平滑）
+type rsiIndicator struct {
+	period int
+}
+
+func newRSIIndicator(params map[string]float64) (Indicator, error) {
+	period, err := intParam(params, "period", 14)
+	if err != nil {
+		return nil, err
+	}
+	return &rsiIndicator{period: period}, nil
+}
+
+func (ind *rsiIndicator) Key() string { return fmt.Sprintf("rsi%d", ind.period) }
+
+func (ind *rsiIndicator) Label() string {
+	return fmt.Sprintf("RSI indicators (%d‑Period)", ind.period)
+}
+
+func (ind *rsiIndicator) Calculate(klines []Kline) []IndicatorLine {
+	return []IndicatorLine{{Values: rsiOf(closesOf(klines), ind.period)}}
+}
+
+// atrIndicator 平均真实波幅
+type atrIndicator struct {
+	period int
+}
+
+func newATRIndicator(params map[string]float64) (Indicator, error) {
+	period, err := intParam(params, "period", 14)
+	if err != nil {
+		return nil, err
+	}
+	return &atrIndicator{period: period}, nil
+}
+
+func (ind *atrIndicator) Key() string { return fmt.Sprintf("atr%d", ind.period) }
+
+func (ind *atrIndicator) Label() string {
+	return fmt.Sprintf("ATR indicators (%d‑Period)", ind.period)
+}
+
+func (ind *atrIndicator) Calculate(klines []Kline) []IndicatorLine {
+	return []IndicatorLine{{Values: rmaOf(trueRangeOf(klines), ind.period)}}
+}
+
+// macdIndicator MACD（快慢EMA差值 + 信号线）
+type macdIndicator struct {
+	fast, slow, signal int
+}
+
+func newMACDIndicator(params map[string]float64) (Indicator, error) {
+	fast, err := intParam(params, "fast", 12)
+	if err != nil {
+		return nil, err
+	}
+	slow, err := intParam(params, "slow", 26)
+	if err != nil {
+		return nil, err
+	}
+	signal, err := intParam(params, "signal", 9)
+	if err != nil {
+		return nil, err
+	}
+	if fast >= slow {
+		return nil, fmt.Errorf("fast(%d)必须小于slow(%d)", fast, slow)
+	}
+	return &macdIndicator{fast: fast, slow: slow, signal: signal}, nil
+}
+
+func (ind *macdIndicator) Key() string {
+	if ind.fast == 12 && ind.slow == 26 && ind.signal == 9 {
+		return "macd"
+	}
+	return fmt.Sprintf("macd%d_%d_%d", ind.fast, ind.slow, ind.signal)
+}
+
+func (ind *macdIndicator) Label() string {
+	if ind.fast == 12 && ind.slow == 26 && ind.signal == 9 {
+		return "MACD indicators"
+	}
+	return fmt.Sprintf("MACD indicators (%d, %d, %d)", ind.fast, ind.slow, ind.signal)
+}
+
+func (ind *macdIndicator) Calculate(klines []Kline) []IndicatorLine {
+	closes := closesOf(klines)
+	fast := emaOf(closes, ind.fast)
+	slow := emaOf(closes, ind.slow)
+
+	macd := nanSeries(len(klines))
+	for i := range macd {
+		macd[i] = fast[i] - slow[i] // 任一为NaN时结果为NaN
+	}
+
+	return []IndicatorLine{
+		{Values: macd},
+		{Name: "signal", Values: emaOf(macd, ind.signal)},
+	}
+}
+
+// volumeIndicator 成交量
+type volumeIndicator struct{}
+
+func newVolumeIndicator(params map[string]float64) (Indicator, error) {
+	return &volumeIndicator{}, nil
+}
+
+func (ind *volumeIndicator) Key() string { return "volume" }
+
+func (ind *volumeIndicator) Label() string { return "Volume" }
+
+func (ind *volumeIndicator) Calculate(klines []Kline) []IndicatorLine {
+	volumes := make([]float64, len(klines))
+	sum := 0.0
+	for i, k := range klines {
+		volumes[i] = k.Volume
+		sum += k.Volume
+	}
+
+	line := IndicatorLine{Values: volumes}
+	if len(klines) > 0 {
+		line.Label = fmt.Sprintf("Volume (average %.3f)", sum/float64(len(klines)))
+	}
+	return []IndicatorLine{line}
+}
+
+// bollingerIndicator 布林带
+type bollingerIndicator struct {
+	period int
+	stddev float64
+}
+
+func newBollingerIndicator(params map[string]float64) (Indicator, error) {
+	period, err := intParam(params, "period", 20)
+	if err != nil {
+		return nil, err
+	}
+	stddev, err := floatParam(params, "stddev", 2)
+	if err != nil {
+		return nil, err
+	}
+	return &bollingerIndicator{period: period, stddev: stddev}, nil
+}
+
+func (ind *bollingerIndicator) Key() string { return fmt.Sprintf("bollinger%d", ind.period) }
+
+func (ind *bollingerIndicator) Label() string {
+	return fmt.Sprintf("Bollinger Bands (%d, %.1fσ)", ind.period, ind.stddev)
+}
+
+func (ind *bollingerIndicator) Calculate(klines []Kline) []IndicatorLine {
+	closes := closesOf(klines)
+	middle := smaOf(closes, ind.period)
+	upper := nanSeries(len(klines))
+	lower := nanSeries(len(klines))
+
+	for i := ind.period - 1; i < len(closes); i++ {
+		variance := 0.0
+		for _, c := range closes[i-ind.period+1 : i+1] {
+			variance += (c - middle[i]) * (c - middle[i])
+		}
+		sd := math.Sqrt(variance / float64(ind.period))
+		upper[i] = middle[i] + ind.stddev*sd
+		lower[i] = middle[i] - ind.stddev*sd
+	}
+
+	return []IndicatorLine{
+		{Name: "upper", Values: upper},
+		{Name: "middle", Values: middle},
+		{Name: "lower", Values: lower},
+	}
+}
+
+// vwapIndicator 成交量加权均价（period为0时从窗口第一根K线开始累计）
+type vwapIndicator struct {
+	period int
+}
+
+func newVWAPIndicator(params map[string]float64) (Indicator, error) {
+	period := 0
+	if _, ok := params["period"]; ok {
+		p, err := intParam(params, "period", 0)
+		if err != nil {
+			return nil, err
+		}
+		period = p
+	}
+	return &vwapIndicator{period: period}, nil
+}
+
+func (ind *vwapIndicator) Key() string {
+	if ind.period == 0 {
+		return "vwap"
+	}
+	return fmt.Sprintf("vwap%d", ind.period)
+}
+
+func (ind *vwapIndicator) Label() string {
+	if ind.period == 0 {
+		return "VWAP (cumulative over fetched candles)"
+	}
+	return fmt.Sprintf("VWAP (rolling %d bars)", ind.period)
+}
+
+func (ind *vwapIndicator) Calculate(klines []Kline) []IndicatorLine {
+	values := nanSeries(len(klines))
+	pv, vol := 0.0, 0.0
+
+	for i, k := range klines {
+		pv += (k.High + k.Low + k.Close) / 3 * k.Volume
+		vol += k.Volume
+		if ind.period > 0 && i >= ind.period {
+			old := klines[i-ind.period]
+			pv -= (old.High + old.Low + old.Close) / 3 * old.Volume
+			vol -= old.Volume
+		}
+		if ind.period > 0 && i < ind.period-1 {
+			continue
+		}
+		if vol > 0 {
+			values[i] = pv / vol
+		}
+	}
+
+	return []IndicatorLine{{Values: values}}
+}
+
+// adxIndicator 平均趋向指数及方向指标（ADX / +DI / -DI）
+type adxIndicator struct {
+	period int
+}
+
+func newADXIndicator(params map[string]float64) (Indicator, error) {
+	period, err := intParam(params, "period", 14)
+	if err != nil {
+		return nil, err
+	}
+	return &adxIndicator{period: period}, nil
+}
+
+func (ind *adxIndicator) Key() string { return fmt.Sprintf("adx%d", ind.period) }
+
+func (ind *adxIndicator) Label() string {
+	return fmt.Sprintf("ADX/DMI (%d‑Period)", ind.period)
+}
+
+func (ind *adxIndicator) Calculate(klines []Kline) []IndicatorLine {
+	n := len(klines)
+	plusDM := nanSeries(n)
+	minusDM := nanSeries(n)
+	for i := 1; i < n; i++ {
+		up := klines[i].High - klines[i-1].High
+		down := klines[i-1].Low - klines[i].Low
+		plusDM[i], minusDM[i] = 0, 0
+		if up > down && up > 0 {
+			plusDM[i] = up
+		}
+		if down > up && down > 0 {
+			minusDM[i] = down
+		}
+	}
+
+	atr := rmaOf(trueRangeOf(klines), ind.period)
+	smoothPlus := rmaOf(plusDM, ind.period)
+	smoothMinus := rmaOf(minusDM, ind.period)
+
+	plusDI := nanSeries(n)
+	minusDI := nanSeries(n)
+	dx := nanSeries(n)
+	for i := 0; i < n; i++ {
+		if math.IsNaN(atr[i]) || atr[i] == 0 {
+			continue
+		}
+		plusDI[i] = 100 * smoothPlus[i] / atr[i]
+		minusDI[i] = 100 * smoothMinus[i] / atr[i]
+		if sum := plusDI[i] + minusDI[i]; sum > 0 {
+			dx[i] = 100 * math.Abs(plusDI[i]-minusDI[i]) / sum
+		} else {
+			dx[i] = 0
+		}
+	}
+
+	return []IndicatorLine{
+		{Name: "adx", Values: rmaOf(dx, ind.period)},
+		{Name: "+di", Values: plusDI},
+		{Name: "-di", Values: minusDI},
+	}
+}
+
+// stochRSIIndicator 随机RSI（%K / %D）
+type stochRSIIndicator struct {
+	rsiPeriod, stochPeriod, k, d int
+}
+
+func newStochRSIIndicator(params map[string]float64) (Indicator, error) {
+	// period 同时作为 rsi_period 和 stoch_period 的默认值（兼容 "stochrsi14" 简写）
+	period, err := intParam(params, "period", 14)
+	if err != nil {
+		return nil, err
+	}
+	rsiPeriod, err := intParam(params, "rsi_period", period)
+	if err != nil {
+		return nil, err
+	}
+	stochPeriod, err := intParam(params, "stoch_period", period)
+	if err != nil {
+		return nil, err
+	}
+	k, err := intParam(params, "k", 3)
+	if err != nil {
+		return nil, err
+	}
+	d, err := intParam(params, "d", 3)
+	if err != nil {
+		return nil, err
+	}
+	return &stochRSIIndicator{rsiPeriod: rsiPeriod, stochPeriod: stochPeriod, k: k, d: d}, nil
+}
+
+func (ind *stochRSIIndicator) Key() string { return fmt.Sprintf("stochrsi%d", ind.rsiPeriod) }
+
+func (ind *stochRSIIndicator) Label() string {
+	return fmt.Sprintf("Stochastic RSI (%d, %d, %d, %d)", ind.rsiPeriod, ind.stochPeriod, ind.k, ind.d)
+}
+
+func (ind *stochRSIIndicator) Calculate(klines []Kline) []IndicatorLine {
+	rsi := rsiOf(closesOf(klines), ind.rsiPeriod)
+	stoch := nanSeries(len(klines))
+
+	for i := range rsi {
+		if i < ind.stochPeriod-1 || math.IsNaN(rsi[i-ind.stochPeriod+1]) {
+			continue
+		}
+		lo, hi := math.Inf(1), math.Inf(-1)
+		for _, v := range rsi[i-ind.stochPeriod+1 : i+1] {
+			lo = math.Min(lo, v)
+			hi = math.Max(hi, v)
+		}
+		if hi > lo {
+			stoch[i] = (rsi[i] - lo) / (hi - lo) * 100
+		} else {
+			stoch[i] = 50 // 区间内RSI无变化时取中值
+		}
+	}
+
+	k := smaOf(stoch, ind.k)
+	return []IndicatorLine{
+		{Name: "k", Values: k},
+		{Name: "d", Values: smaOf(k, ind.d)},
+	}
+}
+
+// obvIndicator 能量潮（从窗口第一根K线开始累计）
+type obvIndicator struct{}
+
+func newOBVIndicator(params map[string]float64) (Indicator, error) {
+	return &obvIndicator{}, nil
+}
+
+func (ind *obvIndicator) Key() string { return "obv" }
+
+func (ind *obvIndicator) Label() string { return "OBV" }
+
+func (ind *obvIndicator) Calculate(klines []Kline) []IndicatorLine {
+	values := make([]float64, len(klines))
+	for i := 1; i < len(klines); i++ {
+		values[i] = values[i-1]
+		switch {
+		case klines[i].Close > klines[i-1].Close:
+			values[i] += klines[i].Volume
+		case klines[i].Close < klines[i-1].Close:
+			values[i] -= klines[i].Volume
+		}
+	}
+	return []IndicatorLine{{Values: values}}
+}
+
+// supertrendIndicator 超级趋势（value为当前止损线，direction为1表示上升趋势、-1表示下降趋势）
+type supertrendIndicator struct {
+	period     int
+	multiplier float64
+}
+
+func newSupertrendIndicator(params map[string]float64) (Indicator, error) {
+	period, err := intParam(params, "period", 10)
+	if err != nil {
+		return nil, err
+	}
+	multiplier, err := floatParam(params, "multiplier", 3)
+	if err != nil {
+		return nil, err
+	}
+	return &supertrendIndicator{period: period, multiplier: multiplier}, nil
+}
+
+func (ind *supertrendIndicator) Key() string { return fmt.Sprintf("supertrend%d", ind.period) }
+
+func (ind *supertrendIndicator) Label() string {
+	return fmt.Sprintf("Supertrend (%d, %.1f)", ind.period, ind.multiplier)
+}
+
+func (ind *supertrendIndicator) Calculate(klines []Kline) []IndicatorLine {
+	n := len(klines)
+	atr := rmaOf(trueRangeOf(klines), ind.period)
+	value := nanSeries(n)
+	direction := nanSeries(n)
+
+	var finalUpper, finalLower float64
+	started := false
+	for i := 0; i < n; i++ {
+		if math.IsNaN(atr[i]) {
+			continue
+		}
+		hl2 := (klines[i].High + klines[i].Low) / 2
+		upper := hl2 + ind.multiplier*atr[i]
+		lower := hl2 - ind.multiplier*atr[i]
+
+		if !started {
+			finalUpper, finalLower = upper, lower
+			direction[i] = 1
+			if klines[i].Close < hl2 {
+				direction[i] = -1
+			}
+			started = true
+		} else {
+			prevClose := klines[i-1].Close
+			if upper < finalUpper || prevClose > finalUpper {
+				finalUpper = upper
+			}
+			if lower > finalLower || prevClose < finalLower {
+				finalLower = lower
+			}
+
+			direction[i] = direction[i-1]
+			if direction[i-1] > 0 && klines[i].Close < finalLower {
+				direction[i] = -1
+			} else if direction[i-1] < 0 && klines[i].Close > finalUpper {
+				direction[i] = 1
+			}
+		}
+
+		if direction[i] > 0 {
+			value[i] = finalLower
+		} else {
+			value[i] = finalUpper
+		}
+	}
+
+	return []IndicatorLine{
+		{Name: "value", Values: value},
+		{Name: "direction", Values: direction},
+	}
+}
+
+// keltnerIndicator 肯特纳通道（EMA中轨 ± ATR倍数）
+type keltnerIndicator struct {
+	period     int
+	atrPeriod  int
+	multiplier float64
+}
+
+func newKeltnerIndicator(params map[string]float64) (Indicator, error) {
+	period, err := intParam(params, "period", 20)
+	if err != nil {
+		return nil, err
+	}
+	atrPeriod, err := intParam(params, "atr_period", 10)
+	if err != nil {
+		return nil, err
+	}
+	multiplier, err := floatParam(params, "multiplier", 2)
+	if err != nil {
+		return nil, err
+	}
+	return &keltnerIndicator{period: period, atrPeriod: atrPeriod, multiplier: multiplier}, nil
+}
+
+func (ind *keltnerIndicator) Key() string { return fmt.Sprintf("keltner%d", ind.period) }
+
+func (ind *keltnerIndicator) Label() string {
+	return fmt.Sprintf("Keltner Channels (%d, ATR %d, %.1f)", ind.period, ind.atrPeriod, ind.multiplier)
+}
+
+func (ind *keltnerIndicator) Calculate(klines []Kline) []IndicatorLine {
+	middle := emaOf(closesOf(klines), ind.period)
+	atr := rmaOf(trueRangeOf(klines), ind.atrPeriod)
+	upper := nanSeries(len(klines))
+	lower := nanSeries(len(klines))
+
+	for i := range middle {
+		upper[i] = middle[i] + ind.multiplier*atr[i]
+		lower[i] = middle[i] - ind.multiplier*atr[i]
+	}
+
+	return []IndicatorLine{
+		{Name: "upper", Values: upper},
+		{Name: "middle", Values: middle},
+		{Name: "lower", Values: lower},
+	}
+}
+
+// nanSeries 创建全部为NaN的序列
+func nanSeries(n int) []float64 {
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = math.NaN()
+	}
+	return values
+}
+
+// closesOf 提取收盘价
+func closesOf(klines []Kline) []float64 {
+	closes := make([]float64, len(klines))
+	for i, k := range klines {
+		closes[i] = k.Close
+	}
+	return closes
+}
+
+// firstValid 第一个非NaN值的位置
+func firstValid(values []float64) int {
+	for i, v := range values {
+		if !math.IsNaN(v) {
+			return i
+		}
+	}
+	return len(values)
+}
+
+// smaOf 简单移动平均（跳过开头的NaN）
+func smaOf(values []float64, period int) []float64 {
+	result := nanSeries(len(values))
+	for i := firstValid(values) + period - 1; i < len(values); i++ {
+		sum := 0.0
+		for _, v := range values[i-period+1 : i+1] {
+			sum += v
+		}
+		result[i] = sum / float64(period)
+	}
+	return result
+}
+
+// emaOf 指数移动平均（以前period个值的SMA为初始值，与calculateEMA一致）
+func emaOf(values []float64, period int) []float64 {
+	return smoothedOf(values, period, 2.0/float64(period+1))
+}
+
+// rmaOf Wilder平滑（用于RSI/ATR/ADX，与calculateRSI、calculateATR一致）
+func rmaOf(values []float64, period int) []float64 {
+	return smoothedOf(values, period, 1.0/float64(period))
+}
+
+// smoothedOf 以SMA为初始值的递推平滑
+func smoothedOf(values []float64, period int, alpha float64) []float64 {
+	result := nanSeries(len(values))
+	start := firstValid(values)
+	if len(values)-start < period {
+		return result
+	}
+
+	sum := 0.0
+	for i := start; i < start+period; i++ {
+		sum += values[i]
+	}
+	avg := sum / float64(period)
+	result[start+period-1] = avg
+
+	for i := start + period; i < len(values); i++ {
+		avg = (values[i]-avg)*alpha + avg
+		result[i] = avg
+	}
+	return result
+}
+
+// rsiOf RSI序列
+func rsiOf(closes []float64, period int) []float64 {
+	gains := nanSeries(len(closes))
+	losses := nanSeries(len(closes))
+	for i := 1; i < len(closes); i++ {
+		change := closes[i] - closes[i-1]
+		gains[i] = math.Max(change, 0)
+		losses[i] = math.Max(-change, 0)
+	}
+
+	avgGain := rmaOf(gains, period)
+	avgLoss := rmaOf(losses, period)
+	rsi := nanSeries(len(closes))
+	for i := range rsi {
+		if math.IsNaN(avgGain[i]) {
+			continue
+		}
+		if avgLoss[i] == 0 {
+			rsi[i] = 100
+			continue
+		}
+		rsi[i] = 100 - 100/(1+avgGain[i]/avgLoss[i])
+	}
+	return rsi
+}
+
+// trueRangeOf 真实波幅序列（第一根K线无前收盘价，为NaN）
+func trueRangeOf(klines []Kline) []float64 {
+	trs := nanSeries(len(klines))
+	for i := 1; i < len(klines); i++ {
+		high := klines[i].High
+		low := klines[i].Low
+		prevClose := klines[i-1].Close
+		trs[i] = math.Max(high-low, math.Max(math.Abs(high-prevClose), math.Abs(low-prevClose)))
+	}
+	return trs
+}
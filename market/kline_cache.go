@@ -0,0 +1,311 @@
+package market
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	restCacheTTL       = 15 * time.Second // 无推送时REST结果的缓存时长（同一周期内复用）
+	seriesIdleTimeout  = 30 * time.Minute // 超过该时长未访问的序列将被清理并取消订阅
+	seriesJanitorEvery = 5 * time.Minute
+	pushStaleMargin    = 30 * time.Second // 推送超过一个K线周期加该余量仍未到达时，视为推送已中断
+)
+
+// KlineCache 长驻K线缓存
+// 通过websocket订阅活跃币种的K线推送，在内存中维护滚动序列；REST只用于首次回填和断线后补数据。
+// KlineCache 本身实现 Provider 接口，持仓量、资金费率、多空比和主动买卖量按统计周期短期缓存（见 feedCache），其余数据直接透传到底层数据源。
+type KlineCache struct {
+	provider    Provider
+	stream      *klineStream // 为nil表示该数据源不支持推送，仅做短时REST缓存
+	feeds       *feedCache
+	janitorOnce sync.Once
+
+	mu     sync.Mutex
+	series map[streamKey]*klineSeries
+}
+
+// klineSeries 单个symbol+周期的滚动K线序列
+type klineSeries struct {
+	klines     []Kline
+	capacity   int       // 保留的K线数量（取历史请求的最大limit）
+	live       bool      // 是否由推送实时更新（回填后收到第一条推送时置为true，断线或出现缺口时置为false）
+	fetchedAt  time.Time // 最近一次REST回填时间
+	lastPush   time.Time // 最近一次收到推送的时间
+	lastAccess time.Time // 最近一次读取时间
+}
+
+var (
+	sharedCachesMu sync.Mutex
+	sharedCaches   = make(map[string]*KlineCache)
+)
+
+// SharedKlineCache 获取数据源对应的共享K线缓存
+// 同一平台的多个trader共用一个缓存和一条websocket连接
+func SharedKlineCache(provider Provider) *KlineCache {
+	sharedCachesMu.Lock()
+	defer sharedCachesMu.Unlock()
+
+	if cache, ok := sharedCaches[provider.Name()]; ok {
+		return cache
+	}
+	cache := NewKlineCache(provider)
+	sharedCaches[provider.Name()] = cache
+	return cache
+}
+
+// NewKlineCache 创建K线缓存
+func NewKlineCache(provider Provider) *KlineCache {
+	c := &KlineCache{
+		provider: provider,
+		feeds:    newFeedCache(),
+		series:   make(map[streamKey]*klineSeries),
+	}
+
+	if sp, ok := provider.(klineStreamProvider); ok {
+		c.stream = newKlineStream(sp.klineStreamCodec(), c.applyKline, c.markAllStale)
+	}
+
+	return c
+}
+
+// Name 数据源名称
+func (c *KlineCache) Name() string {
+	return c.provider.Name()
+}
+
+// GetKlines 获取K线数据（优先读缓存，缓存不可用时REST回填并订阅推送）
+func (c *KlineCache) GetKlines(symbol, interval string, limit int) ([]Kline, error) {
+	return c.getKlines(c.provider, symbol, interval, limit)
+}
+
+// getKlines 读取缓存，缓存不可用时通过provider回填
+func (c *KlineCache) getKlines(provider Provider, symbol, interval string, limit int) ([]Kline, error) {
+	key := streamKey{Symbol: symbol, Interval: interval}
+	now := time.Now()
+
+	c.mu.Lock()
+	s := c.series[key]
+	capacity := limit
+	if s != nil {
+		s.lastAccess = now
+		// limit不超过回填时的容量即可复用（新上线币种REST返回的K线可能少于limit）
+		if limit <= s.capacity && c.isFreshLocked(key, s, now) {
+			klines := tailKlines(s.klines, limit)
+			c.mu.Unlock()
+			return klines, nil
+		}
+		if s.capacity > capacity {
+			capacity = s.capacity
+		}
+	}
+	c.mu.Unlock()
+
+	// 缓存不可用：REST回填
+	klines, err := provider.GetKlines(symbol, interval, capacity)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	s = c.series[key]
+	if s == nil {
+		s = &klineSeries{}
+		c.series[key] = s
+	}
+	s.klines = klines
+	s.capacity = capacity
+	s.fetchedAt = now
+	s.lastAccess = now
+	s.live = false // 订阅请求可能尚未生效，收到推送后才使用推送数据
+	result := tailKlines(s.klines, limit)
+	c.mu.Unlock()
+
+	if c.stream != nil {
+		c.stream.Subscribe(key)
+		c.janitorOnce.Do(func() { go c.janitor() })
+	}
+
+	return result, nil
+}
+
+// WithContext 返回绑定请求上下文的缓存视图（共享缓存和推送，回填及透传请求使用该上下文）
+func (c *KlineCache) WithContext(ctx context.Context) Provider {
+	return &contextKlineCache{
+		Provider: WithContext(ctx, c.provider),
+		cache:    c,
+	}
+}
+
+// contextKlineCache 绑定上下文的K线缓存视图，读共享缓存，未命中时使用嵌入的（绑定上下文的）数据源请求
+type contextKlineCache struct {
+	Provider
+	cache *KlineCache
+}
+
+// GetKlines 获取K线数据（读共享缓存，回填使用绑定上下文的数据源）
+func (v *contextKlineCache) GetKlines(symbol, interval string, limit int) ([]Kline, error) {
+	return v.cache.getKlines(v.Provider, symbol, interval, limit)
+}
+
+// GetOpenInterest 获取持仓量（读共享缓存）
+func (v *contextKlineCache) GetOpenInterest(symbol string) (*OIData, error) {
+	return v.cache.feeds.openInterest(v.Provider, symbol)
+}
+
+// GetOpenInterestHistory 获取历史持仓量（读共享缓存）
+func (v *contextKlineCache) GetOpenInterestHistory(symbol, period string, limit int) ([]OIPoint, error) {
+	return v.cache.feeds.openInterestHistory(v.Provider, symbol, period, limit)
+}
+
+// GetFunding 获取资金费率（读共享缓存）
+func (v *contextKlineCache) GetFunding(symbol string) (*FundingData, error) {
+	return v.cache.feeds.funding(v.Provider, symbol)
+}
+
+// GetFundingHistory 获取资金费率历史（读共享缓存）
+func (v *contextKlineCache) GetFundingHistory(symbol string, limit int) ([]FundingPoint, error) {
+	return v.cache.feeds.fundingHistory(v.Provider, symbol, limit)
+}
+
+// GetLongShortRatio 获取多空账户比序列（读共享缓存）
+func (v *contextKlineCache) GetLongShortRatio(symbol, scope, period string, limit int) ([]LongShortPoint, error) {
+	return v.cache.feeds.longShortRatio(v.Provider, symbol, scope, period, limit)
+}
+
+// GetTakerVolume 获取主动买卖量序列（读共享缓存）
+func (v *contextKlineCache) GetTakerVolume(symbol, period string, limit int) ([]TakerVolumePoint, error) {
+	return v.cache.feeds.takerVolume(v.Provider, symbol, period, limit)
+}
+
+// GetOpenInterest 获取持仓量（短期缓存）
+func (c *KlineCache) GetOpenInterest(symbol string) (*OIData, error) {
+	return c.feeds.openInterest(c.provider, symbol)
+}
+
+// GetOpenInterestHistory 获取历史持仓量（短期缓存）
+func (c *KlineCache) GetOpenInterestHistory(symbol, period string, limit int) ([]OIPoint, error) {
+	return c.feeds.openInterestHistory(c.provider, symbol, period, limit)
+}
+
+// GetFunding 获取资金费率（短期缓存）
+func (c *KlineCache) GetFunding(symbol string) (*FundingData, error) {
+	return c.feeds.funding(c.provider, symbol)
+}
+
+// GetFundingHistory 获取资金费率历史（短期缓存）
+func (c *KlineCache) GetFundingHistory(symbol string, limit int) ([]FundingPoint, error) {
+	return c.feeds.fundingHistory(c.provider, symbol, limit)
+}
+
+// GetOrderBook 获取订单簿快照（透传）
+func (c *KlineCache) GetOrderBook(symbol string, limit int) (*OrderBook, error) {
+	return c.provider.GetOrderBook(symbol, limit)
+}
+
+// GetLongShortRatio 获取多空账户比序列（短期缓存）
+func (c *KlineCache) GetLongShortRatio(symbol, scope, period string, limit int) ([]LongShortPoint, error) {
+	return c.feeds.longShortRatio(c.provider, symbol, scope, period, limit)
+}
+
+// GetTakerVolume 获取主动买卖量序列（短期缓存）
+func (c *KlineCache) GetTakerVolume(symbol, period string, limit int) ([]TakerVolumePoint, error) {
+	return c.feeds.takerVolume(c.provider, symbol, period, limit)
+}
+
+// GetLiquidations 获取强平统计（透传）
+func (c *KlineCache) GetLiquidations(symbol string) (*LiquidationStats, error) {
+	return c.provider.GetLiquidations(symbol)
+}
+
+// isFreshLocked 判断缓存序列是否可直接使用（调用方需持有锁）
+// 推送序列在一个K线周期加余量内没有收到推送时视为中断（订阅失败或推送静默停止），改用REST回填
+func (c *KlineCache) isFreshLocked(key streamKey, s *klineSeries, now time.Time) bool {
+	if s.live {
+		d, err := IntervalDuration(key.Interval)
+		if err == nil && now.Sub(s.lastPush) <= d+pushStaleMargin {
+			return true
+		}
+		s.live = false
+	}
+	return now.Sub(s.fetchedAt) < restCacheTTL
+}
+
+// applyKline 将推送的K线合并进滚动序列
+func (c *KlineCache) applyKline(key streamKey, k Kline) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := c.series[key]
+	if s == nil || len(s.klines) == 0 {
+		return
+	}
+
+	last := &s.klines[len(s.klines)-1]
+	switch {
+	case k.OpenTime == last.OpenTime:
+		// 当前K线更新
+		*last = k
+	case k.OpenTime > last.OpenTime:
+		// 新K线：检查是否与上一根连续，出现缺口则等待下次读取时REST回填
+		if d, err := IntervalDuration(key.Interval); err == nil && k.OpenTime-last.OpenTime > d.Milliseconds() {
+			s.live = false
+			return
+		}
+		s.klines = append(s.klines, k)
+		if len(s.klines) > s.capacity {
+			s.klines = append([]Kline(nil), s.klines[len(s.klines)-s.capacity:]...)
+		}
+	default:
+		return // 早于当前K线的推送（重复或乱序）
+	}
+	s.live = true
+	s.lastPush = time.Now()
+}
+
+// markAllStale 推送断开时将所有序列标记为非实时
+func (c *KlineCache) markAllStale() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, s := range c.series {
+		s.live = false
+	}
+}
+
+// janitor 定期清理长时间未访问的序列并取消订阅
+func (c *KlineCache) janitor() {
+	ticker := time.NewTicker(seriesJanitorEvery)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		now := time.Now()
+		var idle []streamKey
+
+		c.mu.Lock()
+		for key, s := range c.series {
+			if now.Sub(s.lastAccess) > seriesIdleTimeout {
+				idle = append(idle, key)
+				delete(c.series, key)
+			}
+		}
+		c.mu.Unlock()
+
+		if len(idle) > 0 {
+			c.stream.Unsubscribe(idle)
+			log.Printf("🧹 [%s] 清理%d个不活跃的K线序列", c.provider.Name(), len(idle))
+		}
+	}
+}
+
+// tailKlines 复制序列最后n根K线
+func tailKlines(klines []Kline, n int) []Kline {
+	if n > len(klines) {
+		n = len(klines)
+	}
+	result := make([]Kline, n)
+	copy(result, klines[len(klines)-n:])
+	return result
+}
@@ -0,0 +1,74 @@
+package market
+
+import (
+	"testing"
+	"time"
+)
+
+func TestKlineCacheLiveRequiresRecentPush(t *testing.T) {
+	const interval, limit = "3m", 50
+	d := 3 * time.Minute
+	provider := &fakeKlineProvider{name: "fake"}
+	cache := NewKlineCache(provider)
+	key := streamKey{Symbol: "BTCUSDT", Interval: interval}
+
+	get := func() {
+		t.Helper()
+		if _, err := cache.GetKlines("BTCUSDT", interval, limit); err != nil {
+			t.Fatalf("GetKlines: %v", err)
+		}
+	}
+	series := func() *klineSeries {
+		cache.mu.Lock()
+		defer cache.mu.Unlock()
+		return cache.series[key]
+	}
+
+	// 1. 回填后在收到推送前不视为实时，只在REST缓存时长内复用
+	get()
+	if s := series(); s.live {
+		t.Fatalf("回填后未收到推送就标记为实时")
+	}
+	get()
+	if len(provider.limits) != 1 {
+		t.Fatalf("REST缓存时长内重复请求: %v", provider.limits)
+	}
+	series().fetchedAt = time.Now().Add(-time.Minute)
+	get()
+	if len(provider.limits) != 2 {
+		t.Fatalf("REST缓存过期后未重新回填: %v", provider.limits)
+	}
+
+	// 2. 收到推送后由推送维护，不再回填
+	s := series()
+	last := s.klines[len(s.klines)-1]
+	last.Close++
+	cache.applyKline(key, last)
+	if !series().live {
+		t.Fatalf("收到推送后未标记为实时")
+	}
+	series().fetchedAt = time.Now().Add(-time.Hour)
+	get()
+	if len(provider.limits) != 2 {
+		t.Fatalf("推送正常时仍然回填: %v", provider.limits)
+	}
+
+	// 3. 超过一个周期加余量没有推送，视为中断并回填
+	series().lastPush = time.Now().Add(-(d + pushStaleMargin + time.Second))
+	get()
+	if len(provider.limits) != 3 {
+		t.Fatalf("推送静默后未回填: %v", provider.limits)
+	}
+	if series().live {
+		t.Fatalf("推送静默后仍标记为实时")
+	}
+
+	// 4. 推送出现缺口时不再视为实时
+	s = series()
+	gap := s.klines[len(s.klines)-1]
+	gap.OpenTime += 2 * d.Milliseconds()
+	cache.applyKline(key, gap)
+	if series().live {
+		t.Fatalf("推送出现缺口后仍标记为实时")
+	}
+}
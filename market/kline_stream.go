@@ -0,0 +1,350 @@
+package market
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	streamReconnectDelay = 5 * time.Second        // 断线重连间隔
+	streamFlushInterval  = 500 * time.Millisecond // 合并订阅请求的间隔（避免触发交易所消息频率限制）
+	streamReadTimeout    = 5 * time.Minute        // 读超时（超时视为断线）
+	streamHeartbeatEvery = 50 * time.Second       // 心跳间隔（仅对需要心跳的平台）
+)
+
+// streamKey K线订阅键（symbol + 周期）
+type streamKey struct {
+	Symbol   string
+	Interval string
+}
+
+// klineStreamCodec 各平台K线推送协议的编解码
+type klineStreamCodec interface {
+	// url websocket地址
+	url() string
+	// subscribeMessages 生成订阅消息
+	subscribeMessages(keys []streamKey) []interface{}
+	// unsubscribeMessages 生成取消订阅消息
+	unsubscribeMessages(keys []streamKey) []interface{}
+	// heartbeat 心跳消息（nil表示不需要主动心跳）
+	heartbeat() interface{}
+	// parse 解析推送消息，非K线消息返回false
+	parse(message []byte) (streamKey, Kline, bool)
+}
+
+// klineStreamProvider 支持K线websocket推送的数据源
+type klineStreamProvider interface {
+	klineStreamCodec() klineStreamCodec
+}
+
+// klineStream 单个websocket连接，维护订阅集合并自动重连
+type klineStream struct {
+	codec        klineStreamCodec
+	onKline      func(key streamKey, k Kline)
+	onDisconnect func()
+
+	mu        sync.Mutex
+	conn      *websocket.Conn
+	connected bool
+	started   bool
+	subs      map[streamKey]bool
+	pending   []streamKey
+}
+
+// newKlineStream 创建K线推送流（首次订阅时才建立连接）
+func newKlineStream(codec klineStreamCodec, onKline func(key streamKey, k Kline), onDisconnect func()) *klineStream {
+	return &klineStream{
+		codec:        codec,
+		onKline:      onKline,
+		onDisconnect: onDisconnect,
+		subs:         make(map[streamKey]bool),
+	}
+}
+
+// Subscribe 订阅K线推送
+func (s *klineStream) Subscribe(key streamKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.subs[key] {
+		return
+	}
+	s.subs[key] = true
+	s.pending = append(s.pending, key)
+
+	if !s.started {
+		s.started = true
+		go s.run()
+		go s.flushLoop()
+	}
+}
+
+// Unsubscribe 取消订阅
+func (s *klineStream) Unsubscribe(keys []streamKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var removed []streamKey
+	for _, key := range keys {
+		if s.subs[key] {
+			delete(s.subs, key)
+			removed = append(removed, key)
+		}
+	}
+	if len(removed) > 0 && s.connected {
+		s.writeLocked(s.codec.unsubscribeMessages(removed))
+	}
+}
+
+// Connected 连接是否正常
+func (s *klineStream) Connected() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connected
+}
+
+// run 连接主循环（断线自动重连）
+func (s *klineStream) run() {
+	for {
+		conn, _, err := websocket.DefaultDialer.Dial(s.codec.url(), nil)
+		if err != nil {
+			log.Printf("⚠️  K线推送连接失败 (%s): %v，%v后重试", s.codec.url(), err, streamReconnectDelay)
+			time.Sleep(streamReconnectDelay)
+			continue
+		}
+
+		// 连接成功后重新订阅全部
+		s.mu.Lock()
+		s.conn = conn
+		s.connected = true
+		keys := make([]streamKey, 0, len(s.subs))
+		for key := range s.subs {
+			keys = append(keys, key)
+		}
+		s.pending = nil
+		if len(keys) > 0 {
+			s.writeLocked(s.codec.subscribeMessages(keys))
+		}
+		s.mu.Unlock()
+
+		log.Printf("✓ K线推送已连接 (%s)，订阅%d个流", s.codec.url(), len(keys))
+
+		err = s.readLoop(conn)
+
+		s.mu.Lock()
+		s.conn = nil
+		s.connected = false
+		s.mu.Unlock()
+		conn.Close()
+
+		// 断线期间可能丢失K线，通知缓存失效
+		s.onDisconnect()
+
+		log.Printf("⚠️  K线推送断开 (%s): %v，%v后重连", s.codec.url(), err, streamReconnectDelay)
+		time.Sleep(streamReconnectDelay)
+	}
+}
+
+// readLoop 读取推送消息直到出错
+func (s *klineStream) readLoop(conn *websocket.Conn) error {
+	conn.SetReadDeadline(time.Now().Add(streamReadTimeout))
+	conn.SetPingHandler(func(appData string) error {
+		conn.SetReadDeadline(time.Now().Add(streamReadTimeout))
+		return conn.WriteControl(websocket.PongMessage, []byte(appData), time.Now().Add(10*time.Second))
+	})
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		conn.SetReadDeadline(time.Now().Add(streamReadTimeout))
+
+		if key, kline, ok := s.codec.parse(message); ok {
+			s.onKline(key, kline)
+		}
+	}
+}
+
+// flushLoop 定期合并发送新增订阅和心跳
+func (s *klineStream) flushLoop() {
+	ticker := time.NewTicker(streamFlushInterval)
+	defer ticker.Stop()
+
+	lastHeartbeat := time.Now()
+	for range ticker.C {
+		s.mu.Lock()
+		if s.connected {
+			if len(s.pending) > 0 {
+				s.writeLocked(s.codec.subscribeMessages(s.pending))
+				s.pending = nil
+			}
+			if hb := s.codec.heartbeat(); hb != nil && time.Since(lastHeartbeat) >= streamHeartbeatEvery {
+				s.writeLocked([]interface{}{hb})
+				lastHeartbeat = time.Now()
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// writeLocked 发送消息（调用方需持有锁）
+func (s *klineStream) writeLocked(messages []interface{}) {
+	if s.conn == nil {
+		return
+	}
+	for _, msg := range messages {
+		if err := s.conn.WriteJSON(msg); err != nil {
+			log.Printf("⚠️  K线推送发送消息失败: %v", err)
+			return
+		}
+	}
+}
+
+// binanceStreamCodec 币安合约K线推送协议（Aster与其兼容）
+type binanceStreamCodec struct {
+	wsURL  string
+	nextID int64
+}
+
+func (c *binanceStreamCodec) url() string {
+	return c.wsURL
+}
+
+func (c *binanceStreamCodec) streamNames(keys []streamKey) []string {
+	names := make([]string, len(keys))
+	for i, key := range keys {
+		names[i] = fmt.Sprintf("%s@kline_%s", strings.ToLower(key.Symbol), key.Interval)
+	}
+	return names
+}
+
+func (c *binanceStreamCodec) subscribeMessages(keys []streamKey) []interface{} {
+	return []interface{}{map[string]interface{}{
+		"method": "SUBSCRIBE",
+		"params": c.streamNames(keys),
+		"id":     atomic.AddInt64(&c.nextID, 1),
+	}}
+}
+
+func (c *binanceStreamCodec) unsubscribeMessages(keys []streamKey) []interface{} {
+	return []interface{}{map[string]interface{}{
+		"method": "UNSUBSCRIBE",
+		"params": c.streamNames(keys),
+		"id":     atomic.AddInt64(&c.nextID, 1),
+	}}
+}
+
+func (c *binanceStreamCodec) heartbeat() interface{} {
+	return nil // 币安由服务端发ping，客户端回pong即可
+}
+
+func (c *binanceStreamCodec) parse(message []byte) (streamKey, Kline, bool) {
+	var msg struct {
+		Data struct {
+			Event string `json:"e"`
+			K     struct {
+				OpenTime  int64       `json:"t"`
+				CloseTime int64       `json:"T"`
+				Symbol    string      `json:"s"`
+				Interval  string      `json:"i"`
+				Open      interface{} `json:"o"`
+				Close     interface{} `json:"c"`
+				High      interface{} `json:"h"`
+				Low       interface{} `json:"l"`
+				Volume    interface{} `json:"v"`
+			} `json:"k"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(message, &msg); err != nil || msg.Data.Event != "kline" {
+		return streamKey{}, Kline{}, false
+	}
+
+	k := msg.Data.K
+	return streamKey{Symbol: k.Symbol, Interval: k.Interval}, newKlineFromRaw(k.OpenTime, k.CloseTime, k.Open, k.High, k.Low, k.Close, k.Volume), true
+}
+
+// hyperliquidStreamCodec Hyperliquid K线推送协议
+type hyperliquidStreamCodec struct {
+	wsURL string
+	coin  func(symbol string) string // symbol -> Hyperliquid币种名（见 HyperliquidProvider.coin）
+}
+
+func (c *hyperliquidStreamCodec) url() string {
+	return c.wsURL
+}
+
+func (c *hyperliquidStreamCodec) messages(method string, keys []streamKey) []interface{} {
+	msgs := make([]interface{}, len(keys))
+	for i, key := range keys {
+		msgs[i] = map[string]interface{}{
+			"method": method,
+			"subscription": map[string]interface{}{
+				"type":     "candle",
+				"coin":     c.coin(key.Symbol),
+				"interval": key.Interval,
+			},
+		}
+	}
+	return msgs
+}
+
+func (c *hyperliquidStreamCodec) subscribeMessages(keys []streamKey) []interface{} {
+	return c.messages("subscribe", keys)
+}
+
+func (c *hyperliquidStreamCodec) unsubscribeMessages(keys []streamKey) []interface{} {
+	return c.messages("unsubscribe", keys)
+}
+
+func (c *hyperliquidStreamCodec) heartbeat() interface{} {
+	return map[string]interface{}{"method": "ping"} // 60秒无消息服务端会断开
+}
+
+func (c *hyperliquidStreamCodec) parse(message []byte) (streamKey, Kline, bool) {
+	var msg struct {
+		Channel string `json:"channel"`
+		Data    struct {
+			OpenTime  int64       `json:"t"`
+			CloseTime int64       `json:"T"`
+			Coin      string      `json:"s"`
+			Interval  string      `json:"i"`
+			Open      interface{} `json:"o"`
+			Close     interface{} `json:"c"`
+			High      interface{} `json:"h"`
+			Low       interface{} `json:"l"`
+			Volume    interface{} `json:"v"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(message, &msg); err != nil || msg.Channel != "candle" {
+		return streamKey{}, Kline{}, false
+	}
+
+	d := msg.Data
+	return streamKey{Symbol: Normalize(d.Coin), Interval: d.Interval}, newKlineFromRaw(d.OpenTime, d.CloseTime, d.Open, d.High, d.Low, d.Close, d.Volume), true
+}
+
+// newKlineFromRaw 从推送的原始字段构建K线（价格可能是字符串或数字）
+func newKlineFromRaw(openTime, closeTime int64, open, high, low, close, volume interface{}) Kline {
+	o, _ := parseFloat(open)
+	h, _ := parseFloat(high)
+	l, _ := parseFloat(low)
+	c, _ := parseFloat(close)
+	v, _ := parseFloat(volume)
+	return Kline{
+		OpenTime:  openTime,
+		Open:      o,
+		High:      h,
+		Low:       l,
+		Close:     c,
+		Volume:    v,
+		CloseTime: closeTime,
+	}
+}
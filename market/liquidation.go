@@ -0,0 +1,220 @@
+package market
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const liquidationRetention = time.Hour // 强平事件保留时长（最长统计窗口）
+
+// LiquidationStats 单个币种的强平统计
+// 多头强平 = 交易所强制卖出多头仓位；空头强平 = 强制买入平空
+type LiquidationStats struct {
+	LongNotional5m  float64       // 近5分钟多头强平金额（USD）
+	ShortNotional5m float64       // 近5分钟空头强平金额（USD）
+	LongNotional1h  float64       // 近1小时多头强平金额（USD）
+	ShortNotional1h float64       // 近1小时空头强平金额（USD）
+	Count5m         int           // 近5分钟强平笔数
+	Count1h         int           // 近1小时强平笔数
+	Coverage        time.Duration // 推送已连续收集的时长（不足1小时时1h统计不完整）
+}
+
+// liquidationEvent 单笔强平事件
+type liquidationEvent struct {
+	time     time.Time
+	long     bool // true表示多头被强平
+	notional float64
+}
+
+// liquidationFeed 全市场强平推送聚合（币安 !forceOrder@arr，Aster兼容）
+// 注意：币安每个symbol每秒最多推送一笔强平，统计值为下限
+type liquidationFeed struct {
+	url string
+
+	mu         sync.Mutex
+	started    bool
+	connected  bool
+	since      time.Time // 本次连续收集的开始时间（断线后重置）
+	events     map[string][]liquidationEvent
+	lastPruned time.Time
+}
+
+var (
+	liquidationFeedsMu sync.Mutex
+	liquidationFeeds   = make(map[string]*liquidationFeed)
+)
+
+// sharedLiquidationFeed 获取指定地址的共享强平推送（首次使用时建立连接）
+func sharedLiquidationFeed(url string) *liquidationFeed {
+	liquidationFeedsMu.Lock()
+	defer liquidationFeedsMu.Unlock()
+
+	if feed, ok := liquidationFeeds[url]; ok {
+		return feed
+	}
+	feed := &liquidationFeed{
+		url:    url,
+		events: make(map[string][]liquidationEvent),
+	}
+	liquidationFeeds[url] = feed
+	return feed
+}
+
+// Stats 获取币种的强平统计
+func (f *liquidationFeed) Stats(symbol string) (*LiquidationStats, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.started {
+		f.started = true
+		go f.run()
+	}
+	if !f.connected {
+		return nil, fmt.Errorf("强平推送未连接")
+	}
+
+	now := time.Now()
+	stats := &LiquidationStats{Coverage: now.Sub(f.since)}
+	for _, e := range f.events[symbol] {
+		age := now.Sub(e.time)
+		if age > liquidationRetention {
+			continue
+		}
+		stats.Count1h++
+		if e.long {
+			stats.LongNotional1h += e.notional
+		} else {
+			stats.ShortNotional1h += e.notional
+		}
+		if age <= 5*time.Minute {
+			stats.Count5m++
+			if e.long {
+				stats.LongNotional5m += e.notional
+			} else {
+				stats.ShortNotional5m += e.notional
+			}
+		}
+	}
+
+	return stats, nil
+}
+
+// run 连接主循环（断线自动重连）
+func (f *liquidationFeed) run() {
+	for {
+		conn, _, err := websocket.DefaultDialer.Dial(f.url, nil)
+		if err != nil {
+			log.Printf("⚠️  强平推送连接失败 (%s): %v，%v后重试", f.url, err, streamReconnectDelay)
+			time.Sleep(streamReconnectDelay)
+			continue
+		}
+
+		// 断线期间的强平已丢失，重新开始收集
+		f.mu.Lock()
+		f.connected = true
+		f.since = time.Now()
+		f.events = make(map[string][]liquidationEvent)
+		f.mu.Unlock()
+
+		log.Printf("✓ 强平推送已连接 (%s)", f.url)
+
+		err = f.readLoop(conn)
+
+		f.mu.Lock()
+		f.connected = false
+		f.mu.Unlock()
+		conn.Close()
+
+		log.Printf("⚠️  强平推送断开 (%s): %v，%v后重连", f.url, err, streamReconnectDelay)
+		time.Sleep(streamReconnectDelay)
+	}
+}
+
+// readLoop 读取推送消息直到出错
+func (f *liquidationFeed) readLoop(conn *websocket.Conn) error {
+	conn.SetReadDeadline(time.Now().Add(streamReadTimeout))
+	conn.SetPingHandler(func(appData string) error {
+		conn.SetReadDeadline(time.Now().Add(streamReadTimeout))
+		return conn.WriteControl(websocket.PongMessage, []byte(appData), time.Now().Add(10*time.Second))
+	})
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		conn.SetReadDeadline(time.Now().Add(streamReadTimeout))
+
+		if symbol, event, ok := parseBinanceForceOrder(message); ok {
+			f.add(symbol, event)
+		}
+	}
+}
+
+// add 记录强平事件并定期清理过期数据
+func (f *liquidationFeed) add(symbol string, event liquidationEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.events[symbol] = append(f.events[symbol], event)
+
+	if time.Since(f.lastPruned) < time.Minute {
+		return
+	}
+	cutoff := time.Now().Add(-liquidationRetention)
+	for s, events := range f.events {
+		i := 0
+		for i < len(events) && events[i].time.Before(cutoff) {
+			i++
+		}
+		if i == len(events) {
+			delete(f.events, s)
+		} else if i > 0 {
+			f.events[s] = append([]liquidationEvent(nil), events[i:]...)
+		}
+	}
+	f.lastPruned = time.Now()
+}
+
+// parseBinanceForceOrder 解析币安强平推送
+func parseBinanceForceOrder(message []byte) (string, liquidationEvent, bool) {
+	var msg struct {
+		Event string `json:"e"`
+		Order struct {
+			Symbol      string `json:"s"`
+			Side        string `json:"S"`
+			AvgPrice    string `json:"ap"`
+			FilledQty   string `json:"z"`
+			TradeTimeMs int64  `json:"T"`
+		} `json:"o"`
+	}
+	if err := json.Unmarshal(message, &msg); err != nil || msg.Event != "forceOrder" {
+		return "", liquidationEvent{}, false
+	}
+
+	price, _ := parseFloat(msg.Order.AvgPrice)
+	qty, _ := parseFloat(msg.Order.FilledQty)
+	return msg.Order.Symbol, liquidationEvent{
+		time:     time.UnixMilli(msg.Order.TradeTimeMs),
+		long:     msg.Order.Side == "SELL", // 卖单强平的是多头仓位
+		notional: price * qty,
+	}, true
+}
+
+// formatLiquidations 格式化强平统计
+func formatLiquidations(l *LiquidationStats) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Liquidations: 5m long $%.0f / short $%.0f (%d orders) | 1h long $%.0f / short $%.0f (%d orders)",
+		l.LongNotional5m, l.ShortNotional5m, l.Count5m, l.LongNotional1h, l.ShortNotional1h, l.Count1h))
+	if l.Coverage < liquidationRetention {
+		sb.WriteString(fmt.Sprintf(" (feed collected for %dm only)", int(l.Coverage.Minutes())))
+	}
+	sb.WriteString("\n\n")
+	return sb.String()
+}
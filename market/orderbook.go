@@ -0,0 +1,143 @@
+package market
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+const orderBookDepthLimit = 500 // 请求的盘口档位数（各平台会按自身上限截断；只统计±1%以内，币安1000档权重为20，500档为10）
+
+// OrderBook 订单簿快照
+type OrderBook struct {
+	Bids []BookLevel // 买盘（价格从高到低）
+	Asks []BookLevel // 卖盘（价格从低到高）
+	Time int64       // 快照时间（毫秒）
+}
+
+// BookLevel 单个价位
+type BookLevel struct {
+	Price    float64
+	Quantity float64
+}
+
+// DepthData 盘口深度摘要
+type DepthData struct {
+	BestBid   float64
+	BestAsk   float64
+	MidPrice  float64
+	SpreadBps float64 // 买卖价差（基点）
+
+	BidDepth05 float64 // 中间价下方0.5%以内的买盘挂单金额（USD）
+	AskDepth05 float64 // 中间价上方0.5%以内的卖盘挂单金额（USD）
+	BidDepth1  float64 // 中间价下方1%以内的买盘挂单金额（USD）
+	AskDepth1  float64 // 中间价上方1%以内的卖盘挂单金额（USD）
+
+	Imbalance float64 // 买一卖一挂单量失衡度：(买一量-卖一量)/(买一量+卖一量)，范围-1~1
+
+	Truncated bool       // 返回的档位未覆盖±1%区间（深度为下限值）
+	Book      *OrderBook // 原始订单簿（用于下单前冲击评估）
+}
+
+// GetDepth 只获取订单簿并汇总盘口深度（下单前的冲击评估使用，不重新获取K线等其他数据）
+func GetDepth(ctx context.Context, symbol string, opts *Options) (*DepthData, error) {
+	provider := defaultProvider
+	if opts != nil && opts.Provider != nil {
+		provider = opts.Provider
+	}
+
+	symbol = Normalize(symbol)
+	book, err := WithContext(ctx, provider).GetOrderBook(symbol, orderBookDepthLimit)
+	if err != nil {
+		return nil, err
+	}
+	depth := summarizeDepth(book)
+	if depth == nil {
+		return nil, fmt.Errorf("%s 订单簿为空", symbol)
+	}
+	return depth, nil
+}
+
+// summarizeDepth 汇总订单簿深度
+func summarizeDepth(book *OrderBook) *DepthData {
+	if book == nil || len(book.Bids) == 0 || len(book.Asks) == 0 {
+		return nil
+	}
+
+	bestBid := book.Bids[0]
+	bestAsk := book.Asks[0]
+	mid := (bestBid.Price + bestAsk.Price) / 2
+	if mid <= 0 {
+		return nil
+	}
+
+	d := &DepthData{
+		BestBid:  bestBid.Price,
+		BestAsk:  bestAsk.Price,
+		MidPrice: mid,
+		Book:     book,
+	}
+	d.SpreadBps = (bestAsk.Price - bestBid.Price) / mid * 10000
+	if total := bestBid.Quantity + bestAsk.Quantity; total > 0 {
+		d.Imbalance = (bestBid.Quantity - bestAsk.Quantity) / total
+	}
+
+	d.BidDepth05 = depthWithin(book.Bids, mid*(1-0.005), false)
+	d.BidDepth1 = depthWithin(book.Bids, mid*(1-0.01), false)
+	d.AskDepth05 = depthWithin(book.Asks, mid*(1+0.005), true)
+	d.AskDepth1 = depthWithin(book.Asks, mid*(1+0.01), true)
+
+	lastBid := book.Bids[len(book.Bids)-1].Price
+	lastAsk := book.Asks[len(book.Asks)-1].Price
+	d.Truncated = lastBid > mid*(1-0.01) || lastAsk < mid*(1+0.01)
+
+	return d
+}
+
+// depthWithin 计算到达边界价格前的挂单金额（USD）
+func depthWithin(levels []BookLevel, bound float64, ascending bool) float64 {
+	total := 0.0
+	for _, level := range levels {
+		if (ascending && level.Price > bound) || (!ascending && level.Price < bound) {
+			break
+		}
+		total += level.Price * level.Quantity
+	}
+	return total
+}
+
+// EstimateImpact 估算市价成交sizeUSD的冲击成本
+// 返回成交均价相对中间价的偏离百分比；filled为false表示订单簿档位不足以完全成交（此时impactPct为下限）
+func (d *DepthData) EstimateImpact(sizeUSD float64, buy bool) (impactPct float64, filled bool, err error) {
+	if d == nil || d.Book == nil {
+		return 0, false, fmt.Errorf("无订单簿数据")
+	}
+	if sizeUSD <= 0 {
+		return 0, true, nil
+	}
+
+	levels := d.Book.Bids
+	if buy {
+		levels = d.Book.Asks
+	}
+
+	remaining := sizeUSD
+	cost, quantity := 0.0, 0.0
+	for _, level := range levels {
+		notional := level.Price * level.Quantity
+		take := math.Min(notional, remaining)
+		cost += take
+		quantity += take / level.Price
+		remaining -= take
+		if remaining <= 0 {
+			break
+		}
+	}
+	if quantity == 0 {
+		return 0, false, fmt.Errorf("订单簿为空")
+	}
+
+	avgPrice := cost / quantity
+	impactPct = math.Abs(avgPrice-d.MidPrice) / d.MidPrice * 100
+	return impactPct, remaining <= 0, nil
+}
@@ -0,0 +1,92 @@
+package market
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	positioningPeriod = "1h" // 多空比和主动买卖量的统计周期
+	positioningLimit  = 10   // 序列长度
+)
+
+// 多空账户比统计范围
+const (
+	LongShortTopTraders = "top"    // 大户账户
+	LongShortGlobal     = "global" // 全部账户
+)
+
+// LongShortPoint 多空账户比数据点
+type LongShortPoint struct {
+	Time         int64   // 统计时间（毫秒）
+	Ratio        float64 // 多空比 = 多头账户占比 / 空头账户占比
+	LongAccount  float64 // 多头账户占比（0-1）
+	ShortAccount float64 // 空头账户占比（0-1）
+}
+
+// TakerVolumePoint 主动买卖量数据点
+type TakerVolumePoint struct {
+	Time         int64   // 统计时间（毫秒）
+	BuyVolume    float64 // 主动买入量（币数）
+	SellVolume   float64 // 主动卖出量（币数）
+	BuySellRatio float64 // 主动买卖比
+}
+
+// PositioningData 市场参与者持仓与主动成交数据（数据源不支持的部分为空）
+type PositioningData struct {
+	Period         string
+	TopTraderRatio []LongShortPoint
+	GlobalRatio    []LongShortPoint
+	TakerVolume    []TakerVolumePoint
+}
+
+// getPositioning 获取多空比和主动买卖量（各项独立，失败的项留空；全部失败返回nil）
+func getPositioning(provider Provider, symbol string) *PositioningData {
+	p := &PositioningData{Period: positioningPeriod}
+	p.TopTraderRatio, _ = provider.GetLongShortRatio(symbol, LongShortTopTraders, positioningPeriod, positioningLimit)
+	p.GlobalRatio, _ = provider.GetLongShortRatio(symbol, LongShortGlobal, positioningPeriod, positioningLimit)
+	p.TakerVolume, _ = provider.GetTakerVolume(symbol, positioningPeriod, positioningLimit)
+
+	if len(p.TopTraderRatio) == 0 && len(p.GlobalRatio) == 0 && len(p.TakerVolume) == 0 {
+		return nil
+	}
+	return p
+}
+
+// formatPositioning 格式化多空比和主动买卖量
+func formatPositioning(p *PositioningData) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("Crowd positioning (%s intervals, oldest → latest):\n\n", p.Period))
+
+	if len(p.TopTraderRatio) > 0 {
+		sb.WriteString(fmt.Sprintf("Top trader long/short account ratio: %s\n\n", formatFloatSlice(longShortRatios(p.TopTraderRatio))))
+	}
+	if len(p.GlobalRatio) > 0 {
+		sb.WriteString(fmt.Sprintf("Global long/short account ratio: %s\n\n", formatFloatSlice(longShortRatios(p.GlobalRatio))))
+	}
+	if len(p.TakerVolume) > 0 {
+		buys := make([]float64, len(p.TakerVolume))
+		sells := make([]float64, len(p.TakerVolume))
+		ratios := make([]float64, len(p.TakerVolume))
+		for i, v := range p.TakerVolume {
+			buys[i] = v.BuyVolume
+			sells[i] = v.SellVolume
+			ratios[i] = v.BuySellRatio
+		}
+		sb.WriteString(fmt.Sprintf("Taker buy volume: %s\n\n", formatFloatSlice(buys)))
+		sb.WriteString(fmt.Sprintf("Taker sell volume: %s\n\n", formatFloatSlice(sells)))
+		sb.WriteString(fmt.Sprintf("Taker buy/sell ratio: %s\n\n", formatFloatSlice(ratios)))
+	}
+
+	return sb.String()
+}
+
+// longShortRatios 提取多空比序列
+func longShortRatios(points []LongShortPoint) []float64 {
+	ratios := make([]float64, len(points))
+	for i, p := range points {
+		ratios[i] = p.Ratio
+	}
+	return ratios
+}
@@ -0,0 +1,228 @@
+package market
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ErrNotSupported 数据源不提供该数据（配置了回退时会改用备用数据源）
+var ErrNotSupported = errors.New("数据源不支持该数据")
+
+// Provider 行情数据源接口
+// 每个交易平台提供自己的K线、持仓量和资金费率，保证AI看到的数据与实际成交的平台一致
+type Provider interface {
+	// Name 数据源名称（binance / hyperliquid / aster）
+	Name() string
+
+	// GetKlines 获取K线数据（按时间正序：从旧到新）
+	GetKlines(symbol, interval string, limit int) ([]Kline, error)
+
+	// GetOpenInterest 获取当前持仓量
+	GetOpenInterest(symbol string) (*OIData, error)
+
+	// GetOpenInterestHistory 获取历史持仓量（按时间正序），period为统计周期（如 "15m"、"4h"）
+	GetOpenInterestHistory(symbol, period string, limit int) ([]OIPoint, error)
+
+	// GetFunding 获取当前资金费率、下次结算时间以及标记/指数价格
+	GetFunding(symbol string) (*FundingData, error)
+
+	// GetFundingHistory 获取最近limit期已结算的资金费率（按时间正序）
+	GetFundingHistory(symbol string, limit int) ([]FundingPoint, error)
+
+	// GetOrderBook 获取订单簿快照（limit为每侧最多档位数）
+	GetOrderBook(symbol string, limit int) (*OrderBook, error)
+
+	// GetLongShortRatio 获取多空账户比序列（scope: LongShortTopTraders / LongShortGlobal）
+	GetLongShortRatio(symbol, scope, period string, limit int) ([]LongShortPoint, error)
+
+	// GetTakerVolume 获取主动买卖量序列
+	GetTakerVolume(symbol, period string, limit int) ([]TakerVolumePoint, error)
+
+	// GetLiquidations 获取近5分钟/1小时的强平统计
+	GetLiquidations(symbol string) (*LiquidationStats, error)
+}
+
+// defaultProvider 默认数据源（币安合约，带K线推送缓存）
+var defaultProvider Provider = SharedKlineCache(NewBinanceProvider())
+
+// DefaultProvider 获取默认数据源
+func DefaultProvider() Provider {
+	return defaultProvider
+}
+
+// NewProviderForExchange 根据交易平台创建对应的行情数据源
+// 返回的数据源带有共享K线缓存（同一平台的trader共用websocket订阅）
+// fallbackToBinance 为true时，非币安平台的数据获取失败会回退到币安
+func NewProviderForExchange(exchange string, testnet bool, fallbackToBinance bool) (Provider, error) {
+	var provider Provider
+	switch exchange {
+	case "", "binance":
+		return defaultProvider, nil
+	case "hyperliquid":
+		provider = SharedKlineCache(NewHyperliquidProvider(testnet))
+	case "aster":
+		provider = SharedKlineCache(NewAsterProvider())
+	default:
+		return nil, fmt.Errorf("不支持的行情数据源: %s", exchange)
+	}
+
+	if fallbackToBinance {
+		return NewFallbackProvider(provider, defaultProvider), nil
+	}
+	return provider, nil
+}
+
+// FallbackProvider 带回退的数据源：主数据源失败时使用备用数据源
+type FallbackProvider struct {
+	primary  Provider
+	fallback Provider
+}
+
+// NewFallbackProvider 创建带回退的数据源
+func NewFallbackProvider(primary, fallback Provider) *FallbackProvider {
+	return &FallbackProvider{
+		primary:  primary,
+		fallback: fallback,
+	}
+}
+
+// Name 数据源名称
+func (p *FallbackProvider) Name() string {
+	return fmt.Sprintf("%s(fallback:%s)", p.primary.Name(), p.fallback.Name())
+}
+
+// WithContext 返回主、备数据源都绑定请求上下文的副本
+func (p *FallbackProvider) WithContext(ctx context.Context) Provider {
+	return NewFallbackProvider(WithContext(ctx, p.primary), WithContext(ctx, p.fallback))
+}
+
+// GetKlines 获取K线数据
+func (p *FallbackProvider) GetKlines(symbol, interval string, limit int) ([]Kline, error) {
+	klines, err := p.primary.GetKlines(symbol, interval, limit)
+	if err == nil && len(klines) > 0 {
+		return klines, nil
+	}
+	log.Printf("⚠️  %s 获取%s %s K线失败，回退到%s: %v", p.primary.Name(), symbol, interval, p.fallback.Name(), err)
+	return p.fallback.GetKlines(symbol, interval, limit)
+}
+
+// GetOpenInterest 获取持仓量
+func (p *FallbackProvider) GetOpenInterest(symbol string) (*OIData, error) {
+	oi, err := p.primary.GetOpenInterest(symbol)
+	if err == nil {
+		return oi, nil
+	}
+	log.Printf("⚠️  %s 获取%s 持仓量失败，回退到%s: %v", p.primary.Name(), symbol, p.fallback.Name(), err)
+	return p.fallback.GetOpenInterest(symbol)
+}
+
+// GetOpenInterestHistory 获取历史持仓量
+func (p *FallbackProvider) GetOpenInterestHistory(symbol, period string, limit int) ([]OIPoint, error) {
+	history, err := p.primary.GetOpenInterestHistory(symbol, period, limit)
+	if err == nil && len(history) > 0 {
+		return history, nil
+	}
+	if !errors.Is(err, ErrNotSupported) {
+		log.Printf("⚠️  %s 获取%s %s 历史持仓量失败，回退到%s: %v", p.primary.Name(), symbol, period, p.fallback.Name(), err)
+	}
+	return p.fallback.GetOpenInterestHistory(symbol, period, limit)
+}
+
+// GetFunding 获取资金费率
+func (p *FallbackProvider) GetFunding(symbol string) (*FundingData, error) {
+	funding, err := p.primary.GetFunding(symbol)
+	if err == nil {
+		return funding, nil
+	}
+	log.Printf("⚠️  %s 获取%s 资金费率失败，回退到%s: %v", p.primary.Name(), symbol, p.fallback.Name(), err)
+	return p.fallback.GetFunding(symbol)
+}
+
+// GetFundingHistory 获取资金费率历史
+func (p *FallbackProvider) GetFundingHistory(symbol string, limit int) ([]FundingPoint, error) {
+	history, err := p.primary.GetFundingHistory(symbol, limit)
+	if err == nil && len(history) > 0 {
+		return history, nil
+	}
+	if !errors.Is(err, ErrNotSupported) {
+		log.Printf("⚠️  %s 获取%s 资金费率历史失败，回退到%s: %v", p.primary.Name(), symbol, p.fallback.Name(), err)
+	}
+	return p.fallback.GetFundingHistory(symbol, limit)
+}
+
+// GetOrderBook 获取订单簿快照
+func (p *FallbackProvider) GetOrderBook(symbol string, limit int) (*OrderBook, error) {
+	book, err := p.primary.GetOrderBook(symbol, limit)
+	if err == nil {
+		return book, nil
+	}
+	log.Printf("⚠️  %s 获取%s 订单簿失败，回退到%s: %v", p.primary.Name(), symbol, p.fallback.Name(), err)
+	return p.fallback.GetOrderBook(symbol, limit)
+}
+
+// GetLongShortRatio 获取多空账户比序列
+func (p *FallbackProvider) GetLongShortRatio(symbol, scope, period string, limit int) ([]LongShortPoint, error) {
+	points, err := p.primary.GetLongShortRatio(symbol, scope, period, limit)
+	if err == nil && len(points) > 0 {
+		return points, nil
+	}
+	if !errors.Is(err, ErrNotSupported) {
+		log.Printf("⚠️  %s 获取%s 多空比失败，回退到%s: %v", p.primary.Name(), symbol, p.fallback.Name(), err)
+	}
+	return p.fallback.GetLongShortRatio(symbol, scope, period, limit)
+}
+
+// GetTakerVolume 获取主动买卖量序列
+func (p *FallbackProvider) GetTakerVolume(symbol, period string, limit int) ([]TakerVolumePoint, error) {
+	points, err := p.primary.GetTakerVolume(symbol, period, limit)
+	if err == nil && len(points) > 0 {
+		return points, nil
+	}
+	if !errors.Is(err, ErrNotSupported) {
+		log.Printf("⚠️  %s 获取%s 主动买卖量失败，回退到%s: %v", p.primary.Name(), symbol, p.fallback.Name(), err)
+	}
+	return p.fallback.GetTakerVolume(symbol, period, limit)
+}
+
+// GetLiquidations 获取强平统计
+func (p *FallbackProvider) GetLiquidations(symbol string) (*LiquidationStats, error) {
+	stats, err := p.primary.GetLiquidations(symbol)
+	if err == nil {
+		return stats, nil
+	}
+	if !errors.Is(err, ErrNotSupported) {
+		log.Printf("⚠️  %s 获取%s 强平统计失败，回退到%s: %v", p.primary.Name(), symbol, p.fallback.Name(), err)
+	}
+	return p.fallback.GetLiquidations(symbol)
+}
+
+// IntervalDuration 将K线周期字符串转换为时长（如 "3m" -> 3分钟），不支持月线
+func IntervalDuration(interval string) (time.Duration, error) {
+	if len(interval) < 2 {
+		return 0, fmt.Errorf("无效的K线周期: %s", interval)
+	}
+
+	var n int
+	if _, err := fmt.Sscanf(interval[:len(interval)-1], "%d", &n); err != nil || n <= 0 {
+		return 0, fmt.Errorf("无效的K线周期: %s", interval)
+	}
+
+	switch interval[len(interval)-1] {
+	case 'm':
+		return time.Duration(n) * time.Minute, nil
+	case 'h':
+		return time.Duration(n) * time.Hour, nil
+	case 'd':
+		return time.Duration(n) * 24 * time.Hour, nil
+	case 'w':
+		return time.Duration(n) * 7 * 24 * time.Hour, nil
+	case 'M':
+		// 月线按自然月划分，长度不固定，无法按固定间隔检查K线连续性和缺口
+		return 0, fmt.Errorf("不支持月线周期: %s", interval)
+	default:
+		return 0, fmt.Errorf("无效的K线周期: %s", interval)
+	}
+}
@@ -0,0 +1,53 @@
+package market
+
+import (
+	"fmt"
+	"time"
+)
+
+const qualityZeroVolumeBars = 3 // 最近连续多少根已收盘K线成交量为0视为停牌
+
+// checkDataQuality 检查各周期K线是否新鲜、连续、有成交
+// 返回问题列表（为空表示通过），有问题的币种不应开仓
+func checkDataQuality(klinesByInterval map[string][]Kline, order []string, now time.Time) []string {
+	var issues []string
+	for _, interval := range order {
+		klines := klinesByInterval[interval]
+		d, err := IntervalDuration(interval)
+		if err != nil || len(klines) == 0 {
+			continue
+		}
+
+		// 最后一根K线应为当前未收盘或刚收盘的K线，缺失一整根以上说明数据过期（下架/停牌/推送中断）
+		last := klines[len(klines)-1]
+		if age := now.Sub(time.UnixMilli(last.CloseTime)); age > d {
+			issues = append(issues, fmt.Sprintf("%s 最后一根K线已收盘%s，数据过期", interval, age.Truncate(time.Second)))
+		}
+
+		// K线缺口
+		gaps := 0
+		var firstGap time.Time
+		for i := 1; i < len(klines); i++ {
+			if time.Duration(klines[i].OpenTime-klines[i-1].OpenTime)*time.Millisecond != d {
+				if gaps == 0 {
+					firstGap = time.UnixMilli(klines[i-1].OpenTime).Add(d)
+				}
+				gaps++
+			}
+		}
+		if gaps > 0 {
+			issues = append(issues, fmt.Sprintf("%s K线有%d处缺口（首个: %s）", interval, gaps, firstGap.Format("01-02 15:04")))
+		}
+
+		// 最近连续零成交
+		closed := closedKlines(klines, now)
+		zero := 0
+		for i := len(closed) - 1; i >= 0 && closed[i].Volume == 0; i-- {
+			zero++
+		}
+		if zero >= qualityZeroVolumeBars {
+			issues = append(issues, fmt.Sprintf("%s 最近%d根K线成交量为0", interval, zero))
+		}
+	}
+	return issues
+}
@@ -0,0 +1,106 @@
+package market
+
+import (
+	"fmt"
+	"math"
+)
+
+// Regime 市场状态
+// 判定放在 market 包内而不是单独的 market/regime 包：判定依赖本包未导出的指标实现（ADX、RMA、EMA），
+// 而 Data.Regime 又引用 RegimeData，拆成子包会与 market 互相导入。
+// 判定阈值只在 ClassifyRegime 中维护，decision 的市场状态规则只使用判定结果。
+type Regime string
+
+const (
+	RegimeTrendingUp     Regime = "trending_up"     // 上升趋势
+	RegimeTrendingDown   Regime = "trending_down"   // 下降趋势
+	RegimeRanging        Regime = "ranging"         // 震荡
+	RegimeHighVolatility Regime = "high_volatility" // 高波动
+)
+
+// Regimes 所有市场状态（用于配置校验）
+var Regimes = []Regime{RegimeTrendingUp, RegimeTrendingDown, RegimeRanging, RegimeHighVolatility}
+
+const (
+	regimePeriod        = 14   // ADX / ATR 周期
+	regimeEMAPeriod     = 20   // 趋势方向使用的EMA周期
+	regimeSlopeBars     = 5    // EMA斜率的回看K线数
+	regimeHighVolPct    = 90   // ATR分位 ≥ 该值视为高波动
+	regimeTrendADX      = 25   // ADX ≥ 该值视为有趋势
+	regimeMinSlopeATR   = 0.05 // |EMA斜率| ≥ 该值（ATR/根）才确认趋势方向
+	regimeMinATRSamples = 20   // 计算ATR分位至少需要的样本数
+)
+
+// RegimeData 市场状态及其判定依据
+type RegimeData struct {
+	Regime        Regime
+	Interval      string  // 判定使用的K线周期
+	ADX           float64 // ADX(14)
+	ATRPercentile float64 // 当前ATR(14)在可用历史中的分位（0-100）
+	EMASlope      float64 // EMA20近5根的平均斜率，以ATR为单位（正为向上）
+}
+
+// ClassifyRegime 根据ADX、ATR分位和EMA斜率判定市场状态（数据不足时返回nil）
+// 优先级：ATR处于历史高位 → 高波动；ADX显示有趋势且EMA斜率足够 → 按斜率方向判定趋势；否则为震荡
+func ClassifyRegime(klines []Kline, interval string) *RegimeData {
+	if len(klines) < 2*regimePeriod+regimeSlopeBars {
+		return nil
+	}
+	last := len(klines) - 1
+
+	adx := (&adxIndicator{period: regimePeriod}).Calculate(klines)[0].Values
+	atr := rmaOf(trueRangeOf(klines), regimePeriod)
+	ema := emaOf(closesOf(klines), regimeEMAPeriod)
+	if math.IsNaN(adx[last]) || math.IsNaN(atr[last]) || atr[last] == 0 ||
+		math.IsNaN(ema[last]) || math.IsNaN(ema[last-regimeSlopeBars]) {
+		return nil
+	}
+
+	var history []float64
+	for _, v := range atr {
+		if !math.IsNaN(v) {
+			history = append(history, v)
+		}
+	}
+	if len(history) < regimeMinATRSamples {
+		return nil
+	}
+
+	r := &RegimeData{
+		Interval:      interval,
+		ADX:           adx[last],
+		ATRPercentile: percentileRank(history, atr[last]),
+		EMASlope:      (ema[last] - ema[last-regimeSlopeBars]) / float64(regimeSlopeBars) / atr[last],
+	}
+
+	switch {
+	case r.ATRPercentile >= regimeHighVolPct:
+		r.Regime = RegimeHighVolatility
+	case r.ADX >= regimeTrendADX && r.EMASlope >= regimeMinSlopeATR:
+		r.Regime = RegimeTrendingUp
+	case r.ADX >= regimeTrendADX && r.EMASlope <= -regimeMinSlopeATR:
+		r.Regime = RegimeTrendingDown
+	default:
+		r.Regime = RegimeRanging
+	}
+	return r
+}
+
+// percentileRank value在values中的百分位（相等的值按一半计入，序列恒定时为50）
+func percentileRank(values []float64, value float64) float64 {
+	below, equal := 0, 0
+	for _, v := range values {
+		if v < value {
+			below++
+		} else if v == value {
+			equal++
+		}
+	}
+	return (float64(below) + float64(equal)/2) / float64(len(values)) * 100
+}
+
+// formatRegime 格式化市场状态
+func formatRegime(r *RegimeData) string {
+	return fmt.Sprintf("Regime (%s): %s | ADX %.1f | ATR percentile %.0f | EMA20 slope %+.2f ATR/bar\n\n",
+		r.Interval, r.Regime, r.ADX, r.ATRPercentile, r.EMASlope)
+}
@@ -0,0 +1,192 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"nofx/market"
+)
+
+// DownloadOptions 下载参数
+type DownloadOptions struct {
+	Symbols      []string
+	Intervals    []string
+	From         time.Time
+	To           time.Time
+	Funding      bool // 是否下载资金费率历史
+	OpenInterest bool // 是否下载历史持仓量（统计周期与K线周期相同，数据源不支持的周期跳过）
+}
+
+// Download 从数据源下载历史数据到本地（增量：只补齐本地缺失的区间和缺口）
+func (s *Store) Download(provider market.HistoryProvider, opts DownloadOptions) error {
+	if !opts.From.Before(opts.To) {
+		return fmt.Errorf("起始时间必须早于结束时间")
+	}
+	source := market.StoreSource(provider)
+
+	for _, symbol := range opts.Symbols {
+		symbol = market.Normalize(symbol)
+
+		for _, interval := range opts.Intervals {
+			if err := s.downloadKlines(provider, source, symbol, interval, opts.From, opts.To); err != nil {
+				return fmt.Errorf("%s %s K线下载失败: %w", symbol, interval, err)
+			}
+
+			if opts.OpenInterest {
+				err := s.downloadOpenInterest(provider, source, symbol, interval, opts.From, opts.To)
+				if errors.Is(err, market.ErrNotSupported) {
+					log.Printf("⏭️  %s %s: %v", symbol, interval, err)
+				} else if err != nil {
+					return fmt.Errorf("%s %s 持仓量下载失败: %w", symbol, interval, err)
+				}
+			}
+		}
+
+		if opts.Funding {
+			if err := s.downloadFunding(provider, source, symbol, opts.From, opts.To); err != nil {
+				return fmt.Errorf("%s 资金费率下载失败: %w", symbol, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// downloadKlines 下载K线：本地范围之前、之后的区间以及中间的缺口
+func (s *Store) downloadKlines(provider market.HistoryProvider, source, symbol, interval string, from, to time.Time) error {
+	step, err := market.IntervalDuration(interval)
+	if err != nil {
+		return err
+	}
+
+	// 结束时间不超过最后一根已收盘K线
+	if latestClosed := time.Now().Truncate(step).Add(-step); to.After(latestClosed) {
+		to = latestClosed
+	}
+
+	var ranges []Gap
+	first, last, ok, err := s.KlineRange(source, symbol, interval)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		ranges = append(ranges, Gap{Start: from, End: to})
+	} else {
+		if from.Before(first) {
+			ranges = append(ranges, Gap{Start: from, End: first.Add(-step)})
+		}
+		gaps, err := s.KlineGaps(source, symbol, interval)
+		if err != nil {
+			return err
+		}
+		for _, gap := range gaps {
+			if gap.End.After(from) && gap.Start.Before(to) {
+				ranges = append(ranges, Gap{Start: gap.Start, End: gap.End.Add(-step)})
+			}
+		}
+		if next := last.Add(step); !next.After(to) {
+			ranges = append(ranges, Gap{Start: next, End: to})
+		}
+	}
+
+	total := 0
+	for _, r := range ranges {
+		start := r.Start
+		for !start.After(r.End) {
+			klines, err := provider.GetKlinesRange(symbol, interval, start, r.End)
+			if err != nil {
+				return err
+			}
+			if len(klines) == 0 {
+				break
+			}
+			if err := s.MergeKlines(source, symbol, interval, klines); err != nil {
+				return err
+			}
+			total += len(klines)
+			start = time.UnixMilli(klines[len(klines)-1].OpenTime).Add(step)
+		}
+	}
+
+	gaps, err := s.KlineGaps(source, symbol, interval)
+	if err != nil {
+		return err
+	}
+	log.Printf("✓ [%s] %s %s K线: 新增%d根", source, symbol, interval, total)
+	if len(gaps) > 0 {
+		log.Printf("⚠️  [%s] %s %s 仍有%d个缺口（交易所无数据），首个: %s ~ %s", source, symbol, interval, len(gaps),
+			gaps[0].Start.Format(time.RFC3339), gaps[0].End.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// downloadFunding 下载资金费率（只补齐本地最后一条之后和最早一条之前的区间）
+func (s *Store) downloadFunding(provider market.HistoryProvider, source, symbol string, from, to time.Time) error {
+	ranges := []Gap{{Start: from, End: to}}
+	first, last, ok, err := s.FundingRange(source, symbol)
+	if err != nil {
+		return err
+	}
+	if ok {
+		ranges = ranges[:0]
+		if from.Before(first) {
+			ranges = append(ranges, Gap{Start: from, End: first.Add(-time.Millisecond)})
+		}
+		if last.Before(to) {
+			ranges = append(ranges, Gap{Start: last.Add(time.Millisecond), End: to})
+		}
+	}
+
+	total := 0
+	for _, r := range ranges {
+		start := r.Start
+		for start.Before(r.End) {
+			points, err := provider.GetFundingHistoryRange(symbol, start, r.End)
+			if err != nil {
+				return err
+			}
+			if len(points) == 0 {
+				break
+			}
+			if err := s.MergeFunding(source, symbol, points); err != nil {
+				return err
+			}
+			total += len(points)
+			start = time.UnixMilli(points[len(points)-1].Time + 1)
+		}
+	}
+
+	log.Printf("✓ [%s] %s 资金费率: 新增%d条", source, symbol, total)
+	return nil
+}
+
+// downloadOpenInterest 下载历史持仓量（只补齐本地最后一条之后的区间）
+func (s *Store) downloadOpenInterest(provider market.HistoryProvider, source, symbol, period string, from, to time.Time) error {
+	start := from
+	if _, last, ok, err := s.OpenInterestRange(source, symbol, period); err != nil {
+		return err
+	} else if ok && last.After(start) {
+		start = last.Add(time.Millisecond)
+	}
+
+	total := 0
+	for start.Before(to) {
+		points, err := provider.GetOpenInterestHistoryRange(symbol, period, start, to)
+		if err != nil {
+			return err
+		}
+		if len(points) == 0 {
+			break
+		}
+		if err := s.MergeOpenInterest(source, symbol, period, points); err != nil {
+			return err
+		}
+		total += len(points)
+		start = time.UnixMilli(points[len(points)-1].Time + 1)
+	}
+
+	log.Printf("✓ [%s] %s %s 持仓量: 新增%d条", source, symbol, period, total)
+	return nil
+}
@@ -0,0 +1,448 @@
+// Package store 本地历史行情存储
+//
+// 目录结构: <dir>/<数据源>/<SYMBOL>/
+//
+//	klines_<周期>.csv   open_time,open,high,low,close,volume,close_time
+//	funding.csv         time,rate
+//	oi_<周期>.csv       time,open_interest,value
+//
+// 每个文件按时间正序、每行一条记录，只保存已收盘/已结算的数据。
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"nofx/market"
+)
+
+// Store 本地历史行情存储
+type Store struct {
+	dir string
+	mu  sync.Mutex // 串行化写入（读取不加锁，写入通过临时文件+重命名保证原子性）
+}
+
+// Gap K线缺口：[Start, End) 区间内的K线缺失
+type Gap struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Open 打开（必要时创建）本地存储目录
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建行情存储目录失败: %w", err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+// Dir 存储根目录
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// LatestKlines 读取本地最近limit根K线，本地没有该序列时返回空
+func (s *Store) LatestKlines(source, symbol, interval string, limit int) ([]market.Kline, error) {
+	lines, err := tailLines(s.klinePath(source, symbol, interval), limit)
+	if err != nil {
+		return nil, err
+	}
+	return parseKlineLines(lines)
+}
+
+// LoadKlines 读取 [from, to) 区间内的K线（用于回测和指标预热）
+func (s *Store) LoadKlines(source, symbol, interval string, from, to time.Time) ([]market.Kline, error) {
+	records, err := readRecords(s.klinePath(source, symbol, interval))
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make([]string, 0, len(records))
+	for _, r := range records {
+		if r.key >= from.UnixMilli() && r.key < to.UnixMilli() {
+			lines = append(lines, r.line)
+		}
+	}
+	return parseKlineLines(lines)
+}
+
+// AppendKlines 追加K线（只追加晚于本地最后一根的K线，更早的忽略；缺口由 KlineGaps 检测）
+func (s *Store) AppendKlines(source, symbol, interval string, klines []market.Kline) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := s.klinePath(source, symbol, interval)
+	last, err := lastKey(path)
+	if err != nil {
+		return err
+	}
+
+	var records []record
+	for _, k := range klines {
+		if k.OpenTime > last {
+			records = append(records, klineRecord(k))
+		}
+	}
+	return appendRecords(path, records)
+}
+
+// MergeKlines 合并写入K线（可填补中间缺口，同一根K线以新数据为准）
+func (s *Store) MergeKlines(source, symbol, interval string, klines []market.Kline) error {
+	records := make([]record, len(klines))
+	for i, k := range klines {
+		records[i] = klineRecord(k)
+	}
+	return s.merge(s.klinePath(source, symbol, interval), records)
+}
+
+// KlineGaps 检测本地K线序列中的缺口
+func (s *Store) KlineGaps(source, symbol, interval string) ([]Gap, error) {
+	d, err := market.IntervalDuration(interval)
+	if err != nil {
+		return nil, err
+	}
+	records, err := readRecords(s.klinePath(source, symbol, interval))
+	if err != nil {
+		return nil, err
+	}
+
+	var gaps []Gap
+	for i := 1; i < len(records); i++ {
+		if expected := records[i-1].key + d.Milliseconds(); records[i].key > expected {
+			gaps = append(gaps, Gap{Start: time.UnixMilli(expected), End: time.UnixMilli(records[i].key)})
+		}
+	}
+	return gaps, nil
+}
+
+// KlineRange 本地K线序列的首尾开盘时间（无数据时ok为false）
+func (s *Store) KlineRange(source, symbol, interval string) (first, last time.Time, ok bool, err error) {
+	return s.keyRange(s.klinePath(source, symbol, interval))
+}
+
+// LoadFunding 读取 [from, to) 区间内的资金费率
+func (s *Store) LoadFunding(source, symbol string, from, to time.Time) ([]market.FundingPoint, error) {
+	records, err := readRecords(s.fundingPath(source, symbol))
+	if err != nil {
+		return nil, err
+	}
+
+	var points []market.FundingPoint
+	for _, r := range records {
+		if r.key < from.UnixMilli() || r.key >= to.UnixMilli() {
+			continue
+		}
+		fields := strings.Split(r.line, ",")
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("资金费率记录格式错误: %s", r.line)
+		}
+		rate, _ := strconv.ParseFloat(fields[1], 64)
+		points = append(points, market.FundingPoint{Time: r.key, Rate: rate})
+	}
+	return points, nil
+}
+
+// MergeFunding 合并写入资金费率
+func (s *Store) MergeFunding(source, symbol string, points []market.FundingPoint) error {
+	records := make([]record, len(points))
+	for i, p := range points {
+		records[i] = record{key: p.Time, line: fmt.Sprintf("%d,%s", p.Time, formatFloat(p.Rate))}
+	}
+	return s.merge(s.fundingPath(source, symbol), records)
+}
+
+// FundingRange 本地资金费率的首尾时间
+func (s *Store) FundingRange(source, symbol string) (first, last time.Time, ok bool, err error) {
+	return s.keyRange(s.fundingPath(source, symbol))
+}
+
+// LoadOpenInterest 读取 [from, to) 区间内的历史持仓量
+func (s *Store) LoadOpenInterest(source, symbol, period string, from, to time.Time) ([]market.OIPoint, error) {
+	records, err := readRecords(s.oiPath(source, symbol, period))
+	if err != nil {
+		return nil, err
+	}
+
+	var points []market.OIPoint
+	for _, r := range records {
+		if r.key < from.UnixMilli() || r.key >= to.UnixMilli() {
+			continue
+		}
+		fields := strings.Split(r.line, ",")
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("持仓量记录格式错误: %s", r.line)
+		}
+		oi, _ := strconv.ParseFloat(fields[1], 64)
+		value, _ := strconv.ParseFloat(fields[2], 64)
+		points = append(points, market.OIPoint{Time: r.key, OpenInterest: oi, Value: value})
+	}
+	return points, nil
+}
+
+// MergeOpenInterest 合并写入历史持仓量
+func (s *Store) MergeOpenInterest(source, symbol, period string, points []market.OIPoint) error {
+	records := make([]record, len(points))
+	for i, p := range points {
+		records[i] = record{key: p.Time, line: fmt.Sprintf("%d,%s,%s", p.Time, formatFloat(p.OpenInterest), formatFloat(p.Value))}
+	}
+	return s.merge(s.oiPath(source, symbol, period), records)
+}
+
+// OpenInterestRange 本地历史持仓量的首尾时间
+func (s *Store) OpenInterestRange(source, symbol, period string) (first, last time.Time, ok bool, err error) {
+	return s.keyRange(s.oiPath(source, symbol, period))
+}
+
+func (s *Store) klinePath(source, symbol, interval string) string {
+	return filepath.Join(s.dir, source, market.Normalize(symbol), "klines_"+interval+".csv")
+}
+
+func (s *Store) fundingPath(source, symbol string) string {
+	return filepath.Join(s.dir, source, market.Normalize(symbol), "funding.csv")
+}
+
+func (s *Store) oiPath(source, symbol, period string) string {
+	return filepath.Join(s.dir, source, market.Normalize(symbol), "oi_"+period+".csv")
+}
+
+// merge 合并记录（有重叠或早于本地最后一条时整体重写文件）
+func (s *Store) merge(path string, records []record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 新记录全部晚于本地最后一条时直接追加（下载新数据的常见情况，避免重写整个文件）
+	sort.Slice(records, func(i, j int) bool { return records[i].key < records[j].key })
+	last, err := lastKey(path)
+	if err != nil {
+		return err
+	}
+	if records[0].key > last {
+		return appendRecords(path, records)
+	}
+
+	existing, err := readRecords(path)
+	if err != nil {
+		return err
+	}
+
+	byKey := make(map[int64]string, len(existing)+len(records))
+	for _, r := range existing {
+		byKey[r.key] = r.line
+	}
+	for _, r := range records {
+		byKey[r.key] = r.line
+	}
+
+	merged := make([]record, 0, len(byKey))
+	for key, line := range byKey {
+		merged = append(merged, record{key: key, line: line})
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].key < merged[j].key })
+
+	return writeRecords(path, merged)
+}
+
+// keyRange 文件首尾记录的时间
+func (s *Store) keyRange(path string) (first, last time.Time, ok bool, err error) {
+	records, err := readRecords(path)
+	if err != nil || len(records) == 0 {
+		return time.Time{}, time.Time{}, false, err
+	}
+	return time.UnixMilli(records[0].key), time.UnixMilli(records[len(records)-1].key), true, nil
+}
+
+// record 一行记录（key为首列的毫秒时间戳）
+type record struct {
+	key  int64
+	line string
+}
+
+func klineRecord(k market.Kline) record {
+	return record{
+		key: k.OpenTime,
+		line: fmt.Sprintf("%d,%s,%s,%s,%s,%s,%d", k.OpenTime,
+			formatFloat(k.Open), formatFloat(k.High), formatFloat(k.Low), formatFloat(k.Close), formatFloat(k.Volume), k.CloseTime),
+	}
+}
+
+func parseKlineLines(lines []string) ([]market.Kline, error) {
+	klines := make([]market.Kline, 0, len(lines))
+	for _, line := range lines {
+		fields := strings.Split(line, ",")
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("K线记录格式错误: %s", line)
+		}
+		var values [7]float64
+		for i, f := range fields {
+			v, err := strconv.ParseFloat(f, 64)
+			if err != nil {
+				return nil, fmt.Errorf("K线记录格式错误: %s", line)
+			}
+			values[i] = v
+		}
+		klines = append(klines, market.Kline{
+			OpenTime:  int64(values[0]),
+			Open:      values[1],
+			High:      values[2],
+			Low:       values[3],
+			Close:     values[4],
+			Volume:    values[5],
+			CloseTime: int64(values[6]),
+		})
+	}
+	return klines, nil
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func parseKey(line string) (int64, error) {
+	keyField, _, _ := strings.Cut(line, ",")
+	return strconv.ParseInt(keyField, 10, 64)
+}
+
+// readRecords 读取整个文件（文件不存在时返回空）
+func readRecords(path string) ([]record, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []record
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		key, err := parseKey(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s 记录格式错误: %s", path, line)
+		}
+		records = append(records, record{key: key, line: line})
+	}
+	return records, scanner.Err()
+}
+
+// writeRecords 通过临时文件+重命名原子地重写文件
+func writeRecords(path string, records []record) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, r := range records {
+		w.WriteString(r.line)
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// appendRecords 追加记录到文件末尾
+func appendRecords(path string, records []record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var buf bytes.Buffer
+	for _, r := range records {
+		buf.WriteString(r.line)
+		buf.WriteByte('\n')
+	}
+	_, err = f.Write(buf.Bytes())
+	return err
+}
+
+// lastKey 文件最后一条记录的时间（文件不存在时返回0）
+func lastKey(path string) (int64, error) {
+	lines, err := tailLines(path, 1)
+	if err != nil || len(lines) == 0 {
+		return 0, err
+	}
+	return parseKey(lines[0])
+}
+
+// tailLines 从文件末尾读取最后n行（不读取整个文件）
+func tailLines(path string, n int) ([]string, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	const chunkSize = 64 * 1024
+	var data []byte
+	offset := info.Size()
+	for offset > 0 && bytes.Count(data, []byte{'\n'}) <= n {
+		size := int64(chunkSize)
+		if offset < size {
+			size = offset
+		}
+		offset -= size
+
+		chunk := make([]byte, size)
+		if _, err := f.ReadAt(chunk, offset); err != nil && err != io.EOF {
+			return nil, err
+		}
+		data = append(chunk, data...)
+	}
+
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if offset > 0 {
+		lines = lines[1:] // 第一行可能不完整
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	if len(lines) == 1 && lines[0] == "" {
+		return nil, nil
+	}
+	return lines, nil
+}
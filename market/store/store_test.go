@@ -0,0 +1,253 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"nofx/market"
+)
+
+var testBase = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// testKlines 生成从start开始的n根连续K线（收盘价为序号，便于核对）
+func testKlines(start time.Time, d time.Duration, n int) []market.Kline {
+	klines := make([]market.Kline, n)
+	for i := range klines {
+		open := start.Add(time.Duration(i) * d)
+		klines[i] = market.Kline{
+			OpenTime:  open.UnixMilli(),
+			Open:      100.5,
+			High:      101.25,
+			Low:       99.125,
+			Close:     float64(open.Unix()),
+			Volume:    1234.5678,
+			CloseTime: open.Add(d).UnixMilli() - 1,
+		}
+	}
+	return klines
+}
+
+func openStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	return s
+}
+
+func TestAppendAndLatestKlines(t *testing.T) {
+	s := openStore(t)
+	d := time.Hour
+	klines := testKlines(testBase, d, 10)
+
+	if err := s.AppendKlines("binance", "btcusdt", "1h", klines[:6]); err != nil {
+		t.Fatalf("AppendKlines: %v", err)
+	}
+	// 与本地重叠的部分被忽略，只追加更晚的K线
+	if err := s.AppendKlines("binance", "BTCUSDT", "1h", klines[4:]); err != nil {
+		t.Fatalf("AppendKlines: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		limit int
+		want  []market.Kline
+	}{
+		{"最近3根", 3, klines[7:]},
+		{"全部", 10, klines},
+		{"limit超过本地数量", 50, klines},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.LatestKlines("binance", "BTCUSDT", "1h", tt.limit)
+			if err != nil {
+				t.Fatalf("LatestKlines: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("返回%d根, 期望%d根", len(got), len(tt.want))
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("第%d根 = %+v, 期望 %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+
+	if got, err := s.LatestKlines("binance", "ETHUSDT", "1h", 10); err != nil || len(got) != 0 {
+		t.Errorf("不存在的序列应返回空: %v, %v", got, err)
+	}
+}
+
+func TestLatestKlinesAcrossChunks(t *testing.T) {
+	s := openStore(t)
+	d := time.Minute
+	// 超过单次读取的64KB，覆盖跨块读取和第一行不完整的情况
+	klines := testKlines(testBase, d, 3000)
+	if err := s.AppendKlines("binance", "BTCUSDT", "1m", klines); err != nil {
+		t.Fatalf("AppendKlines: %v", err)
+	}
+
+	for _, limit := range []int{1, 1000, 2999, 3000} {
+		got, err := s.LatestKlines("binance", "BTCUSDT", "1m", limit)
+		if err != nil {
+			t.Fatalf("LatestKlines(%d): %v", limit, err)
+		}
+		if len(got) != limit || got[0] != klines[len(klines)-limit] || got[len(got)-1] != klines[len(klines)-1] {
+			t.Errorf("LatestKlines(%d) 返回%d根，范围不正确", limit, len(got))
+		}
+	}
+}
+
+func TestMergeKlinesAndGaps(t *testing.T) {
+	s := openStore(t)
+	d := time.Hour
+	klines := testKlines(testBase, d, 12)
+
+	// 两段之间缺 [4, 8)
+	if err := s.MergeKlines("binance", "BTCUSDT", "1h", klines[:4]); err != nil {
+		t.Fatalf("MergeKlines: %v", err)
+	}
+	if err := s.MergeKlines("binance", "BTCUSDT", "1h", klines[8:]); err != nil {
+		t.Fatalf("MergeKlines: %v", err)
+	}
+
+	gaps, err := s.KlineGaps("binance", "BTCUSDT", "1h")
+	if err != nil {
+		t.Fatalf("KlineGaps: %v", err)
+	}
+	want := Gap{Start: testBase.Add(4 * d), End: testBase.Add(8 * d)}
+	if len(gaps) != 1 || !gaps[0].Start.Equal(want.Start) || !gaps[0].End.Equal(want.End) {
+		t.Fatalf("缺口 = %+v, 期望 [%+v]", gaps, want)
+	}
+
+	// 填补缺口，并覆盖一根已有的K线
+	fill := append([]market.Kline(nil), klines[3:9]...)
+	fill[0].Close = -1
+	if err := s.MergeKlines("binance", "BTCUSDT", "1h", fill); err != nil {
+		t.Fatalf("MergeKlines: %v", err)
+	}
+	if gaps, _ := s.KlineGaps("binance", "BTCUSDT", "1h"); len(gaps) != 0 {
+		t.Errorf("填补后仍有缺口: %+v", gaps)
+	}
+
+	loaded, err := s.LoadKlines("binance", "BTCUSDT", "1h", testBase, testBase.Add(12*d))
+	if err != nil {
+		t.Fatalf("LoadKlines: %v", err)
+	}
+	if len(loaded) != 12 {
+		t.Fatalf("LoadKlines 返回%d根, 期望12根", len(loaded))
+	}
+	for i := 1; i < len(loaded); i++ {
+		if loaded[i].OpenTime-loaded[i-1].OpenTime != d.Milliseconds() {
+			t.Fatalf("第%d根K线顺序错误", i)
+		}
+	}
+	if loaded[3].Close != -1 {
+		t.Errorf("重叠的K线应以新数据为准")
+	}
+
+	first, last, ok, err := s.KlineRange("binance", "BTCUSDT", "1h")
+	if err != nil || !ok || !first.Equal(testBase) || !last.Equal(testBase.Add(11*d)) {
+		t.Errorf("KlineRange = %v, %v, %v, %v", first, last, ok, err)
+	}
+}
+
+func TestLoadKlinesRange(t *testing.T) {
+	s := openStore(t)
+	d := time.Hour
+	klines := testKlines(testBase, d, 10)
+	if err := s.MergeKlines("binance", "BTCUSDT", "1h", klines); err != nil {
+		t.Fatalf("MergeKlines: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		from, to time.Time
+		want     int
+	}{
+		{"左闭右开", testBase.Add(2 * d), testBase.Add(5 * d), 3},
+		{"区间之前", testBase.Add(-5 * d), testBase, 0},
+		{"覆盖全部", testBase.Add(-d), testBase.Add(20 * d), 10},
+	}
+	for _, tt := range tests {
+		got, err := s.LoadKlines("binance", "BTCUSDT", "1h", tt.from, tt.to)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(got) != tt.want {
+			t.Errorf("%s: 返回%d根, 期望%d根", tt.name, len(got), tt.want)
+		}
+	}
+}
+
+func TestFundingAndOpenInterest(t *testing.T) {
+	s := openStore(t)
+	step := 8 * time.Hour
+
+	var funding []market.FundingPoint
+	var oi []market.OIPoint
+	for i := 0; i < 6; i++ {
+		ts := testBase.Add(time.Duration(i) * step).UnixMilli()
+		funding = append(funding, market.FundingPoint{Time: ts, Rate: 0.0001 * float64(i)})
+		oi = append(oi, market.OIPoint{Time: ts, OpenInterest: 1000 + float64(i), Value: 1e6 + float64(i)})
+	}
+
+	// 乱序、重叠写入
+	if err := s.MergeFunding("binance", "BTCUSDT", funding[3:]); err != nil {
+		t.Fatalf("MergeFunding: %v", err)
+	}
+	if err := s.MergeFunding("binance", "BTCUSDT", funding[:4]); err != nil {
+		t.Fatalf("MergeFunding: %v", err)
+	}
+	if err := s.MergeOpenInterest("binance", "BTCUSDT", "4h", oi); err != nil {
+		t.Fatalf("MergeOpenInterest: %v", err)
+	}
+
+	gotFunding, err := s.LoadFunding("binance", "BTCUSDT", testBase, testBase.Add(6*step))
+	if err != nil {
+		t.Fatalf("LoadFunding: %v", err)
+	}
+	if len(gotFunding) != len(funding) {
+		t.Fatalf("LoadFunding 返回%d条, 期望%d条", len(gotFunding), len(funding))
+	}
+	for i := range funding {
+		if gotFunding[i] != funding[i] {
+			t.Errorf("funding[%d] = %+v, 期望 %+v", i, gotFunding[i], funding[i])
+		}
+	}
+
+	gotOI, err := s.LoadOpenInterest("binance", "BTCUSDT", "4h", testBase.Add(step), testBase.Add(3*step))
+	if err != nil {
+		t.Fatalf("LoadOpenInterest: %v", err)
+	}
+	if len(gotOI) != 2 || gotOI[0] != oi[1] || gotOI[1] != oi[2] {
+		t.Errorf("LoadOpenInterest = %+v, 期望 %+v", gotOI, oi[1:3])
+	}
+
+	if _, last, ok, err := s.FundingRange("binance", "BTCUSDT"); err != nil || !ok || last.UnixMilli() != funding[5].Time {
+		t.Errorf("FundingRange last = %v, ok = %v, err = %v", last, ok, err)
+	}
+	if _, _, ok, err := s.OpenInterestRange("binance", "BTCUSDT", "1h"); err != nil || ok {
+		t.Errorf("不存在的持仓量序列 ok = %v, err = %v", ok, err)
+	}
+}
+
+func TestMalformedRecord(t *testing.T) {
+	s := openStore(t)
+	path := s.klinePath("binance", "BTCUSDT", "1h")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("1704067200000,1,2,3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.LatestKlines("binance", "BTCUSDT", "1h", 10); err == nil || !strings.Contains(err.Error(), "格式错误") {
+		t.Errorf("字段数错误的记录应报错: %v", err)
+	}
+}
@@ -3,10 +3,12 @@ package mcp
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -19,6 +21,29 @@ const (
 	ProviderCustom   Provider = "custom"
 )
 
+// 结构化输出方式
+const (
+	OutputText       = ""            // 纯文本（由调用方从文本中解析JSON）
+	OutputJSONSchema = "json_schema" // response_format: json_schema（OpenAI及兼容API）
+	OutputToolCall   = "tool"        // 强制调用函数，从函数参数中取JSON
+)
+
+// ErrStructuredOutputUnsupported API拒绝了结构化输出参数
+var ErrStructuredOutputUnsupported = errors.New("API不支持结构化输出")
+
+// ResponseSchema 结构化输出的JSON Schema
+type ResponseSchema struct {
+	Name        string                 // schema名称（tool模式下为函数名）
+	Description string                 // 描述
+	Schema      map[string]interface{} // JSON Schema（顶层必须是object）
+}
+
+// Message 对话消息（role: system / user / assistant）
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
 // Client AI API配置
 type Client struct {
 	Provider   Provider
@@ -27,7 +52,10 @@ type Client struct {
 	BaseURL    string
 	Model      string
 	Timeout    time.Duration
-	UseFullURL bool // 是否使用完整URL（不添加/chat/completions）
+	UseFullURL bool   // 是否使用完整URL（不添加/chat/completions）
+	OutputMode string // 结构化输出方式（OutputText / OutputJSONSchema / OutputToolCall）
+
+	structuredUnsupported int32 // API拒绝过结构化输出参数（原子读写，同一客户端会被多个协程并发使用）
 }
 
 func New() *Client {
@@ -86,6 +114,47 @@ func (cfg *Client) SetClient(Client Client) {
 
 // CallWithMessages 使用 system + user prompt 调用AI API（推荐）
 func (cfg *Client) CallWithMessages(systemPrompt, userPrompt string) (string, error) {
+	var messages []Message
+
+	// 如果有 system prompt，添加 system message
+	if systemPrompt != "" {
+		messages = append(messages, Message{Role: "system", Content: systemPrompt})
+	}
+
+	// 添加 user message
+	messages = append(messages, Message{Role: "user", Content: userPrompt})
+
+	return cfg.Chat(messages)
+}
+
+// Chat 使用完整对话历史调用AI API（多轮对话，如让AI修正上一次的输出）
+func (cfg *Client) Chat(messages []Message) (string, error) {
+	return cfg.chat(messages, nil)
+}
+
+// StructuredOutput 是否使用结构化输出（配置了 OutputMode 且API未拒绝过结构化输出参数）
+func (cfg *Client) StructuredOutput() bool {
+	return cfg.OutputMode != OutputText && atomic.LoadInt32(&cfg.structuredUnsupported) == 0
+}
+
+// ChatWithSchema 按 OutputMode 要求AI输出符合schema的JSON（OutputText时等同于Chat）
+// API拒绝结构化输出参数时回退为纯文本输出，并在之后的调用中不再尝试（不修改配置的 OutputMode）
+func (cfg *Client) ChatWithSchema(messages []Message, schema *ResponseSchema) (string, error) {
+	if !cfg.StructuredOutput() || schema == nil {
+		return cfg.chat(messages, nil)
+	}
+
+	result, err := cfg.chat(messages, schema)
+	if errors.Is(err, ErrStructuredOutputUnsupported) {
+		if atomic.CompareAndSwapInt32(&cfg.structuredUnsupported, 0, 1) {
+			fmt.Printf("⚠️  %v，回退为纯文本输出: %s\n", err, cfg.Model)
+		}
+		return cfg.chat(messages, nil)
+	}
+	return result, err
+}
+
+func (cfg *Client) chat(messages []Message, schema *ResponseSchema) (string, error) {
 	if cfg.APIKey == "" {
 		return "", fmt.Errorf("AI API密钥未设置，请先调用 SetDeepSeekAPIKey() 或 SetQwenAPIKey()")
 	}
@@ -99,7 +168,7 @@ func (cfg *Client) CallWithMessages(systemPrompt, userPrompt string) (string, er
 			fmt.Printf("⚠️  AI API调用失败，正在重试 (%d/%d)...\n", attempt, maxRetries)
 		}
 
-		result, err := cfg.callOnce(systemPrompt, userPrompt)
+		result, err := cfg.callOnce(messages, schema)
 		if err == nil {
 			if attempt > 1 {
 				fmt.Printf("✓ AI API重试成功\n")
@@ -124,25 +193,8 @@ func (cfg *Client) CallWithMessages(systemPrompt, userPrompt string) (string, er
 	return "", fmt.Errorf("重试%d次后仍然失败: %w", maxRetries, lastErr)
 }
 
-// callOnce 单次调用AI API（内部使用）
-func (cfg *Client) callOnce(systemPrompt, userPrompt string) (string, error) {
-	// 构建 messages 数组
-	messages := []map[string]string{}
-
-	// 如果有 system prompt，添加 system message
-	if systemPrompt != "" {
-		messages = append(messages, map[string]string{
-			"role":    "system",
-			"content": systemPrompt,
-		})
-	}
-
-	// 添加 user message
-	messages = append(messages, map[string]string{
-		"role":    "user",
-		"content": userPrompt,
-	})
-
+// callOnce 单次调用AI API（内部使用，schema为空时输出纯文本）
+func (cfg *Client) callOnce(messages []Message, schema *ResponseSchema) (string, error) {
 	// 构建请求体
 	requestBody := map[string]interface{}{
 		"model":       cfg.Model,
@@ -151,8 +203,34 @@ func (cfg *Client) callOnce(systemPrompt, userPrompt string) (string, error) {
 		"max_tokens":  2000,
 	}
 
-	// 注意：response_format 参数仅 OpenAI 支持，DeepSeek/Qwen 不支持
-	// 我们通过强化 prompt 和后处理来确保 JSON 格式正确
+	// 注意：response_format 参数仅 OpenAI 等部分API支持，DeepSeek/Qwen 不支持
+	// 不支持时使用纯文本输出，通过强化 prompt 和后处理来确保 JSON 格式正确
+	if schema != nil {
+		switch cfg.OutputMode {
+		case OutputJSONSchema:
+			requestBody["response_format"] = map[string]interface{}{
+				"type": "json_schema",
+				"json_schema": map[string]interface{}{
+					"name":        schema.Name,
+					"description": schema.Description,
+					"schema":      schema.Schema,
+				},
+			}
+		case OutputToolCall:
+			requestBody["tools"] = []interface{}{map[string]interface{}{
+				"type": "function",
+				"function": map[string]interface{}{
+					"name":        schema.Name,
+					"description": schema.Description,
+					"parameters":  schema.Schema,
+				},
+			}}
+			requestBody["tool_choice"] = map[string]interface{}{
+				"type":     "function",
+				"function": map[string]string{"name": schema.Name},
+			}
+		}
+	}
 
 	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
@@ -201,6 +279,9 @@ func (cfg *Client) callOnce(systemPrompt, userPrompt string) (string, error) {
 		return "", fmt.Errorf("读取响应失败: %w", err)
 	}
 
+	if resp.StatusCode == http.StatusBadRequest && schema != nil && rejectsStructuredOutput(body) {
+		return "", fmt.Errorf("%w (status %d): %s", ErrStructuredOutputUnsupported, resp.StatusCode, string(body))
+	}
 	if resp.StatusCode != http.StatusOK {
 		return "", fmt.Errorf("API返回错误 (status %d): %s", resp.StatusCode, string(body))
 	}
@@ -209,7 +290,13 @@ func (cfg *Client) callOnce(systemPrompt, userPrompt string) (string, error) {
 	var result struct {
 		Choices []struct {
 			Message struct {
-				Content string `json:"content"`
+				Content   string `json:"content"`
+				ToolCalls []struct {
+					Function struct {
+						Name      string `json:"name"`
+						Arguments string `json:"arguments"`
+					} `json:"function"`
+				} `json:"tool_calls"`
 			} `json:"message"`
 		} `json:"choices"`
 	}
@@ -222,9 +309,29 @@ func (cfg *Client) callOnce(systemPrompt, userPrompt string) (string, error) {
 		return "", fmt.Errorf("API返回空响应")
 	}
 
+	// tool模式：JSON在函数参数中（部分模型不调用函数而直接输出文本，此时返回文本交给调用方兜底解析）
+	if schema != nil && cfg.OutputMode == OutputToolCall {
+		for _, call := range result.Choices[0].Message.ToolCalls {
+			if call.Function.Name == schema.Name {
+				return call.Function.Arguments, nil
+			}
+		}
+	}
+
 	return result.Choices[0].Message.Content, nil
 }
 
+// rejectsStructuredOutput 400错误是否针对结构化输出参数（上下文超长、消息格式错误等其他400不回退）
+func rejectsStructuredOutput(body []byte) bool {
+	text := strings.ToLower(string(body))
+	for _, param := range []string{"response_format", "json_schema", "tool_choice", "tools"} {
+		if strings.Contains(text, param) {
+			return true
+		}
+	}
+	return false
+}
+
 // isRetryableError 判断错误是否可重试
 func isRetryableError(err error) bool {
 	errStr := err.Error()
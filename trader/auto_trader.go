@@ -1,6 +1,7 @@
 package trader
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -18,7 +19,7 @@ type AutoTraderConfig struct {
 	// Trader标识
 	ID      string // Trader唯一标识（用于日志目录等）
 	Name    string // Trader显示名称
-	AIModel string // AI模型: "qwen" 或 "deepseek"
+	AIModel string // AI模型: "qwen", "deepseek", "custom", "ensemble" 或 "rule"
 
 	// 交易平台选择
 	Exchange string // "binance", "hyperliquid" 或 "aster"
@@ -37,6 +38,31 @@ type AutoTraderConfig struct {
 	AsterSigner     string // Aster API钱包地址
 	AsterPrivateKey string // Aster API钱包私钥
 
+	// 行情数据配置
+	MarketDataFallback bool                     // 交易平台行情获取失败时是否回退到币安
+	Timeframes         []market.TimeframeConfig // 多周期分析配置（为空时使用默认的3m+4h）
+	MaxImpactPct       float64                  // 开仓前允许的最大盘口冲击（百分比，默认0.5）
+
+	// 相关性风控
+	MaxCorrelatedPositions int     // 同方向高相关持仓数上限（含本次开仓，0使用默认值2，-1表示不限）
+	CorrelationThreshold   float64 // 视为高相关的收益率相关系数（默认0.8）
+
+	// 市场状态规则
+	RegimeRules []decision.RegimeRule
+
+	// 候选币种过滤链（按顺序执行，为空时使用默认的15M持仓价值过滤）
+	Filters []decision.FilterConfig
+
+	// Prompt模板文件路径（为空时使用内置默认模板）
+	SystemPromptTemplate string
+	UserPromptTemplate   string
+
+	// 风险参数（渲染进prompt并在决策验证时强制执行）
+	RiskProfile decision.RiskProfile
+
+	// 决策解析或校验失败后让AI修正的最大轮数（0表示不修正）
+	MaxCorrectionRounds int
+
 	CoinPoolAPIURL string
 
 	// AI配置
@@ -49,6 +75,22 @@ type AutoTraderConfig struct {
 	CustomAPIKey    string
 	CustomModelName string
 
+	// 结构化输出方式（mcp.OutputJSONSchema / mcp.OutputToolCall，为空时从文本解析）
+	AIOutputMode string
+
+	// 多模型集成（配置后每个周期并发请求所有模型并按策略合并决策，忽略上面的单模型配置）
+	Ensemble *decision.EnsembleConfig
+
+	// 决策流水线（decision.PipelineSingle / decision.PipelineDebate，为空时为单次决策）
+	Pipeline string
+	Debate   *decision.DebateConfig // 多空辩论各阶段的模型（为空的阶段使用上面的主模型）
+
+	// 规则策略（配置后不调用AI，按技术指标给出决策）
+	Strategy *decision.RuleConfig
+
+	// 决策记忆（最近决策、平仓结果、币种胜率渲染进prompt，为空时不启用）
+	Memory *decision.MemoryConfig
+
 	// 扫描配置
 	ScanInterval time.Duration // 扫描间隔（建议3分钟）
 
@@ -74,15 +116,21 @@ type AutoTrader struct {
 	config                AutoTraderConfig
 	trader                Trader // 使用Trader接口（支持多平台）
 	mcpClient             *mcp.Client
+	strategy              decision.Strategy      // 决策策略（AI单次决策、多模型集成、多空辩论或规则策略）
+	provider              string                 // 决策来源（状态接口的ai_provider，如 DeepSeek、集成成员、规则名）
+	marketOptions         *market.Options        // 行情数据源（与交易平台一致）和时间框架
 	decisionLogger        *logger.DecisionLogger // 决策日志记录器
 	initialBalance        float64
 	dailyPnL              float64
 	lastResetTime         time.Time
 	stopUntil             time.Time
 	isRunning             bool
-	startTime             time.Time        // 系统启动时间
-	callCount             int              // AI调用次数
-	positionFirstSeenTime map[string]int64 // 持仓首次出现时间 (symbol_side -> timestamp毫秒)
+	startTime             time.Time                  // 系统启动时间
+	callCount             int                        // AI调用次数
+	positionFirstSeenTime map[string]int64           // 持仓首次出现时间 (symbol_side -> timestamp毫秒)
+	filters               []decision.CandidateFilter // 候选币种过滤链
+	prompts               *decision.PromptTemplates  // System / User Prompt 模板
+	lastCloseTime         map[string]time.Time       // 币种最近平仓时间（冷却期判断）
 }
 
 // NewAutoTrader 创建自动交易器
@@ -103,27 +151,88 @@ func NewAutoTrader(config AutoTraderConfig) (*AutoTrader, error) {
 	}
 
 	mcpClient := mcp.New()
+	var strategy decision.Strategy = &decision.AIStrategy{Client: mcpClient}
+	var provider string
 
 	// 初始化AI
-	if config.AIModel == "custom" {
+	if config.Strategy != nil {
+		// 规则策略（不调用AI）
+		rules, err := decision.NewRuleStrategy(*config.Strategy)
+		if err != nil {
+			return nil, fmt.Errorf("初始化规则策略失败: %w", err)
+		}
+		strategy = rules
+		provider = fmt.Sprintf("Rule: %s (%s)", rules.Name(), config.Strategy.Interval)
+		config.AIModel = "rule"
+		log.Printf("📏 [%s] 使用规则策略: %s (%s)", config.Name, rules.Name(), config.Strategy.Interval)
+	} else if config.Ensemble != nil {
+		// 多模型集成
+		ensemble, err := decision.NewEnsemble(*config.Ensemble)
+		if err != nil {
+			return nil, fmt.Errorf("初始化多模型集成失败: %w", err)
+		}
+		strategy = ensemble
+		provider = fmt.Sprintf("Ensemble(%s): %s", ensemble.Policy, strings.Join(ensemble.Names(), ", "))
+		config.AIModel = "ensemble"
+		log.Printf("🤖 [%s] 使用多模型集成(%s): %s", config.Name, ensemble.Policy, strings.Join(ensemble.Names(), ", "))
+	} else if config.AIModel == "custom" {
 		// 使用自定义API
 		mcpClient.SetCustomAPI(config.CustomAPIURL, config.CustomAPIKey, config.CustomModelName)
+		provider = config.CustomModelName
 		log.Printf("🤖 [%s] 使用自定义AI API: %s (模型: %s)", config.Name, config.CustomAPIURL, config.CustomModelName)
 	} else if config.UseQwen || config.AIModel == "qwen" {
 		// 使用Qwen
 		mcpClient.SetQwenAPIKey(config.QwenKey, "")
+		provider = "Qwen"
 		log.Printf("🤖 [%s] 使用阿里云Qwen AI", config.Name)
 	} else {
 		// 默认使用DeepSeek
 		mcpClient.SetDeepSeekAPIKey(config.DeepSeekKey)
+		provider = "DeepSeek"
 		log.Printf("🤖 [%s] 使用DeepSeek AI", config.Name)
 	}
+	if config.AIOutputMode != mcp.OutputText {
+		mcpClient.OutputMode = config.AIOutputMode
+		log.Printf("🧾 [%s] 结构化输出: %s", config.Name, config.AIOutputMode)
+	}
+
+	// 多空辩论流水线
+	if config.Pipeline == decision.PipelineDebate {
+		mainName := config.AIModel
+		if config.AIModel == "custom" {
+			mainName = config.CustomModelName
+		}
+		var debateConfig decision.DebateConfig
+		if config.Debate != nil {
+			debateConfig = *config.Debate
+		}
+		debate, err := decision.NewDebate(debateConfig, decision.ModelClient{Name: mainName, Client: mcpClient})
+		if err != nil {
+			return nil, fmt.Errorf("初始化多空辩论流水线失败: %w", err)
+		}
+		strategy = debate
+		provider = fmt.Sprintf("Debate: bull %s | bear %s | judge %s", debate.Bull.Name, debate.Bear.Name, debate.Judge.Name)
+		log.Printf("⚖️  [%s] 多空辩论流水线: 多方 %s | 空方 %s | 裁判 %s", config.Name, debate.Bull.Name, debate.Bear.Name, debate.Judge.Name)
+	}
 
 	// 初始化币种池API
 	if config.CoinPoolAPIURL != "" {
 		pool.SetCoinPoolAPI(config.CoinPoolAPIURL)
 	}
 
+	if config.MaxImpactPct <= 0 {
+		config.MaxImpactPct = 0.5
+	}
+	switch {
+	case config.MaxCorrelatedPositions == 0:
+		config.MaxCorrelatedPositions = 2
+	case config.MaxCorrelatedPositions < 0:
+		config.MaxCorrelatedPositions = 0 // 不限（与 decision.Context 的约定一致）
+	}
+	if config.CorrelationThreshold <= 0 {
+		config.CorrelationThreshold = 0.8
+	}
+
 	// 设置默认交易平台
 	if config.Exchange == "" {
 		config.Exchange = "binance"
@@ -153,6 +262,27 @@ func NewAutoTrader(config AutoTraderConfig) (*AutoTrader, error) {
 		return nil, fmt.Errorf("不支持的交易平台: %s", config.Exchange)
 	}
 
+	// 创建与交易平台一致的行情数据源
+	marketProvider, err := market.NewProviderForExchange(config.Exchange, config.HyperliquidTestnet, config.MarketDataFallback)
+	if err != nil {
+		return nil, fmt.Errorf("初始化行情数据源失败: %w", err)
+	}
+	log.Printf("📡 [%s] 行情数据源: %s", config.Name, marketProvider.Name())
+
+	// 创建候选币种过滤链
+	filters, err := decision.NewFilterChain(config.Filters)
+	if err != nil {
+		return nil, fmt.Errorf("初始化候选币种过滤器失败: %w", err)
+	}
+
+	config.RiskProfile = config.RiskProfile.WithDefaults()
+
+	// 加载Prompt模板
+	prompts, err := decision.LoadPromptTemplates(config.SystemPromptTemplate, config.UserPromptTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("加载Prompt模板失败: %w", err)
+	}
+
 	// 验证初始金额配置
 	if config.InitialBalance <= 0 {
 		return nil, fmt.Errorf("初始金额必须大于0，请在配置中设置InitialBalance")
@@ -170,6 +300,9 @@ func NewAutoTrader(config AutoTraderConfig) (*AutoTrader, error) {
 		config:                config,
 		trader:                trader,
 		mcpClient:             mcpClient,
+		strategy:              strategy,
+		provider:              provider,
+		marketOptions:         &market.Options{Provider: marketProvider, Timeframes: config.Timeframes},
 		decisionLogger:        decisionLogger,
 		initialBalance:        config.InitialBalance,
 		lastResetTime:         time.Now(),
@@ -177,6 +310,9 @@ func NewAutoTrader(config AutoTraderConfig) (*AutoTrader, error) {
 		callCount:             0,
 		isRunning:             false,
 		positionFirstSeenTime: make(map[string]int64),
+		filters:               filters,
+		prompts:               prompts,
+		lastCloseTime:         make(map[string]time.Time),
 	}, nil
 }
 
@@ -218,9 +354,9 @@ func (at *AutoTrader) Stop() {
 func (at *AutoTrader) runCycle() error {
 	at.callCount++
 
-	log.Printf("\n" + strings.Repeat("=", 70))
+	log.Print("\n" + strings.Repeat("=", 70))
 	log.Printf("⏰ %s - AI决策周期 #%d", time.Now().Format("2006-01-02 15:04:05"), at.callCount)
-	log.Printf(strings.Repeat("=", 70))
+	log.Print(strings.Repeat("=", 70))
 
 	// 创建决策记录
 	record := &logger.DecisionRecord{
@@ -285,9 +421,16 @@ func (at *AutoTrader) runCycle() error {
 	log.Printf("📊 账户净值: %.2f USDT | 可用: %.2f USDT | 持仓: %d",
 		ctx.Account.TotalEquity, ctx.Account.AvailableBalance, ctx.Account.PositionCount)
 
-	// 4. 调用AI获取完整决策
-	log.Println("🤖 正在请求AI分析并决策...")
-	decision, err := decision.GetFullDecision(ctx, at.mcpClient)
+	// 4. 调用策略获取完整决策
+	if at.config.Strategy != nil {
+		log.Printf("📏 正在按规则策略计算决策 [%s]...", at.provider)
+	} else {
+		log.Printf("🤖 正在请求AI分析并决策 [%s]...", at.provider)
+	}
+	decision, err := at.strategy.Decide(ctx)
+	record.MarketDataErrors = ctx.MarketDataErrors
+	record.DataQualityIssues = ctx.DataQualityIssues
+	record.FilteredCoins = ctx.FilteredCandidates
 
 	// 即使有错误，也保存思维链、决策和输入prompt（用于debug）
 	if decision != nil {
@@ -297,6 +440,24 @@ func (at *AutoTrader) runCycle() error {
 			decisionJSON, _ := json.MarshalIndent(decision.Decisions, "", "  ")
 			record.DecisionJSON = string(decisionJSON)
 		}
+		// 未通过验证的决策不执行，只记录原因
+		record.Rejected = toRejectedRecords(decision.Rejected)
+		for _, r := range decision.Rejected {
+			record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("⛔ %s %s 被拒绝: %s", r.Decision.Symbol, r.Decision.Action, r.Reason))
+		}
+		record.Corrections = toCorrectionRecords(decision.Corrections)
+		if decision.Ensemble != nil {
+			record.EnsemblePolicy = decision.Ensemble.Policy
+			record.ModelOutputs, record.EnsembleVotes = toEnsembleRecords(decision.Ensemble)
+		}
+		for _, stage := range decision.Debate {
+			record.Debate = append(record.Debate, logger.DebateStage{
+				Stage:    stage.Stage,
+				Model:    stage.Model,
+				Response: stage.Response,
+				Error:    stage.Error,
+			})
+		}
 	}
 
 	if err != nil {
@@ -305,11 +466,11 @@ func (at *AutoTrader) runCycle() error {
 
 		// 打印AI思维链（即使有错误）
 		if decision != nil && decision.CoTTrace != "" {
-			log.Printf("\n" + strings.Repeat("-", 70))
+			log.Print("\n" + strings.Repeat("-", 70))
 			log.Println("💭 AI思维链分析（错误情况）:")
 			log.Println(strings.Repeat("-", 70))
 			log.Println(decision.CoTTrace)
-			log.Printf(strings.Repeat("-", 70) + "\n")
+			log.Print(strings.Repeat("-", 70) + "\n")
 		}
 
 		at.decisionLogger.LogDecision(record)
@@ -317,11 +478,11 @@ func (at *AutoTrader) runCycle() error {
 	}
 
 	// 5. 打印AI思维链
-	log.Printf("\n" + strings.Repeat("-", 70))
+	log.Print("\n" + strings.Repeat("-", 70))
 	log.Println("💭 AI思维链分析:")
 	log.Println(strings.Repeat("-", 70))
 	log.Println(decision.CoTTrace)
-	log.Printf(strings.Repeat("-", 70) + "\n")
+	log.Print(strings.Repeat("-", 70) + "\n")
 
 	// 6. 打印AI决策
 	log.Printf("📋 AI决策列表 (%d 个):\n", len(decision.Decisions))
@@ -355,7 +516,7 @@ func (at *AutoTrader) runCycle() error {
 			Success:   false,
 		}
 
-		if err := at.executeDecisionWithRecord(&d, &actionRecord); err != nil {
+		if err := at.executeDecisionWithRecord(ctx, &d, &actionRecord); err != nil {
 			log.Printf("❌ 执行决策失败 (%s %s): %v", d.Symbol, d.Action, err)
 			actionRecord.Error = err.Error()
 			record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("❌ %s %s 失败: %v", d.Symbol, d.Action, err))
@@ -377,6 +538,64 @@ func (at *AutoTrader) runCycle() error {
 	return nil
 }
 
+// toEnsembleRecords 把各模型的输出和投票结果转换为日志记录格式
+func toEnsembleRecords(result *decision.EnsembleResult) ([]logger.ModelOutput, []logger.EnsembleVote) {
+	var outputs []logger.ModelOutput
+	for _, m := range result.Models {
+		decisionJSON, _ := json.MarshalIndent(m.Decisions, "", "  ")
+		outputs = append(outputs, logger.ModelOutput{
+			Model:        m.Name,
+			Response:     m.Response,
+			DecisionJSON: string(decisionJSON),
+			Rejected:     toRejectedRecords(m.Rejected),
+			Corrections:  toCorrectionRecords(m.Corrections),
+			Error:        m.Error,
+		})
+	}
+
+	var votes []logger.EnsembleVote
+	for _, v := range result.Votes {
+		votes = append(votes, logger.EnsembleVote{
+			Symbol:  v.Symbol,
+			Action:  v.Action,
+			Models:  v.Models,
+			Support: v.Support,
+			Total:   v.Total,
+			Adopted: v.Adopted,
+			Note:    v.Note,
+		})
+	}
+	return outputs, votes
+}
+
+// toCorrectionRecords 转换为日志记录格式
+func toCorrectionRecords(rounds []decision.CorrectionRound) []logger.CorrectionRound {
+	var records []logger.CorrectionRound
+	for _, c := range rounds {
+		records = append(records, logger.CorrectionRound{
+			Round:    c.Round,
+			Feedback: c.Feedback,
+			Response: c.Response,
+			Error:    c.Error,
+			Rejected: toRejectedRecords(c.Rejected),
+		})
+	}
+	return records
+}
+
+// toRejectedRecords 转换为日志记录格式
+func toRejectedRecords(rejected []decision.RejectedDecision) []logger.RejectedDecision {
+	var records []logger.RejectedDecision
+	for _, r := range rejected {
+		records = append(records, logger.RejectedDecision{
+			Symbol: r.Decision.Symbol,
+			Action: r.Decision.Action,
+			Reason: r.Reason,
+		})
+	}
+	return records
+}
+
 // buildTradingContext 构建交易上下文
 func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
 	// 1. 获取账户信息
@@ -467,10 +686,22 @@ func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
 		})
 	}
 
-	// 清理已平仓的持仓记录
+	// 清理已平仓的持仓记录（止盈止损等非AI平仓也在这里记录平仓时间）
 	for key := range at.positionFirstSeenTime {
 		if !currentPositionKeys[key] {
 			delete(at.positionFirstSeenTime, key)
+			symbol := key[:strings.LastIndex(key, "_")]
+			if _, ok := at.lastCloseTime[symbol]; !ok {
+				at.lastCloseTime[symbol] = time.Now()
+			}
+		}
+	}
+
+	// 清理已过冷却期的平仓记录
+	cooldown := time.Duration(at.config.RiskProfile.CooldownMinutes) * time.Minute
+	for symbol, closedAt := range at.lastCloseTime {
+		if time.Since(closedAt) > cooldown {
+			delete(at.lastCloseTime, symbol)
 		}
 	}
 
@@ -526,6 +757,8 @@ func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
 		CallCount:       at.callCount,
 		BTCETHLeverage:  at.config.BTCETHLeverage,  // 使用配置的杠杆倍数
 		AltcoinLeverage: at.config.AltcoinLeverage, // 使用配置的杠杆倍数
+		ScanIntervalMin: int(at.config.ScanInterval.Minutes()),
+		Deadline:        time.Now().Add(at.config.ScanInterval), // 下一个周期开始前必须完成
 		Account: decision.AccountInfo{
 			TotalEquity:      totalEquity,
 			AvailableBalance: availableBalance,
@@ -537,23 +770,35 @@ func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
 		},
 		Positions:      positionInfos,
 		CandidateCoins: candidateCoins,
-		Performance:    performance, // 添加历史表现分析
+		MarketOptions:  at.marketOptions,
+
+		CorrelationThreshold:   at.config.CorrelationThreshold,
+		MaxCorrelatedPositions: at.config.MaxCorrelatedPositions,
+		RegimeRules:            at.config.RegimeRules,
+		Filters:                at.filters,
+		Prompts:                at.prompts,
+		Risk:                   at.config.RiskProfile,
+		RecentCloses:           at.lastCloseTime,
+		MaxCorrectionRounds:    at.config.MaxCorrectionRounds,
+		Performance:            performance, // 添加历史表现分析
+		Memory:                 at.buildMemory(performance),
 	}
 
 	return ctx, nil
 }
 
 // executeDecisionWithRecord 执行AI决策并记录详细信息
-func (at *AutoTrader) executeDecisionWithRecord(decision *decision.Decision, actionRecord *logger.DecisionAction) error {
+// 价格使用本周期决策时已获取的行情数据（ctx.MarketDataMap），执行时不再重复获取
+func (at *AutoTrader) executeDecisionWithRecord(ctx *decision.Context, decision *decision.Decision, actionRecord *logger.DecisionAction) error {
 	switch decision.Action {
 	case "open_long":
-		return at.executeOpenLongWithRecord(decision, actionRecord)
+		return at.executeOpenLongWithRecord(ctx, decision, actionRecord)
 	case "open_short":
-		return at.executeOpenShortWithRecord(decision, actionRecord)
+		return at.executeOpenShortWithRecord(ctx, decision, actionRecord)
 	case "close_long":
-		return at.executeCloseLongWithRecord(decision, actionRecord)
+		return at.executeCloseLongWithRecord(ctx, decision, actionRecord)
 	case "close_short":
-		return at.executeCloseShortWithRecord(decision, actionRecord)
+		return at.executeCloseShortWithRecord(ctx, decision, actionRecord)
 	case "hold", "wait":
 		// 无需执行，仅记录
 		return nil
@@ -563,7 +808,7 @@ func (at *AutoTrader) executeDecisionWithRecord(decision *decision.Decision, act
 }
 
 // executeOpenLongWithRecord 执行开多仓并记录详细信息
-func (at *AutoTrader) executeOpenLongWithRecord(decision *decision.Decision, actionRecord *logger.DecisionAction) error {
+func (at *AutoTrader) executeOpenLongWithRecord(ctx *decision.Context, decision *decision.Decision, actionRecord *logger.DecisionAction) error {
 	log.Printf("  📈 开多仓: %s", decision.Symbol)
 
 	// ⚠️ 关键：检查是否已有同币种同方向持仓，如果有则拒绝开仓（防止仓位叠加超限）
@@ -576,9 +821,19 @@ func (at *AutoTrader) executeOpenLongWithRecord(decision *decision.Decision, act
 		}
 	}
 
-	// 获取当前价格
-	marketData, err := market.Get(decision.Symbol)
-	if err != nil {
+	// 当前价格（本周期行情数据）
+	marketData, ok := ctx.MarketDataMap[decision.Symbol]
+	if !ok || marketData == nil {
+		return fmt.Errorf("%s 无本周期行情数据，拒绝开仓", decision.Symbol)
+	}
+
+	// 数据质量检查（过期价格会导致数量计算错误）
+	if len(marketData.QualityIssues) > 0 {
+		return fmt.Errorf("%s 行情数据异常，拒绝开仓: %s", decision.Symbol, strings.Join(marketData.QualityIssues, "; "))
+	}
+
+	// 盘口冲击检查
+	if err := at.checkMarketImpact(ctx, decision, true); err != nil {
 		return err
 	}
 
@@ -616,7 +871,7 @@ func (at *AutoTrader) executeOpenLongWithRecord(decision *decision.Decision, act
 }
 
 // executeOpenShortWithRecord 执行开空仓并记录详细信息
-func (at *AutoTrader) executeOpenShortWithRecord(decision *decision.Decision, actionRecord *logger.DecisionAction) error {
+func (at *AutoTrader) executeOpenShortWithRecord(ctx *decision.Context, decision *decision.Decision, actionRecord *logger.DecisionAction) error {
 	log.Printf("  📉 开空仓: %s", decision.Symbol)
 
 	// ⚠️ 关键：检查是否已有同币种同方向持仓，如果有则拒绝开仓（防止仓位叠加超限）
@@ -629,9 +884,19 @@ func (at *AutoTrader) executeOpenShortWithRecord(decision *decision.Decision, ac
 		}
 	}
 
-	// 获取当前价格
-	marketData, err := market.Get(decision.Symbol)
-	if err != nil {
+	// 当前价格（本周期行情数据）
+	marketData, ok := ctx.MarketDataMap[decision.Symbol]
+	if !ok || marketData == nil {
+		return fmt.Errorf("%s 无本周期行情数据，拒绝开仓", decision.Symbol)
+	}
+
+	// 数据质量检查（过期价格会导致数量计算错误）
+	if len(marketData.QualityIssues) > 0 {
+		return fmt.Errorf("%s 行情数据异常，拒绝开仓: %s", decision.Symbol, strings.Join(marketData.QualityIssues, "; "))
+	}
+
+	// 盘口冲击检查
+	if err := at.checkMarketImpact(ctx, decision, false); err != nil {
 		return err
 	}
 
@@ -668,16 +933,46 @@ func (at *AutoTrader) executeOpenShortWithRecord(decision *decision.Decision, ac
 	return nil
 }
 
+// checkMarketImpact 开仓前检查盘口深度能否承接该仓位（冲击超过上限则拒绝）
+// 只重新获取订单簿（决策耗时期间盘口可能已变化），请求受本周期截止时间约束
+func (at *AutoTrader) checkMarketImpact(ctx *decision.Context, decision *decision.Decision, buy bool) error {
+	reqCtx, cancel := context.WithCancel(context.Background())
+	if !ctx.Deadline.IsZero() {
+		reqCtx, cancel = context.WithDeadline(context.Background(), ctx.Deadline)
+	}
+	defer cancel()
+
+	depth, err := market.GetDepth(reqCtx, decision.Symbol, at.marketOptions)
+	if err != nil {
+		log.Printf("  ⚠️ %s 无盘口数据，跳过冲击检查: %v", decision.Symbol, err)
+		return nil
+	}
+
+	impactPct, filled, err := depth.EstimateImpact(decision.PositionSizeUSD, buy)
+	if err != nil {
+		log.Printf("  ⚠️ %s 盘口冲击评估失败，跳过检查: %v", decision.Symbol, err)
+		return nil
+	}
+	if impactPct > at.config.MaxImpactPct {
+		return fmt.Errorf("%s 盘口深度不足：%.0f USDT 预计冲击%.3f%%，超过上限%.2f%%", decision.Symbol, decision.PositionSizeUSD, impactPct, at.config.MaxImpactPct)
+	}
+	if !filled {
+		// 快照内的档位吃完仍未成交完，剩余部分的冲击只会更大，视为超过上限
+		return fmt.Errorf("%s 盘口深度不足：快照内的挂单不足以承接%.0f USDT（已覆盖部分冲击%.3f%%，上限%.2f%%）", decision.Symbol, decision.PositionSizeUSD, impactPct, at.config.MaxImpactPct)
+	}
+
+	log.Printf("  📖 %s 预计盘口冲击%.3f%%（上限%.2f%%）", decision.Symbol, impactPct, at.config.MaxImpactPct)
+	return nil
+}
+
 // executeCloseLongWithRecord 执行平多仓并记录详细信息
-func (at *AutoTrader) executeCloseLongWithRecord(decision *decision.Decision, actionRecord *logger.DecisionAction) error {
+func (at *AutoTrader) executeCloseLongWithRecord(ctx *decision.Context, decision *decision.Decision, actionRecord *logger.DecisionAction) error {
 	log.Printf("  🔄 平多仓: %s", decision.Symbol)
 
-	// 获取当前价格
-	marketData, err := market.Get(decision.Symbol)
-	if err != nil {
-		return err
+	// 记录价格（本周期行情数据，缺失时不影响平仓）
+	if marketData, ok := ctx.MarketDataMap[decision.Symbol]; ok && marketData != nil {
+		actionRecord.Price = marketData.CurrentPrice
 	}
-	actionRecord.Price = marketData.CurrentPrice
 
 	// 平仓
 	order, err := at.trader.CloseLong(decision.Symbol, 0) // 0 = 全部平仓
@@ -690,20 +985,19 @@ func (at *AutoTrader) executeCloseLongWithRecord(decision *decision.Decision, ac
 		actionRecord.OrderID = orderID
 	}
 
+	at.lastCloseTime[decision.Symbol] = time.Now()
 	log.Printf("  ✓ 平仓成功")
 	return nil
 }
 
 // executeCloseShortWithRecord 执行平空仓并记录详细信息
-func (at *AutoTrader) executeCloseShortWithRecord(decision *decision.Decision, actionRecord *logger.DecisionAction) error {
+func (at *AutoTrader) executeCloseShortWithRecord(ctx *decision.Context, decision *decision.Decision, actionRecord *logger.DecisionAction) error {
 	log.Printf("  🔄 平空仓: %s", decision.Symbol)
 
-	// 获取当前价格
-	marketData, err := market.Get(decision.Symbol)
-	if err != nil {
-		return err
+	// 记录价格（本周期行情数据，缺失时不影响平仓）
+	if marketData, ok := ctx.MarketDataMap[decision.Symbol]; ok && marketData != nil {
+		actionRecord.Price = marketData.CurrentPrice
 	}
-	actionRecord.Price = marketData.CurrentPrice
 
 	// 平仓
 	order, err := at.trader.CloseShort(decision.Symbol, 0) // 0 = 全部平仓
@@ -716,6 +1010,7 @@ func (at *AutoTrader) executeCloseShortWithRecord(decision *decision.Decision, a
 		actionRecord.OrderID = orderID
 	}
 
+	at.lastCloseTime[decision.Symbol] = time.Now()
 	log.Printf("  ✓ 平仓成功")
 	return nil
 }
@@ -742,11 +1037,6 @@ func (at *AutoTrader) GetDecisionLogger() *logger.DecisionLogger {
 
 // GetStatus 获取系统状态（用于API）
 func (at *AutoTrader) GetStatus() map[string]interface{} {
-	aiProvider := "DeepSeek"
-	if at.config.UseQwen {
-		aiProvider = "Qwen"
-	}
-
 	return map[string]interface{}{
 		"trader_id":       at.id,
 		"trader_name":     at.name,
@@ -760,7 +1050,7 @@ func (at *AutoTrader) GetStatus() map[string]interface{} {
 		"scan_interval":   at.config.ScanInterval.String(),
 		"stop_until":      at.stopUntil.Format(time.RFC3339),
 		"last_reset_time": at.lastResetTime.Format(time.RFC3339),
-		"ai_provider":     aiProvider,
+		"ai_provider":     at.provider,
 	}
 }
 
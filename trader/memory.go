@@ -0,0 +1,105 @@
+package trader
+
+import (
+	"encoding/json"
+	"log"
+	"nofx/decision"
+	"nofx/logger"
+	"sort"
+)
+
+// memoryLookbackCycles 查找最近决策时回看的周期数（大多数周期只有 wait/hold）
+const memoryLookbackCycles = 100
+
+// buildMemory 从决策日志和历史表现整理决策记忆（未配置memory时返回nil）
+func (at *AutoTrader) buildMemory(performance *logger.PerformanceAnalysis) *decision.Memory {
+	if at.config.Memory == nil {
+		return nil
+	}
+	cfg := at.config.Memory.WithDefaults()
+	memory := &decision.Memory{MaxTokens: cfg.MaxTokens}
+
+	// 1. 最近的开平仓决策（含理由和执行结果）
+	if cfg.Decisions > 0 {
+		records, err := at.decisionLogger.GetLatestRecords(memoryLookbackCycles)
+		if err != nil {
+			log.Printf("⚠️  读取历史决策失败: %v", err)
+		}
+		for i := len(records) - 1; i >= 0 && len(memory.Decisions) < cfg.Decisions; i-- {
+			record := records[i]
+			if record.DecisionJSON == "" {
+				continue
+			}
+			var decisions []decision.Decision
+			if err := json.Unmarshal([]byte(record.DecisionJSON), &decisions); err != nil {
+				continue
+			}
+			for j := len(decisions) - 1; j >= 0 && len(memory.Decisions) < cfg.Decisions; j-- {
+				d := decisions[j]
+				if d.Action == "hold" || d.Action == "wait" {
+					continue
+				}
+				item := decision.MemoryDecision{
+					Time:      record.Timestamp,
+					Symbol:    d.Symbol,
+					Action:    d.Action,
+					Reasoning: d.Reasoning,
+					Error:     "未执行",
+				}
+				for _, action := range record.Decisions {
+					if action.Symbol == d.Symbol && action.Action == d.Action {
+						item.Executed, item.Error = action.Success, action.Error
+					}
+				}
+				memory.Decisions = append(memory.Decisions, item)
+			}
+		}
+		reverse(memory.Decisions)
+	}
+
+	if performance != nil {
+		// 2. 最近平仓的交易（RecentTrades 从新到旧）
+		for i := 0; i < len(performance.RecentTrades) && i < cfg.Trades; i++ {
+			t := performance.RecentTrades[i]
+			memory.Trades = append(memory.Trades, decision.MemoryTrade{
+				Symbol:    t.Symbol,
+				Side:      t.Side,
+				PnL:       t.PnL,
+				PnLPct:    t.PnLPct,
+				Hold:      t.CloseTime.Sub(t.OpenTime),
+				CloseTime: t.CloseTime,
+				StopLoss:  t.WasStopLoss,
+			})
+		}
+		reverse(memory.Trades)
+
+		// 3. 各币种胜率（按交易次数排序）
+		for _, stats := range performance.SymbolStats {
+			memory.Symbols = append(memory.Symbols, decision.MemorySymbol{
+				Symbol:   stats.Symbol,
+				Trades:   stats.TotalTrades,
+				Wins:     stats.WinningTrades,
+				WinRate:  stats.WinRate,
+				TotalPnL: stats.TotalPnL,
+			})
+		}
+		sort.Slice(memory.Symbols, func(i, j int) bool {
+			if memory.Symbols[i].Trades != memory.Symbols[j].Trades {
+				return memory.Symbols[i].Trades > memory.Symbols[j].Trades
+			}
+			return memory.Symbols[i].Symbol < memory.Symbols[j].Symbol
+		})
+		if len(memory.Symbols) > cfg.Symbols {
+			memory.Symbols = memory.Symbols[:cfg.Symbols]
+		}
+	}
+
+	return memory
+}
+
+// reverse 原地反转切片
+func reverse[T any](items []T) {
+	for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+		items[i], items[j] = items[j], items[i]
+	}
+}